@@ -4,18 +4,26 @@ import (
 	"log"
 	"os"
 	"strconv"
+	"time"
 
 	"github.com/joho/godotenv"
 )
 
 type Config struct {
-	ServerPort      string
-	PostgresDSN     string
-	RedisAddr       string
-	RedisPassword   string
-	RedisDB         int
-	WeChatAppID     string
-	WeChatAppSecret string
+	ServerPort             string
+	PostgresDSN            string
+	RedisAddr              string
+	RedisPassword          string
+	RedisDB                int
+	WeChatAppID            string
+	WeChatAppSecret        string
+	WeChatAPIBase          string        // 微信接口地址，测试时可指向本地模拟服务
+	AccessTokenTTL         time.Duration // 访问token有效期
+	RefreshTokenTTL        time.Duration // 刷新token有效期（会话闲置超过该时长需重新登录）
+	SingleSession          bool          // 是否单设备登录：新登录会吊销该用户的其他会话
+	AdminTokenTTL          time.Duration // 管理后台token有效期
+	AdminBootstrapUsername string        // 初始GM账号（仅在没有任何管理员时创建）
+	AdminBootstrapPassword string        // 初始GM密码
 }
 
 func LoadConfig() *Config {
@@ -28,13 +36,20 @@ func LoadConfig() *Config {
 	}
 
 	return &Config{
-		ServerPort:      getEnv("SERVER_PORT", ":8080"),
-		PostgresDSN:     getEnv("POSTGRES_DSN", "host=localhost user=postgres password=postgres dbname=ggo port=5432 sslmode=disable"),
-		RedisAddr:       getEnv("REDIS_ADDR", "localhost:6379"),
-		RedisPassword:   getEnv("REDIS_PASSWORD", ""),
-		RedisDB:         getEnvAsInt("REDIS_DB", 0),
-		WeChatAppID:     getEnv("WECHAT_APP_ID", ""),
-		WeChatAppSecret: getEnv("WECHAT_APP_SECRET", ""),
+		ServerPort:             getEnv("SERVER_PORT", ":8080"),
+		PostgresDSN:            getEnv("POSTGRES_DSN", "host=localhost user=postgres password=postgres dbname=ggo port=5432 sslmode=disable"),
+		RedisAddr:              getEnv("REDIS_ADDR", "localhost:6379"),
+		RedisPassword:          getEnv("REDIS_PASSWORD", ""),
+		RedisDB:                getEnvAsInt("REDIS_DB", 0),
+		WeChatAppID:            getEnv("WECHAT_APP_ID", ""),
+		WeChatAppSecret:        getEnv("WECHAT_APP_SECRET", ""),
+		WeChatAPIBase:          getEnv("WECHAT_API_BASE", "https://api.weixin.qq.com"),
+		AccessTokenTTL:         time.Duration(getEnvAsInt("ACCESS_TOKEN_TTL_MINUTES", 120)) * time.Minute,
+		RefreshTokenTTL:        time.Duration(getEnvAsInt("REFRESH_TOKEN_TTL_DAYS", 30)) * 24 * time.Hour,
+		SingleSession:          getEnvAsBool("SINGLE_SESSION", false),
+		AdminTokenTTL:          time.Duration(getEnvAsInt("ADMIN_TOKEN_TTL_HOURS", 12)) * time.Hour,
+		AdminBootstrapUsername: getEnv("ADMIN_BOOTSTRAP_USERNAME", ""),
+		AdminBootstrapPassword: getEnv("ADMIN_BOOTSTRAP_PASSWORD", ""),
 	}
 }
 
@@ -53,3 +68,12 @@ func getEnvAsInt(key string, defaultValue int) int {
 	}
 	return defaultValue
 }
+
+func getEnvAsBool(key string, defaultValue bool) bool {
+	if value := os.Getenv(key); value != "" {
+		if boolValue, err := strconv.ParseBool(value); err == nil {
+			return boolValue
+		}
+	}
+	return defaultValue
+}
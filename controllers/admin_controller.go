@@ -0,0 +1,222 @@
+package controllers
+
+import (
+	"errors"
+	"ggo/config"
+	"ggo/models"
+	"ggo/services"
+	"ggo/utils"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// AdminController 管理后台账号控制器
+type AdminController struct {
+	db     *gorm.DB
+	admins *services.AdminService
+}
+
+// NewAdminController 创建管理后台账号控制器实例
+func NewAdminController(db *gorm.DB, cfg *config.Config) *AdminController {
+	return &AdminController{
+		db:     db,
+		admins: services.NewAdminService(db, cfg),
+	}
+}
+
+// Login 管理员登录，返回后台专用token
+func (ac *AdminController) Login(c *gin.Context) {
+	var req struct {
+		Username string `json:"username" binding:"required"`
+		Password string `json:"password" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "参数错误: "+err.Error())
+		return
+	}
+
+	admin, token, err := ac.admins.Login(req.Username, req.Password)
+	if err != nil {
+		// 失败的登录也会被操作日志记录，这里记下尝试的账号
+		c.Set("adminUsername", req.Username)
+		if errors.Is(err, services.ErrAdminInvalidCredentials) || errors.Is(err, services.ErrAdminDisabled) {
+			utils.ErrorResponse(c, http.StatusUnauthorized, err.Error())
+			return
+		}
+		utils.ErrorResponse(c, http.StatusInternalServerError, "登录失败: "+err.Error())
+		return
+	}
+
+	c.Set("adminID", admin.ID)
+	c.Set("adminUsername", admin.Username)
+	c.Set("adminRole", admin.Role)
+
+	utils.SuccessResponseWithToken(c, gin.H{
+		"admin":       admin,
+		"permissions": models.AdminRolePermissions[admin.Role],
+		"token":       token,
+		"expires_in":  int64(ac.admins.TokenTTL.Seconds()),
+	}, token)
+}
+
+// Me 获取当前管理员信息及权限
+func (ac *AdminController) Me(c *gin.Context) {
+	var admin models.AdminUser
+	if err := ac.db.First(&admin, c.GetUint("adminID")).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "账号不存在")
+		return
+	}
+
+	utils.SuccessResponse(c, gin.H{
+		"admin":       admin,
+		"permissions": models.AdminRolePermissions[admin.Role],
+	})
+}
+
+// ListAdmins 获取后台账号列表
+func (ac *AdminController) ListAdmins(c *gin.Context) {
+	var admins []models.AdminUser
+	if err := ac.db.Order("id asc").Find(&admins).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "查询后台账号失败: "+err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, admins)
+}
+
+// CreateAdmin 创建后台账号
+func (ac *AdminController) CreateAdmin(c *gin.Context) {
+	var req struct {
+		Username string `json:"username" binding:"required,min=3,max=50"`
+		Password string `json:"password" binding:"required,min=6"`
+		Role     string `json:"role" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "参数错误: "+err.Error())
+		return
+	}
+
+	admin, err := ac.admins.CreateAdmin(req.Username, req.Password, req.Role)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrAdminInvalidRole):
+			utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		case errors.Is(err, services.ErrAdminUsernameTaken):
+			utils.ErrorResponse(c, http.StatusConflict, err.Error())
+		default:
+			utils.ErrorResponse(c, http.StatusInternalServerError, "创建后台账号失败: "+err.Error())
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"data":    admin,
+	})
+}
+
+// UpdateAdmin 修改后台账号的角色、启用状态或密码
+func (ac *AdminController) UpdateAdmin(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "无效的账号ID")
+		return
+	}
+
+	var req struct {
+		Role     *string `json:"role"`
+		IsActive *bool   `json:"is_active"`
+		Password *string `json:"password" binding:"omitempty,min=6"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "参数错误: "+err.Error())
+		return
+	}
+
+	// 不允许停用自己或降低自己的角色，避免把自己锁在后台外
+	if uint(id) == c.GetUint("adminID") && ((req.IsActive != nil && !*req.IsActive) || (req.Role != nil && *req.Role != models.AdminRoleGM)) {
+		utils.ErrorResponse(c, http.StatusBadRequest, "不能停用自己或修改自己的角色")
+		return
+	}
+
+	var admin models.AdminUser
+	if err := ac.db.First(&admin, id).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "账号不存在")
+		return
+	}
+
+	updates := map[string]interface{}{}
+	if req.Role != nil {
+		if !models.IsValidAdminRole(*req.Role) {
+			utils.ErrorResponse(c, http.StatusBadRequest, services.ErrAdminInvalidRole.Error())
+			return
+		}
+		updates["role"] = *req.Role
+	}
+	if req.IsActive != nil {
+		updates["is_active"] = *req.IsActive
+	}
+	if req.Password != nil {
+		hashedPassword, err := utils.HashPassword(*req.Password)
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusInternalServerError, "密码加密失败")
+			return
+		}
+		updates["password"] = hashedPassword
+	}
+	if len(updates) == 0 {
+		utils.ErrorResponse(c, http.StatusBadRequest, "没有需要更新的字段")
+		return
+	}
+
+	if err := ac.db.Model(&admin).Updates(updates).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "更新后台账号失败: "+err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, admin)
+}
+
+// GetAuditLogs 分页查询后台操作日志，可按管理员和路由筛选
+func (ac *AdminController) GetAuditLogs(c *gin.Context) {
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page <= 0 {
+		utils.ErrorResponse(c, http.StatusBadRequest, "无效的page参数")
+		return
+	}
+	pageSize, err := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+	if err != nil || pageSize <= 0 || pageSize > 100 {
+		utils.ErrorResponse(c, http.StatusBadRequest, "无效的page_size参数")
+		return
+	}
+
+	query := ac.db.Model(&models.AdminAuditLog{})
+	if adminID := c.Query("admin_id"); adminID != "" {
+		query = query.Where("admin_id = ?", adminID)
+	}
+	if route := c.Query("route"); route != "" {
+		query = query.Where("route = ?", route)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "查询操作日志失败: "+err.Error())
+		return
+	}
+
+	var logs []models.AdminAuditLog
+	if err := query.Order("id desc").Offset((page - 1) * pageSize).Limit(pageSize).Find(&logs).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "查询操作日志失败: "+err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, gin.H{
+		"total":     total,
+		"page":      page,
+		"page_size": pageSize,
+		"logs":      logs,
+	})
+}
@@ -0,0 +1,229 @@
+package controllers
+
+import (
+	"errors"
+	"ggo/models"
+	"ggo/services"
+	"ggo/utils"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// AffixController 词条配置管理（管理后台），修改后立即递增规则集版本号
+type AffixController struct {
+	db *gorm.DB
+}
+
+// NewAffixController 创建词条配置控制器实例
+func NewAffixController(db *gorm.DB) *AffixController {
+	return &AffixController{db: db}
+}
+
+// affixDefinitionRequest 创建/修改词条定义的请求，ranges 会整体替换
+type affixDefinitionRequest struct {
+	AttrType    string              `json:"attr_type" binding:"required,max=20"`
+	Name        string              `json:"name" binding:"required,max=20"`
+	Title       string              `json:"title" binding:"max=20"`
+	Tier        string              `json:"tier" binding:"required"`
+	IsPercent   bool                `json:"is_percent"`
+	Decimals    int                 `json:"decimals"`
+	Description string              `json:"description" binding:"max=200"`
+	IsActive    *bool               `json:"is_active"`
+	Ranges      []models.AffixRange `json:"ranges"`
+}
+
+func (req *affixDefinitionRequest) toModel() models.AffixDefinition {
+	def := models.AffixDefinition{
+		AttrType:    req.AttrType,
+		Name:        req.Name,
+		Title:       req.Title,
+		Tier:        req.Tier,
+		IsPercent:   req.IsPercent,
+		Decimals:    req.Decimals,
+		Description: req.Description,
+		IsActive:    true,
+	}
+	if req.IsActive != nil {
+		def.IsActive = *req.IsActive
+	}
+	for _, r := range req.Ranges {
+		r.ID = 0
+		r.AffixDefinitionID = 0
+		def.Ranges = append(def.Ranges, r)
+	}
+	return def
+}
+
+// GetAffixes 获取全部词条定义、词条规则和当前规则集版本
+func (ac *AffixController) GetAffixes(c *gin.Context) {
+	registry, err := services.GetAffixRegistry(ac.db)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "加载词条配置失败: "+err.Error())
+		return
+	}
+
+	var definitions []models.AffixDefinition
+	if err := ac.db.Preload("Ranges", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).
+		Order("id").Find(&definitions).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "查询词条定义失败: "+err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, gin.H{
+		"version":     registry.Version,
+		"rules":       registry.Rules,
+		"definitions": definitions,
+	})
+}
+
+// CreateAffix 新增词条定义
+func (ac *AffixController) CreateAffix(c *gin.Context) {
+	var req affixDefinitionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "参数错误: "+err.Error())
+		return
+	}
+
+	def := req.toModel()
+	if err := services.ValidateAffixDefinition(&def); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var count int64
+	ac.db.Model(&models.AffixDefinition{}).Where("attr_type = ?", def.AttrType).Count(&count)
+	if count > 0 {
+		utils.ErrorResponse(c, http.StatusConflict, "该属性类型已存在")
+		return
+	}
+
+	err := ac.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&def).Error; err != nil {
+			return err
+		}
+		// is_active 有默认值，创建时传 false 会被忽略，需单独更新
+		if !def.IsActive {
+			if err := tx.Model(&def).Update("is_active", false).Error; err != nil {
+				return err
+			}
+		}
+		return services.BumpAffixVersion(tx, c.GetString("adminUsername"))
+	})
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "创建词条失败: "+err.Error())
+		return
+	}
+	services.InvalidateAffixRegistry()
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"data":    def,
+	})
+}
+
+// UpdateAffix 修改词条定义，数值范围整体替换；属性类型不可修改（已有装备按类型引用）
+func (ac *AffixController) UpdateAffix(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "无效的词条ID")
+		return
+	}
+
+	var existing models.AffixDefinition
+	if err := ac.db.First(&existing, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			utils.ErrorResponse(c, http.StatusNotFound, "词条不存在")
+		} else {
+			utils.ErrorResponse(c, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+
+	var req affixDefinitionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "参数错误: "+err.Error())
+		return
+	}
+	if req.AttrType != existing.AttrType {
+		utils.ErrorResponse(c, http.StatusBadRequest, "属性类型不可修改")
+		return
+	}
+
+	def := req.toModel()
+	def.ID = existing.ID
+	if req.IsActive == nil {
+		def.IsActive = existing.IsActive
+	}
+	if err := services.ValidateAffixDefinition(&def); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	err = ac.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.AffixDefinition{}).Where("id = ?", def.ID).Updates(map[string]interface{}{
+			"name":        def.Name,
+			"title":       def.Title,
+			"tier":        def.Tier,
+			"is_percent":  def.IsPercent,
+			"decimals":    def.Decimals,
+			"description": def.Description,
+			"is_active":   def.IsActive,
+		}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("affix_definition_id = ?", def.ID).Delete(&models.AffixRange{}).Error; err != nil {
+			return err
+		}
+		for i := range def.Ranges {
+			def.Ranges[i].AffixDefinitionID = def.ID
+		}
+		if len(def.Ranges) > 0 {
+			if err := tx.Create(&def.Ranges).Error; err != nil {
+				return err
+			}
+		}
+		return services.BumpAffixVersion(tx, c.GetString("adminUsername"))
+	})
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "修改词条失败: "+err.Error())
+		return
+	}
+	services.InvalidateAffixRegistry()
+
+	utils.SuccessResponse(c, def)
+}
+
+// UpdateAffixRules 修改词条规则（打造/融合概率、词条上限、隐藏词条与鉴定概率），未传的字段使用默认值
+func (ac *AffixController) UpdateAffixRules(c *gin.Context) {
+	rules := services.DefaultAffixRules()
+	if err := c.ShouldBindJSON(&rules); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "参数错误: "+err.Error())
+		return
+	}
+
+	err := ac.db.Transaction(func(tx *gorm.DB) error {
+		return services.SaveAffixRules(tx, rules, c.GetString("adminUsername"))
+	})
+	if errors.Is(err, services.ErrAffixInvalid) {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "修改词条规则失败: "+err.Error())
+		return
+	}
+	services.InvalidateAffixRegistry()
+
+	registry, err := services.GetAffixRegistry(ac.db)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "加载词条配置失败: "+err.Error())
+		return
+	}
+	utils.SuccessResponse(c, gin.H{
+		"version": registry.Version,
+		"rules":   registry.Rules,
+	})
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"ggo/models"
+	"ggo/services/stats"
 	"ggo/utils"
 	"net/http"
 
@@ -57,6 +58,9 @@ func (ac *ArchiveController) SaveArchive(c *gin.Context) {
 		return
 	}
 
+	// 神秘商店由服务端管理（/shop 接口），不再保存客户端生成的商店数据
+	delete(jsonData, "mystery_shop")
+
 	// 使用事务确保数据一致性
 	var saveSuccess bool
 	var responseMessage string
@@ -116,6 +120,8 @@ func (ac *ArchiveController) SaveArchive(c *gin.Context) {
 	})
 
 	if saveSuccess {
+		// 存档中的基础属性可能变化，重新计算战力
+		stats.Refresh(ac.db, userID.(uint))
 		utils.SuccessResponse(c, gin.H{"message": responseMessage})
 	} else {
 		utils.ErrorResponse(c, http.StatusOK, responseMessage)
@@ -147,6 +153,9 @@ func (ac *ArchiveController) LoadArchive(c *gin.Context) {
 		return
 	}
 
+	// 旧存档中可能还带有客户端生成的神秘商店，读取时去掉
+	delete(archive.JSONData, "mystery_shop")
+
 	utils.SuccessResponse(c, gin.H{
 		"json_data": archive.JSONData,
 		"v":         archive.V,
@@ -0,0 +1,86 @@
+package controllers
+
+import (
+	"ggo/models"
+	"ggo/services"
+	"ggo/utils"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// CurrencyController 货币流水控制器
+type CurrencyController struct {
+	db *gorm.DB
+}
+
+// NewCurrencyController 创建货币流水控制器实例
+func NewCurrencyController(db *gorm.DB) *CurrencyController {
+	return &CurrencyController{db: db}
+}
+
+// GetTransactions 获取当前用户的货币流水
+func (cc *CurrencyController) GetTransactions(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "用户未认证")
+		return
+	}
+
+	currency := c.Query("currency")
+	if currency != "" && currency != models.CurrencyGold && currency != models.CurrencyDiamond {
+		utils.ErrorResponse(c, http.StatusBadRequest, "无效的currency参数，支持: gold, diamond")
+		return
+	}
+
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page <= 0 {
+		utils.ErrorResponse(c, http.StatusBadRequest, "无效的page参数")
+		return
+	}
+	pageSize, err := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+	if err != nil || pageSize <= 0 || pageSize > 100 {
+		utils.ErrorResponse(c, http.StatusBadRequest, "无效的page_size参数")
+		return
+	}
+
+	query := cc.db.Model(&models.CurrencyTransaction{}).Where("user_id = ?", userID.(uint))
+	if currency != "" {
+		query = query.Where("currency = ?", currency)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "查询流水失败: "+err.Error())
+		return
+	}
+
+	var transactions []models.CurrencyTransaction
+	if err := query.Order("id desc").Offset((page - 1) * pageSize).Limit(pageSize).Find(&transactions).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "查询流水失败: "+err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, gin.H{
+		"total":        total,
+		"page":         page,
+		"page_size":    pageSize,
+		"transactions": transactions,
+	})
+}
+
+// Reconcile 核对所有用户的余额与流水合计（管理员功能）
+func (cc *CurrencyController) Reconcile(c *gin.Context) {
+	mismatches, err := services.ReconcileCurrencyLedger(cc.db)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "对账失败: "+err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, gin.H{
+		"mismatch_count": len(mismatches),
+		"mismatches":     mismatches,
+	})
+}
@@ -0,0 +1,306 @@
+package controllers
+
+import (
+	"errors"
+	"ggo/models"
+	"ggo/services"
+	"ggo/utils"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// DropTableController 掉落表管理（管理后台）
+type DropTableController struct {
+	db *gorm.DB
+}
+
+// NewDropTableController 创建掉落表控制器实例
+func NewDropTableController(db *gorm.DB) *DropTableController {
+	return &DropTableController{db: db}
+}
+
+// dropTableRequest 创建/修改掉落表的请求，entries 会整体替换
+type dropTableRequest struct {
+	Name        string                  `json:"name" binding:"required,max=100"`
+	Description string                  `json:"description" binding:"max=500"`
+	Rolls       int                     `json:"rolls" binding:"min=0"`
+	NoneWeight  int                     `json:"none_weight" binding:"min=0"`
+	IsActive    *bool                   `json:"is_active"`
+	Entries     []models.DropTableEntry `json:"entries"`
+}
+
+func (req *dropTableRequest) toModel() models.DropTable {
+	table := models.DropTable{
+		Name:        req.Name,
+		Description: req.Description,
+		Rolls:       req.Rolls,
+		NoneWeight:  req.NoneWeight,
+		IsActive:    true,
+	}
+	if req.IsActive != nil {
+		table.IsActive = *req.IsActive
+	}
+	for _, entry := range req.Entries {
+		entry.ID = 0
+		entry.DropTableID = 0
+		table.Entries = append(table.Entries, entry)
+	}
+	return table
+}
+
+// GetDropTables 获取掉落表列表
+func (dc *DropTableController) GetDropTables(c *gin.Context) {
+	var tables []models.DropTable
+	if err := dc.db.Preload("Entries").Order("id").Find(&tables).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "查询掉落表失败: "+err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, tables)
+}
+
+// GetDropTable 获取掉落表详情及挂载关系
+func (dc *DropTableController) GetDropTable(c *gin.Context) {
+	table, ok := dc.loadTable(c)
+	if !ok {
+		return
+	}
+
+	var bindings []models.DropTableBinding
+	if err := dc.db.Where("drop_table_id = ?", table.ID).Order("id").Find(&bindings).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "查询挂载关系失败: "+err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, gin.H{
+		"drop_table": table,
+		"bindings":   bindings,
+	})
+}
+
+// CreateDropTable 创建掉落表
+func (dc *DropTableController) CreateDropTable(c *gin.Context) {
+	var req dropTableRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "参数错误: "+err.Error())
+		return
+	}
+
+	table := req.toModel()
+	if err := services.ValidateDropTable(&table); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	err := dc.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&table).Error; err != nil {
+			return err
+		}
+		// is_active 有默认值，创建时传 false 会被忽略，需单独更新
+		if !table.IsActive {
+			return tx.Model(&table).Update("is_active", false).Error
+		}
+		return nil
+	})
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "创建掉落表失败: "+err.Error())
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"data":    table,
+	})
+}
+
+// UpdateDropTable 修改掉落表，条目整体替换
+func (dc *DropTableController) UpdateDropTable(c *gin.Context) {
+	existing, ok := dc.loadTable(c)
+	if !ok {
+		return
+	}
+
+	var req dropTableRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "参数错误: "+err.Error())
+		return
+	}
+
+	table := req.toModel()
+	table.ID = existing.ID
+	if req.IsActive == nil {
+		table.IsActive = existing.IsActive
+	}
+	if err := services.ValidateDropTable(&table); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	err := dc.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.DropTable{}).Where("id = ?", table.ID).Updates(map[string]interface{}{
+			"name":        table.Name,
+			"description": table.Description,
+			"rolls":       table.Rolls,
+			"none_weight": table.NoneWeight,
+			"is_active":   table.IsActive,
+		}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("drop_table_id = ?", table.ID).Delete(&models.DropTableEntry{}).Error; err != nil {
+			return err
+		}
+		for i := range table.Entries {
+			table.Entries[i].DropTableID = table.ID
+		}
+		if len(table.Entries) > 0 {
+			return tx.Create(&table.Entries).Error
+		}
+		return nil
+	})
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "修改掉落表失败: "+err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, table)
+}
+
+// DeleteDropTable 删除掉落表及其条目和挂载关系
+func (dc *DropTableController) DeleteDropTable(c *gin.Context) {
+	table, ok := dc.loadTable(c)
+	if !ok {
+		return
+	}
+
+	err := dc.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("drop_table_id = ?", table.ID).Delete(&models.DropTableBinding{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("drop_table_id = ?", table.ID).Delete(&models.DropTableEntry{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.DropTable{}, table.ID).Error
+	})
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "删除掉落表失败: "+err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, gin.H{"message": "掉落表删除成功"})
+}
+
+// BindDropTable 将掉落表挂载到怪物、场景或首领
+func (dc *DropTableController) BindDropTable(c *gin.Context) {
+	table, ok := dc.loadTable(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		SourceType string `json:"source_type" binding:"required"`
+		SourceID   uint   `json:"source_id"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "参数错误: "+err.Error())
+		return
+	}
+	if !models.IsValidDropSource(req.SourceType) {
+		utils.ErrorResponse(c, http.StatusBadRequest, "无效的source_type，支持: monster, scene, boss")
+		return
+	}
+
+	// 怪物和场景需要真实存在
+	var err error
+	switch req.SourceType {
+	case models.DropSourceMonster:
+		err = dc.db.First(&models.Monster{}, req.SourceID).Error
+	case models.DropSourceScene:
+		err = dc.db.First(&models.Scene{}, req.SourceID).Error
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		utils.ErrorResponse(c, http.StatusBadRequest, "挂载目标不存在")
+		return
+	}
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	binding := models.DropTableBinding{
+		DropTableID: table.ID,
+		SourceType:  req.SourceType,
+		SourceID:    req.SourceID,
+	}
+	var count int64
+	dc.db.Model(&models.DropTableBinding{}).
+		Where("drop_table_id = ? AND source_type = ? AND source_id = ?", table.ID, req.SourceType, req.SourceID).
+		Count(&count)
+	if count > 0 {
+		utils.ErrorResponse(c, http.StatusConflict, "该掉落表已挂载到此目标")
+		return
+	}
+	if err := dc.db.Create(&binding).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "挂载掉落表失败: "+err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, binding)
+}
+
+// UnbindDropTable 取消挂载
+func (dc *DropTableController) UnbindDropTable(c *gin.Context) {
+	result := dc.db.Where("id = ? AND drop_table_id = ?", c.Param("binding_id"), c.Param("id")).
+		Delete(&models.DropTableBinding{})
+	if result.Error != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "取消挂载失败: "+result.Error.Error())
+		return
+	}
+	if result.RowsAffected == 0 {
+		utils.ErrorResponse(c, http.StatusNotFound, "挂载关系不存在")
+		return
+	}
+
+	utils.SuccessResponse(c, gin.H{"message": "取消挂载成功"})
+}
+
+// SimulateDropTable 模拟抽取N次并返回分布（不发放任何物品），未启用的掉落表也可模拟
+func (dc *DropTableController) SimulateDropTable(c *gin.Context) {
+	table, ok := dc.loadTable(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Times int `json:"times" binding:"required,min=1,max=100000"`
+		Level int `json:"level" binding:"min=0"` // 模拟的玩家等级
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "参数错误: "+err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, services.SimulateDropTable(table, req.Times, req.Level, time.Now().UnixNano()))
+}
+
+// loadTable 按路径参数 id 加载掉落表，失败时已写入响应
+func (dc *DropTableController) loadTable(c *gin.Context) (*models.DropTable, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "无效的掉落表ID")
+		return nil, false
+	}
+
+	var table models.DropTable
+	if err := dc.db.Preload("Entries", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).First(&table, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			utils.ErrorResponse(c, http.StatusNotFound, "掉落表不存在")
+		} else {
+			utils.ErrorResponse(c, http.StatusInternalServerError, err.Error())
+		}
+		return nil, false
+	}
+	return &table, true
+}
@@ -1,14 +1,16 @@
 package controllers
 
 import (
+	"errors"
 	"fmt"
 	"ggo/models"
+	"ggo/services"
+	"ggo/services/stats"
 	"ggo/utils"
-	"math/rand"
 	"net/http"
+	"sort"
 	"strconv"
 	"strings"
-	"time"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
@@ -53,8 +55,6 @@ func (ec *EquipmentController) GetEquipmentTemplates(c *gin.Context) {
 }
 
 func NewEquipmentController(db *gorm.DB) *EquipmentController {
-	// 初始化随机数种子
-	rand.Seed(time.Now().UnixNano())
 	return &EquipmentController{db: db}
 }
 
@@ -76,227 +76,70 @@ func (ec *EquipmentController) GenerateEquipment(c *gin.Context) {
 		return
 	}
 
-	// 开始事务
-	tx := ec.db.Begin()
-
-	// 1. 验证用户存在
-	var user models.User
-	if err := tx.First(&user, userID.(uint)).Error; err != nil {
-		tx.Rollback()
-		utils.ErrorResponse(c, http.StatusNotFound, "用户不存在")
+	result, err := services.NewForgeService(ec.db).Forge(userID.(uint), request.ItemIDs)
+	if err != nil {
+		respondForgeError(c, err, "装备打造失败")
 		return
 	}
 
-	// 2. 验证宝物存在且属于该用户，并且数量大于0
-	var treasures []models.Treasure
-	var myItems []models.MyItem
-
-	for _, myItemID := range request.ItemIDs {
-		// 先根据itemID找到MyItem记录
-		var myItem models.MyItem
-		if err := tx.Where("id = ? AND user_id = ? AND item_type = ?", myItemID, userID.(uint), "treasure").First(&myItem).Error; err != nil {
-			tx.Rollback()
-			utils.ErrorResponse(c, http.StatusNotFound, "宝物不存在或不属于该用户")
-			return
-		}
-
-		// 检查宝物数量是否大于0
-		if myItem.Quantity <= 0 {
-			tx.Rollback()
-			utils.ErrorResponse(c, http.StatusBadRequest, "宝物数量不足")
-			return
-		}
-
-		// 然后使用MyItem中的item_id作为treasureID搜索宝物信息
-		var treasure models.Treasure
-		if err := tx.First(&treasure, myItem.ItemID).Error; err != nil {
-			tx.Rollback()
-			utils.ErrorResponse(c, http.StatusNotFound, "宝物信息不存在")
-			return
-		}
-
-		treasures = append(treasures, treasure)
-		myItems = append(myItems, myItem)
-	}
-
-	// 3. 固定消耗5万金币
-	costGold := 50000
-	if user.Gold < costGold {
-		tx.Rollback()
-		utils.ErrorResponse(c, http.StatusBadRequest, "金币不足")
-		return
-	}
+	utils.SuccessResponse(c, gin.H{
+		"message":                 "装备打造成功",
+		"cost_gold":               result.CostGold,
+		"current_gold":            result.CurrentGold,
+		"equipment_level":         result.Level,
+		"user_equipment":          result.Equipment,
+		"used_treasures":          result.Treasures,
+		"selected_treasure_index": result.SelectedIndex,
+		"rare_guaranteed":         result.RareGuaranteed,
+		"forge_pity":              result.Pity,
+	})
+}
 
-	// 4. 扣除金币
-	if err := tx.Model(&user).Update("gold", user.Gold-costGold).Error; err != nil {
-		tx.Rollback()
-		utils.ErrorResponse(c, http.StatusInternalServerError, "扣除金币失败")
+// PreviewGenerateEquipment 打造预览：按选择的宝物返回消耗、保底进度和与实际打造一致的完整概率
+func (ec *EquipmentController) PreviewGenerateEquipment(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "用户未认证")
 		return
 	}
 
-	// 5. 减少使用的宝物数量
-	for _, myItem := range myItems {
-		// 减少数量
-		myItem.Quantity--
-		if myItem.Quantity <= 0 {
-			// 数量为0时删除记录
-			if err := tx.Delete(&myItem).Error; err != nil {
-				tx.Rollback()
-				utils.ErrorResponse(c, http.StatusInternalServerError, "删除宝物失败")
-				return
-			}
-		} else {
-			// 数量不为0时更新数量
-			if err := tx.Save(&myItem).Error; err != nil {
-				tx.Rollback()
-				utils.ErrorResponse(c, http.StatusInternalServerError, "更新宝物数量失败")
+	// 支持 itemids=1,2,3 或重复的 itemids 参数
+	var itemIDs []uint
+	for _, value := range c.QueryArray("itemids") {
+		for _, part := range strings.Split(value, ",") {
+			id, err := strconv.ParseUint(strings.TrimSpace(part), 10, 32)
+			if err != nil {
+				utils.ErrorResponse(c, http.StatusBadRequest, "无效的宝物ID")
 				return
 			}
+			itemIDs = append(itemIDs, uint(id))
 		}
 	}
 
-	// 6. 从提供的宝物中随机选择一件的品级
-	randomIndex := rand.Intn(len(treasures))
-	equipmentLevel := treasures[randomIndex].Level
-
-	// 7. 随机选择对应等级的装备模板
-	var equipmentTemplate models.EquipmentTemplate
-	if err := tx.Where("level = ? AND is_active = ?", equipmentLevel, true).Order("RANDOM()").First(&equipmentTemplate).Error; err != nil {
-		tx.Rollback()
-		utils.ErrorResponse(c, http.StatusNotFound, "没有找到合适的装备模板")
-		return
-	}
-
-	// 8. 创建玩家装备记录
-	userEquipment := models.UserEquipment{
-		UserID:      userID.(uint),
-		EquipmentID: equipmentTemplate.ID,
-		Position:    "backpack",
-	}
-
-	if err := tx.Create(&userEquipment).Error; err != nil {
-		tx.Rollback()
-		utils.ErrorResponse(c, http.StatusInternalServerError, "创建装备失败")
+	preview, err := services.NewForgeService(ec.db).Preview(userID.(uint), itemIDs)
+	if err != nil {
+		respondForgeError(c, err, "获取打造预览失败")
 		return
 	}
-
-	// 9. 处理附加属性
-	var newAttr *models.EquipmentAdditionalAttr
-	randomValue := rand.Float64()
-
-	if randomValue < 0.5 {
-		// 1% 概率添加稀有属性（七宗罪）
-		newAttr = ec.generateRareAttr(equipmentLevel)
-	} else if randomValue < 0.9 {
-		// 10% 概率添加普通属性
-		newAttr = ec.generateCommonAttr(equipmentLevel)
-	}
-
-	// 10. 保存附加属性
-	if newAttr != nil {
-		newAttr.UserEquipmentID = userEquipment.ID
-		if err := tx.Create(newAttr).Error; err != nil {
-			tx.Rollback()
-			utils.ErrorResponse(c, http.StatusInternalServerError, "创建附加属性失败")
-			return
-		}
-	}
-
-	// 11. 提交事务
-	tx.Commit()
-
-	// 12. 加载装备完整信息
-	ec.db.Preload("EquipmentTemplate").Preload("AdditionalAttrs").First(&userEquipment, userEquipment.ID)
-
-	// 13. 返回结果
-	response := gin.H{
-		"message":                 "装备打造成功",
-		"cost_gold":               costGold,
-		"current_gold":            user.Gold - costGold,
-		"equipment_level":         equipmentLevel,
-		"user_equipment":          userEquipment,
-		"used_treasures":          treasures,
-		"selected_treasure_index": randomIndex,
-	}
-
-	utils.SuccessResponse(c, response)
-}
-
-// generateCommonAttr 生成普通附加属性
-func (ec *EquipmentController) generateCommonAttr(level int) *models.EquipmentAdditionalAttr {
-	// 普通属性类型和范围
-	commonAttrs := []struct {
-		Type      string
-		Name      string
-		Min, Max  float64
-		IsPercent bool
-	}{
-		{"attack_bonus", "攻击力加成", 1, 3, true},  // 攻击力加成1~3%
-		{"critical_rate", "暴击率", 1, 3, true},   // 暴击率1~3%
-		{"drain", "吸血", 1, 3, true},            // 吸血1~3%
-		{"damage_reduction", "减伤", 1, 3, true}, // 减伤1~3%
-		{"recovery", "生命恢复", 20, 100, false},   // 自动回复20-100
-		{"attack_fixed", "攻击力", 10, 30, false}, // 攻击力+10~30
-		{"hp_bonus", "血量加成", 1, 5, true},       // 血量加成1%~5%
-	}
-
-	// 随机选择一个属性
-	attr := commonAttrs[rand.Intn(len(commonAttrs))]
-	value := attr.Min + rand.Float64()*(attr.Max-attr.Min)
-
-	// 格式化属性值
-	var attrValue string
-	if attr.IsPercent {
-		attrValue = strconv.FormatFloat(value, 'f', 1, 64) + "%"
-	} else {
-		attrValue = strconv.Itoa(int(value))
-	}
-
-	return &models.EquipmentAdditionalAttr{
-		AttrType:  attr.Type,
-		AttrName:  attr.Name, // 设置普通属性名称
-		AttrValue: attrValue,
-	}
+	utils.SuccessResponse(c, preview)
 }
 
-// generateRareAttr 生成稀有附加属性（七宗罪）
-func (ec *EquipmentController) generateRareAttr(level int) *models.EquipmentAdditionalAttr {
-	// 稀有属性类型和范围
-	rareAttrs := []struct {
-		Type      string
-		Name      string
-		Min, Max  float64
-		IsPercent bool
-	}{
-		{"sloth", "傲慢", 5, 20, true},     // 最大HP提升5-20%
-		{"envy", "嫉妒", 10, 20, true},     // 暴击伤害提升10-20%
-		{"gluttony", "暴食", 10, 20, true}, // 攻速提升10-20%
-		{"greed", "贪婪", 1, 1, true},      // 攻击时1%几率秒杀
-		{"lust", "色欲", 100, 200, false},  // 自动回复100~200
-		{"wrath", "暴怒", 8, 10, true},     // 暴击率8~10%
-		{"pride", "怠惰", 5, 20, true},     // 攻击力提升5-20%
-	}
-
-	// 随机选择一个属性
-	attr := rareAttrs[rand.Intn(len(rareAttrs))]
-	value := attr.Min + rand.Float64()*(attr.Max-attr.Min)
-
-	// 格式化属性值
-	var attrValue string
-	if attr.IsPercent {
-		if attr.Type == "greed" {
-			attrValue = "秒杀1%"
-		} else {
-			attrValue = strconv.FormatFloat(value, 'f', 1, 64) + "%"
-		}
-	} else {
-		attrValue = strconv.Itoa(int(value))
-	}
-
-	return &models.EquipmentAdditionalAttr{
-		AttrType:  attr.Type,
-		AttrName:  attr.Name, // 设置稀有属性名称
-		AttrValue: attrValue,
+func respondForgeError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		utils.ErrorResponse(c, http.StatusNotFound, "用户不存在")
+	case errors.Is(err, services.ErrTreasureNotEnough):
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+	case errors.Is(err, services.ErrForgeTreasureCount):
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+	case errors.Is(err, services.ErrForgeTemplateMissing):
+		utils.ErrorResponse(c, http.StatusNotFound, err.Error())
+	case errors.Is(err, services.ErrInsufficientGold):
+		utils.ErrorResponse(c, http.StatusBadRequest, "金币不足")
+	case errors.Is(err, services.ErrInventoryFull):
+		utils.ErrorResponse(c, http.StatusBadRequest, "背包已满，请整理背包后再打造")
+	default:
+		utils.ErrorResponse(c, http.StatusInternalServerError, message)
 	}
 }
 
@@ -376,13 +219,40 @@ func (ec *EquipmentController) EquipItem(c *gin.Context) {
 	// 4. 获取装备的Slot信息
 	slot := userEquipment.EquipmentTemplate.Slot
 
-	// 5. 卸下用户在同一Slot上已穿戴的其他装备
+	// 5. 卸下用户在同一Slot上已穿戴的其他装备，放回背包
 	// 即使没有找到需要卸下的装备，也不应报错，继续执行
 	// 使用子查询方式确保正确的JOIN操作
 	subQuery := tx.Table("equipment_templates").Select("id").Where("slot = ?", slot)
-	result := tx.Model(&models.UserEquipment{}).
-		Where("user_id = ? AND is_equipped = ? AND equipment_id IN (?)", userID, true, subQuery).
-		Update("is_equipped", false)
+	displaced := tx.Model(&models.UserEquipment{}).
+		Where("user_id = ? AND is_equipped = ? AND equipment_id IN (?)", userID, true, subQuery)
+
+	// 从背包穿上时，卸下的装备使用腾出的格子；从仓库穿上时需要背包有空位
+	if userEquipment.Position != models.PositionBackpack {
+		var displacedCount int64
+		if err := displaced.Session(&gorm.Session{}).Count(&displacedCount).Error; err != nil {
+			tx.Rollback()
+			utils.ErrorResponse(c, http.StatusInternalServerError, "查询同部位装备失败")
+			return
+		}
+		if displacedCount > 0 {
+			free, err := services.FreeSlots(tx, userID.(uint), models.PositionBackpack)
+			if err != nil {
+				tx.Rollback()
+				utils.ErrorResponse(c, http.StatusInternalServerError, "查询背包空间失败")
+				return
+			}
+			if free < int(displacedCount) {
+				tx.Rollback()
+				utils.ErrorResponse(c, http.StatusBadRequest, "背包已满，无法放入卸下的装备")
+				return
+			}
+		}
+	}
+
+	result := displaced.Updates(map[string]interface{}{
+		"is_equipped": false,
+		"position":    models.PositionBackpack,
+	})
 
 	// 只有在数据库操作发生错误时才回滚，没有匹配记录不是错误
 	if result.Error != nil {
@@ -404,6 +274,9 @@ func (ec *EquipmentController) EquipItem(c *gin.Context) {
 	// 提交事务
 	tx.Commit()
 
+	// 战力为派生数据，刷新失败不影响本次操作
+	stats.Refresh(ec.db, userID.(uint))
+
 	// 重新加载装备信息
 	ec.db.Preload("EquipmentTemplate").Preload("AdditionalAttrs").First(&userEquipment, equipmentID)
 
@@ -457,10 +330,23 @@ func (ec *EquipmentController) UnequipItem(c *gin.Context) {
 		return
 	}
 
-	// 4. 更新装备为未穿戴状态，并更新位置为"backpack"
+	// 4. 卸下的装备放回背包，需要背包有空位
+	free, err := services.FreeSlots(tx, userID.(uint), models.PositionBackpack)
+	if err != nil {
+		tx.Rollback()
+		utils.ErrorResponse(c, http.StatusInternalServerError, "查询背包空间失败")
+		return
+	}
+	if free <= 0 {
+		tx.Rollback()
+		utils.ErrorResponse(c, http.StatusBadRequest, "背包已满，请整理背包后再卸下")
+		return
+	}
+
+	// 5. 更新装备为未穿戴状态，并更新位置为背包
 	if err := tx.Model(&userEquipment).Updates(map[string]interface{}{
 		"is_equipped": false,
-		"position":    "backpack",
+		"position":    models.PositionBackpack,
 	}).Error; err != nil {
 		tx.Rollback()
 		utils.ErrorResponse(c, http.StatusInternalServerError, "卸下装备失败")
@@ -470,6 +356,9 @@ func (ec *EquipmentController) UnequipItem(c *gin.Context) {
 	// 提交事务
 	tx.Commit()
 
+	// 战力为派生数据，刷新失败不影响本次操作
+	stats.Refresh(ec.db, userID.(uint))
+
 	// 重新加载装备信息
 	ec.db.Preload("EquipmentTemplate").Preload("AdditionalAttrs").First(&userEquipment, equipmentID)
 
@@ -483,6 +372,7 @@ func (ec *EquipmentController) UnequipItem(c *gin.Context) {
 }
 
 // GetMyEquipment 获取我的装备，返回穿戴中和未穿戴的装备数组
+// 可选参数：is_locked、is_favorite 按标记筛选；sort 排序（favorite 收藏优先、locked 锁定优先、rarity 品级从高到低、enhance 强化等级从高到低），默认按获得顺序
 func (ec *EquipmentController) GetMyEquipment(c *gin.Context) {
 	// 从context获取用户ID
 	userID, exists := c.Get("userID")
@@ -491,15 +381,60 @@ func (ec *EquipmentController) GetMyEquipment(c *gin.Context) {
 		return
 	}
 
+	query := ec.db.Where("user_id = ?", userID.(uint))
+	for _, flag := range []string{"is_locked", "is_favorite"} {
+		value := c.Query(flag)
+		if value == "" {
+			continue
+		}
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, "无效的"+flag+"参数")
+			return
+		}
+		query = query.Where(flag+" = ?", b)
+	}
+
+	sortBy := c.Query("sort")
+	if sortBy != "" && sortBy != "favorite" && sortBy != "locked" && sortBy != "rarity" && sortBy != "enhance" {
+		utils.ErrorResponse(c, http.StatusBadRequest, "无效的sort参数")
+		return
+	}
+
 	// 查询用户的所有装备
 	var allEquipments []models.UserEquipment
-	if err := ec.db.Where("user_id = ?", userID.(uint)).
+	if err := query.
 		Preload("EquipmentTemplate").
 		Preload("AdditionalAttrs").
+		Order("id").
 		Find(&allEquipments).Error; err != nil {
 		utils.ErrorResponse(c, http.StatusInternalServerError, "查询装备失败: "+err.Error())
 		return
 	}
+	sortEquipments(allEquipments, sortBy)
+
+	// 套装进度按当前穿戴的装备计算（不受筛选条件影响）
+	var equippedTemplateIDs []uint
+	if err := ec.db.Model(&models.UserEquipment{}).Where("user_id = ? AND is_equipped = ?", userID.(uint), true).
+		Pluck("equipment_id", &equippedTemplateIDs).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "查询装备失败: "+err.Error())
+		return
+	}
+	templateIDs := make([]uint, 0, len(allEquipments))
+	for _, eq := range allEquipments {
+		templateIDs = append(templateIDs, eq.EquipmentID)
+	}
+	sets, err := stats.LoadSets(ec.db, templateIDs)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "查询套装失败: "+err.Error())
+		return
+	}
+	setByTemplate := make(map[uint]stats.SetStatus)
+	for i, status := range stats.SetProgress(sets, equippedTemplateIDs) {
+		for _, item := range sets[i].Items {
+			setByTemplate[item.EquipmentTemplateID] = status
+		}
+	}
 
 	// 定义装备响应结构
 	type EquipmentResponse struct {
@@ -508,7 +443,12 @@ func (ec *EquipmentController) GetMyEquipment(c *gin.Context) {
 		ImageURL        string  `json:"image_url"`
 		Rarity          string  `json:"rarity"`
 		Slot            string  `json:"slot"`
-		EnhanceLevel    int     `json:"enhance_level"` // 强化等级
+		EnhanceLevel    int     `json:"enhance_level"`  // 强化等级
+		IsLocked        bool    `json:"is_locked"`      // 是否锁定
+		IsFavorite      bool    `json:"is_favorite"`    // 是否收藏
+		HiddenAffixes   int     `json:"hidden_affixes"` // 未鉴定的隐藏词条数
+		IdentifyCount   int     `json:"identify_count"` // 已鉴定次数
+		Set             gin.H   `json:"set"`            // 所属套装及穿戴进度，不属于套装时为 null
 		BaseAttributes  gin.H   `json:"base_attributes"`
 		AdditionalAttrs []gin.H `json:"additional_attrs"`
 		RareAttrs       []gin.H `json:"rare_attrs"`
@@ -534,24 +474,10 @@ func (ec *EquipmentController) GetMyEquipment(c *gin.Context) {
 		"boots":  "鞋子",
 	}
 
-	// 附加属性映射
-	additionalAttrMap := map[string]string{
-		// 普通属性映射
-		"attack_bonus":     "攻击力加成",
-		"critical_rate":    "暴击率",
-		"drain":            "吸血",
-		"damage_reduction": "减伤",
-		"recovery":         "生命恢复",
-		"attack_fixed":     "攻击力",
-		"hp_bonus":         "血量加成",
-		// 稀有属性映射
-		"sloth":    "最大HP提升",
-		"envy":     "暴击伤害提升",
-		"gluttony": "攻速提升",
-		"greed":    "秒杀几率",
-		"lust":     "生命恢复",
-		"wrath":    "暴击率",
-		"pride":    "攻击力提升",
+	registry, err := services.GetAffixRegistry(ec.db)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "加载词条配置失败: "+err.Error())
+		return
 	}
 
 	// 分类装备
@@ -561,11 +487,12 @@ func (ec *EquipmentController) GetMyEquipment(c *gin.Context) {
 	for _, eq := range allEquipments {
 		// 转换基础属性为中文
 		baseAttrs := gin.H{}
+		// 生命值和攻击力为强化后的数值
 		if eq.EquipmentTemplate.HP > 0 {
-			baseAttrs["生命值"] = eq.EquipmentTemplate.HP
+			baseAttrs["生命值"] = stats.EnhancedHP(&eq)
 		}
 		if eq.EquipmentTemplate.Attack > 0 {
-			baseAttrs["攻击力"] = eq.EquipmentTemplate.Attack
+			baseAttrs["攻击力"] = stats.EnhancedAttack(&eq)
 		}
 		if eq.EquipmentTemplate.AttackSpeed != 1.0 {
 			baseAttrs["攻击速度"] = eq.EquipmentTemplate.AttackSpeed
@@ -599,45 +526,19 @@ func (ec *EquipmentController) GetMyEquipment(c *gin.Context) {
 		addAttrs := []gin.H{}
 		rareAttrs := []gin.H{}
 
-		for _, attr := range eq.AdditionalAttrs {
-			// 清理属性值，去除百分号和其他非数字字符
-			cleanValue := attr.AttrValue
-			if strings.Contains(cleanValue, "%") {
-				cleanValue = strings.ReplaceAll(cleanValue, "%", "")
-			}
-			if strings.Contains(cleanValue, "秒杀") {
-				cleanValue = strings.ReplaceAll(cleanValue, "秒杀", "")
-			}
-
-			// 将清理后的字符串转换为float64
-			attrValue, err := strconv.ParseFloat(cleanValue, 64)
-			if err != nil {
-				attrValue = 0.0
-			}
-
-			// 获取属性名称
-			attrName := additionalAttrMap[attr.AttrType]
-
-			// 格式化属性值
-			var formattedValue string
-			if attr.AttrType == "greed" { // 特殊处理秒杀属性
-				formattedValue = "秒杀1%"
-			} else if attr.AttrType == "lust" || attr.AttrType == "attack_fixed" { // 非百分比属性
-				formattedValue = strconv.Itoa(int(attrValue))
-			} else { // 百分比属性
-				formattedValue = fmt.Sprintf("%.1f%%", attrValue)
-			}
+		for i := range eq.AdditionalAttrs {
+			attr := &eq.AdditionalAttrs[i]
+			formattedValue := registry.FormatValue(attr)
 
-			if attr.AttrName != "" {
-				// 稀有属性：格式化为"傲慢·最大HP提升15%"这样
-				fullName := fmt.Sprintf("%s·%s %s", attr.AttrName, attrName, formattedValue)
+			if registry.IsRare(attr.AttrType) {
+				// 稀有属性：格式化为"傲慢·最大HP提升 15.0%"这样
 				rareAttrs = append(rareAttrs, gin.H{
-					"name": fullName,
+					"name": fmt.Sprintf("%s %s", registry.DisplayName(attr.AttrType), formattedValue),
 				})
 			} else {
-				// 普通附加属性：只显示"最大HP提升15%"
+				// 普通附加属性：只显示"攻击力加成 2.5%"
 				addAttrs = append(addAttrs, gin.H{
-					"name":  attrName,
+					"name":  registry.DisplayName(attr.AttrType),
 					"value": formattedValue,
 				})
 			}
@@ -651,11 +552,19 @@ func (ec *EquipmentController) GetMyEquipment(c *gin.Context) {
 			Rarity:          rarityMap[eq.EquipmentTemplate.Level],
 			Slot:            slotMap[eq.EquipmentTemplate.Slot],
 			EnhanceLevel:    eq.EnhanceLevel, // 设置强化等级
+			IsLocked:        eq.IsLocked,
+			IsFavorite:      eq.IsFavorite,
+			HiddenAffixes:   eq.HiddenAffixes,
+			IdentifyCount:   eq.IdentifyCount,
 			BaseAttributes:  baseAttrs,
 			AdditionalAttrs: addAttrs,
 			RareAttrs:       rareAttrs,
 		}
 
+		if status, ok := setByTemplate[eq.EquipmentID]; ok {
+			equipmentResp.Set = setProgressResponse(registry, status)
+		}
+
 		// 分类到穿戴或未穿戴
 		if eq.IsEquipped {
 			equipped = append(equipped, equipmentResp)
@@ -672,3 +581,149 @@ func (ec *EquipmentController) GetMyEquipment(c *gin.Context) {
 
 	utils.SuccessResponse(c, response)
 }
+
+// setProgressResponse 套装进度的展示结构，加成描述如“2件：攻击力加成 5%”
+func setProgressResponse(registry *services.AffixRegistry, status stats.SetStatus) gin.H {
+	bonuses := make([]gin.H, 0, len(status.Bonuses))
+	for _, bonus := range status.Bonuses {
+		attr := models.EquipmentAdditionalAttr{AttrType: bonus.AttrType, Value: bonus.Value}
+		if def, ok := registry.Definition(bonus.AttrType); ok {
+			attr.IsPercent = def.IsPercent
+		}
+		bonuses = append(bonuses, gin.H{
+			"pieces": bonus.Pieces,
+			"name":   fmt.Sprintf("%d件：%s %s", bonus.Pieces, registry.DisplayName(bonus.AttrType), registry.FormatValue(&attr)),
+			"active": bonus.Active,
+		})
+	}
+	return gin.H{
+		"id":       status.SetID,
+		"name":     status.Name,
+		"equipped": status.Equipped,
+		"total":    status.Total,
+		"bonuses":  bonuses,
+	}
+}
+
+// sortEquipments 按排序方式重新排列装备，相同时保持原顺序（获得顺序）
+func sortEquipments(equipments []models.UserEquipment, sortBy string) {
+	var less func(a, b *models.UserEquipment) bool
+	switch sortBy {
+	case "favorite":
+		less = func(a, b *models.UserEquipment) bool { return a.IsFavorite && !b.IsFavorite }
+	case "locked":
+		less = func(a, b *models.UserEquipment) bool { return a.IsLocked && !b.IsLocked }
+	case "rarity":
+		less = func(a, b *models.UserEquipment) bool { return a.EquipmentTemplate.Level > b.EquipmentTemplate.Level }
+	case "enhance":
+		less = func(a, b *models.UserEquipment) bool { return a.EnhanceLevel > b.EnhanceLevel }
+	default:
+		return
+	}
+	sort.SliceStable(equipments, func(i, j int) bool { return less(&equipments[i], &equipments[j]) })
+}
+
+// LockEquipment 锁定/解锁装备，锁定的装备不能被回收、融合或出售
+func (ec *EquipmentController) LockEquipment(c *gin.Context) {
+	var request struct {
+		IsLocked *bool `json:"is_locked" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "参数错误: "+err.Error())
+		return
+	}
+	ec.setEquipmentFlag(c, "is_locked", *request.IsLocked)
+}
+
+// FavoriteEquipment 收藏/取消收藏装备，收藏的装备不能被批量回收
+func (ec *EquipmentController) FavoriteEquipment(c *gin.Context) {
+	var request struct {
+		IsFavorite *bool `json:"is_favorite" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "参数错误: "+err.Error())
+		return
+	}
+	ec.setEquipmentFlag(c, "is_favorite", *request.IsFavorite)
+}
+
+// setEquipmentFlag 修改当前用户某件装备的布尔标记
+func (ec *EquipmentController) setEquipmentFlag(c *gin.Context, column string, value bool) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "用户未登录")
+		return
+	}
+
+	equipmentID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "无效的装备ID")
+		return
+	}
+
+	result := ec.db.Model(&models.UserEquipment{}).
+		Where("id = ? AND user_id = ?", equipmentID, userID.(uint)).
+		Update(column, value)
+	if result.Error != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "修改装备失败: "+result.Error.Error())
+		return
+	}
+	if result.RowsAffected == 0 {
+		utils.ErrorResponse(c, http.StatusNotFound, "装备不存在或不属于该用户")
+		return
+	}
+
+	utils.SuccessResponse(c, gin.H{
+		"id":   equipmentID,
+		column: value,
+	})
+}
+
+// IdentifyEquipment 鉴定装备，揭示一条隐藏词条（消耗金币，或传 item_id 用宝物代替）
+func (ec *EquipmentController) IdentifyEquipment(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "用户未登录")
+		return
+	}
+
+	equipmentID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "无效的装备ID")
+		return
+	}
+
+	var request services.IdentifyRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&request); err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, "参数错误: "+err.Error())
+			return
+		}
+	}
+
+	result, err := services.NewIdentifyService(ec.db).Identify(userID.(uint), uint(equipmentID), request)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrEquipmentNotFound):
+			utils.ErrorResponse(c, http.StatusNotFound, err.Error())
+		case errors.Is(err, services.ErrInsufficientGold):
+			utils.ErrorResponse(c, http.StatusBadRequest, "金币不足")
+		case errors.Is(err, services.ErrIdentifyNothing),
+			errors.Is(err, services.ErrIdentifyAffixFull),
+			errors.Is(err, services.ErrIdentifyMaterial):
+			utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		default:
+			utils.ErrorResponse(c, http.StatusInternalServerError, "鉴定失败")
+		}
+		return
+	}
+
+	message := "鉴定完成，未发现新词条"
+	if result.Revealed {
+		message = "鉴定成功，发现新词条"
+	}
+	utils.SuccessResponse(c, gin.H{
+		"message": message,
+		"result":  result,
+	})
+}
@@ -0,0 +1,210 @@
+package controllers
+
+import (
+	"errors"
+	"ggo/models"
+	"ggo/services"
+	"ggo/utils"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// EquipmentSetController 套装管理（管理后台）
+type EquipmentSetController struct {
+	db *gorm.DB
+}
+
+// NewEquipmentSetController 创建套装控制器实例
+func NewEquipmentSetController(db *gorm.DB) *EquipmentSetController {
+	return &EquipmentSetController{db: db}
+}
+
+// equipmentSetRequest 创建/修改套装的请求，template_ids 和 bonuses 会整体替换
+type equipmentSetRequest struct {
+	Name        string                     `json:"name" binding:"required,max=50"`
+	Description string                     `json:"description" binding:"max=500"`
+	IsActive    *bool                      `json:"is_active"`
+	TemplateIDs []uint                     `json:"template_ids"` // 套装包含的装备模板ID
+	Bonuses     []models.EquipmentSetBonus `json:"bonuses"`
+}
+
+func (req *equipmentSetRequest) toModel() models.EquipmentSet {
+	set := models.EquipmentSet{
+		Name:        req.Name,
+		Description: req.Description,
+		IsActive:    true,
+	}
+	if req.IsActive != nil {
+		set.IsActive = *req.IsActive
+	}
+	for _, templateID := range req.TemplateIDs {
+		set.Items = append(set.Items, models.EquipmentSetItem{EquipmentTemplateID: templateID})
+	}
+	for _, bonus := range req.Bonuses {
+		bonus.ID = 0
+		bonus.EquipmentSetID = 0
+		set.Bonuses = append(set.Bonuses, bonus)
+	}
+	return set
+}
+
+// GetEquipmentSets 获取套装列表
+func (sc *EquipmentSetController) GetEquipmentSets(c *gin.Context) {
+	var sets []models.EquipmentSet
+	if err := sc.db.Preload("Items").
+		Preload("Bonuses", func(db *gorm.DB) *gorm.DB { return db.Order("pieces, id") }).
+		Order("id").Find(&sets).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "查询套装失败: "+err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, sets)
+}
+
+// CreateEquipmentSet 创建套装
+func (sc *EquipmentSetController) CreateEquipmentSet(c *gin.Context) {
+	var req equipmentSetRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "参数错误: "+err.Error())
+		return
+	}
+
+	set := req.toModel()
+	if err := services.ValidateEquipmentSet(sc.db, &set); err != nil {
+		sc.respondValidateError(c, err)
+		return
+	}
+
+	err := sc.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&set).Error; err != nil {
+			return err
+		}
+		// is_active 有默认值，创建时传 false 会被忽略，需单独更新
+		if !set.IsActive {
+			return tx.Model(&set).Update("is_active", false).Error
+		}
+		return nil
+	})
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "创建套装失败: "+err.Error())
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"data":    set,
+	})
+}
+
+// UpdateEquipmentSet 修改套装，模板和加成整体替换
+func (sc *EquipmentSetController) UpdateEquipmentSet(c *gin.Context) {
+	existing, ok := sc.loadSet(c)
+	if !ok {
+		return
+	}
+
+	var req equipmentSetRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "参数错误: "+err.Error())
+		return
+	}
+
+	set := req.toModel()
+	set.ID = existing.ID
+	if req.IsActive == nil {
+		set.IsActive = existing.IsActive
+	}
+	if err := services.ValidateEquipmentSet(sc.db, &set); err != nil {
+		sc.respondValidateError(c, err)
+		return
+	}
+
+	err := sc.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.EquipmentSet{}).Where("id = ?", set.ID).Updates(map[string]interface{}{
+			"name":        set.Name,
+			"description": set.Description,
+			"is_active":   set.IsActive,
+		}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("equipment_set_id = ?", set.ID).Delete(&models.EquipmentSetItem{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("equipment_set_id = ?", set.ID).Delete(&models.EquipmentSetBonus{}).Error; err != nil {
+			return err
+		}
+		for i := range set.Items {
+			set.Items[i].EquipmentSetID = set.ID
+		}
+		if err := tx.Create(&set.Items).Error; err != nil {
+			return err
+		}
+		for i := range set.Bonuses {
+			set.Bonuses[i].EquipmentSetID = set.ID
+		}
+		if len(set.Bonuses) > 0 {
+			return tx.Create(&set.Bonuses).Error
+		}
+		return nil
+	})
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "修改套装失败: "+err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, set)
+}
+
+// DeleteEquipmentSet 删除套装及其模板和加成
+func (sc *EquipmentSetController) DeleteEquipmentSet(c *gin.Context) {
+	set, ok := sc.loadSet(c)
+	if !ok {
+		return
+	}
+
+	err := sc.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("equipment_set_id = ?", set.ID).Delete(&models.EquipmentSetItem{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("equipment_set_id = ?", set.ID).Delete(&models.EquipmentSetBonus{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.EquipmentSet{}, set.ID).Error
+	})
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "删除套装失败: "+err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, gin.H{"message": "套装删除成功"})
+}
+
+func (sc *EquipmentSetController) respondValidateError(c *gin.Context, err error) {
+	if errors.Is(err, services.ErrEquipmentSetInvalid) {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	utils.ErrorResponse(c, http.StatusInternalServerError, "校验套装失败: "+err.Error())
+}
+
+func (sc *EquipmentSetController) loadSet(c *gin.Context) (*models.EquipmentSet, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "无效的套装ID")
+		return nil, false
+	}
+
+	var set models.EquipmentSet
+	if err := sc.db.First(&set, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			utils.ErrorResponse(c, http.StatusNotFound, "套装不存在")
+		} else {
+			utils.ErrorResponse(c, http.StatusInternalServerError, err.Error())
+		}
+		return nil, false
+	}
+	return &set, true
+}
@@ -0,0 +1,133 @@
+package controllers
+
+import (
+	"errors"
+	"ggo/services"
+	"ggo/utils"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// InventoryController 背包/仓库容量、扩充和物品移动
+type InventoryController struct {
+	db *gorm.DB
+}
+
+// NewInventoryController 创建背包控制器实例
+func NewInventoryController(db *gorm.DB) *InventoryController {
+	return &InventoryController{db: db}
+}
+
+// GetInventory 获取背包和仓库的容量与已用格数
+func (ic *InventoryController) GetInventory(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "用户未登录")
+		return
+	}
+
+	spaces, err := services.NewInventoryService(ic.db).Spaces(userID.(uint))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "查询背包容量失败: "+err.Error())
+		return
+	}
+	utils.SuccessResponse(c, gin.H{
+		"spaces":         spaces,
+		"expand_slots":   services.InventoryExpandSlots,
+		"expand_diamond": services.InventoryExpandDiamond,
+		"max_capacity":   services.InventoryMaxCapacity,
+	})
+}
+
+// ExpandInventory 消耗钻石扩充背包或仓库
+func (ic *InventoryController) ExpandInventory(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "用户未登录")
+		return
+	}
+
+	var request struct {
+		Position string `json:"position" binding:"required"` // backpack, warehouse
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "参数错误: "+err.Error())
+		return
+	}
+
+	space, currentDiamond, err := services.NewInventoryService(ic.db).Expand(userID.(uint), request.Position)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInsufficientDiamond):
+			utils.ErrorResponse(c, http.StatusBadRequest, "钻石不足")
+		case errors.Is(err, services.ErrInventoryPosition),
+			errors.Is(err, services.ErrInventoryMaxCapacity):
+			utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		default:
+			utils.ErrorResponse(c, http.StatusInternalServerError, "扩充失败: "+err.Error())
+		}
+		return
+	}
+
+	utils.SuccessResponse(c, gin.H{
+		"message":         "扩充成功",
+		"space":           space,
+		"current_diamond": currentDiamond,
+	})
+}
+
+// MoveItem 在背包和仓库之间移动单个物品或装备
+func (ic *InventoryController) MoveItem(c *gin.Context) {
+	var move services.InventoryMove
+	if err := c.ShouldBindJSON(&move); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "参数错误: "+err.Error())
+		return
+	}
+	ic.move(c, []services.InventoryMove{move})
+}
+
+// BatchMoveItems 批量移动物品或装备，任意一个失败时全部不移动
+func (ic *InventoryController) BatchMoveItems(c *gin.Context) {
+	var request struct {
+		Moves []services.InventoryMove `json:"moves" binding:"required,min=1,dive"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "参数错误: "+err.Error())
+		return
+	}
+	ic.move(c, request.Moves)
+}
+
+func (ic *InventoryController) move(c *gin.Context, moves []services.InventoryMove) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "用户未登录")
+		return
+	}
+
+	results, err := services.NewInventoryService(ic.db).Move(userID.(uint), moves)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrEquipmentNotFound),
+			errors.Is(err, services.ErrMyItemNotEnough):
+			utils.ErrorResponse(c, http.StatusNotFound, err.Error())
+		case errors.Is(err, services.ErrInventoryFull),
+			errors.Is(err, services.ErrInventoryStackFull),
+			errors.Is(err, services.ErrInventoryPosition),
+			errors.Is(err, services.ErrInventoryMoveInvalid),
+			errors.Is(err, services.ErrInventoryMoveTooMany),
+			errors.Is(err, services.ErrEquipmentEquipped):
+			utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		default:
+			utils.ErrorResponse(c, http.StatusInternalServerError, "移动失败: "+err.Error())
+		}
+		return
+	}
+
+	utils.SuccessResponse(c, gin.H{
+		"message": "移动成功",
+		"results": results,
+	})
+}
@@ -54,9 +54,10 @@ func (lc *LeaderboardController) GetLeaderboard(c *gin.Context) {
 		"gold":    true,
 		"chapter": true,
 		"damage":  true,
+		"power":   true,
 	}
 	if !validTypes[rankType] {
-		utils.ErrorResponse(c, http.StatusBadRequest, "无效的type参数，支持: gold, chapter, damage")
+		utils.ErrorResponse(c, http.StatusBadRequest, "无效的type参数，支持: gold, chapter, damage, power")
 		return
 	}
 
@@ -110,6 +111,10 @@ func (lc *LeaderboardController) GetLeaderboard(c *gin.Context) {
 		// 只查询今天的伤害数据
 		querySQL = "SELECT user_id, json_data->>'name' as name, CAST(json_data#>>'{boss_last_result,damage}' AS INTEGER) as value FROM archives WHERE area = ? AND json_data#>>'{boss_last_result,damage}' IS NOT NULL AND json_data#>>'{boss_last_result,damage}' ~ '^[0-9]+$' AND CAST(json_data#>>'{boss_last_result,updated_at}' AS BIGINT) >= ? ORDER BY CAST(json_data#>>'{boss_last_result,damage}' AS INTEGER) DESC LIMIT 10"
 		queryParams = []interface{}{area, todayStartTimestamp}
+	case "power":
+		// 战力由 stats 服务按区服计算后保存在 archives.combat_power
+		querySQL = "SELECT a.user_id, a.json_data->>'name' as name, a.combat_power as value FROM archives a WHERE a.area = ? ORDER BY a.combat_power DESC LIMIT 10"
+		queryParams = []interface{}{area}
 	}
 
 	// 执行原生SQL查询
@@ -175,9 +180,10 @@ func (lc *LeaderboardController) GetPlayerRank(c *gin.Context) {
 		"gold":    true,
 		"chapter": true,
 		"damage":  true,
+		"power":   true,
 	}
 	if !validTypes[rankType] {
-		utils.ErrorResponse(c, http.StatusBadRequest, "无效的type参数，支持: gold, chapter, damage")
+		utils.ErrorResponse(c, http.StatusBadRequest, "无效的type参数，支持: gold, chapter, damage, power")
 		return
 	}
 
@@ -224,6 +230,9 @@ func (lc *LeaderboardController) GetPlayerRank(c *gin.Context) {
 	case "damage":
 		querySQL = "SELECT CAST(json_data#>>'{boss_last_result,damage}' AS INTEGER) FROM archives WHERE user_id = ? AND area = ? AND json_data#>>'{boss_last_result,damage}' IS NOT NULL AND json_data#>>'{boss_last_result,damage}' ~ '^[0-9]+$' AND CAST(json_data#>>'{boss_last_result,updated_at}' AS BIGINT) >= ? ORDER BY CAST(json_data#>>'{boss_last_result,damage}' AS INTEGER) DESC LIMIT 1"
 		countSQL = "SELECT COUNT(*) FROM archives WHERE area = ? AND CAST(json_data#>>'{boss_last_result,damage}' AS INTEGER) > (SELECT COALESCE(CAST(json_data#>>'{boss_last_result,damage}' AS INTEGER), 0) FROM archives WHERE user_id = ? AND area = ? AND json_data#>>'{boss_last_result,damage}' ~ '^[0-9]+$' AND CAST(json_data#>>'{boss_last_result,updated_at}' AS BIGINT) >= ?) AND json_data#>>'{boss_last_result,damage}' ~ '^[0-9]+$' AND CAST(json_data#>>'{boss_last_result,updated_at}' AS BIGINT) >= ?"
+	case "power":
+		querySQL = "SELECT a.combat_power FROM archives a WHERE a.user_id = ? AND a.area = ? LIMIT 1"
+		countSQL = "SELECT COUNT(*) FROM archives a WHERE a.area = ? AND a.combat_power > (SELECT a2.combat_power FROM archives a2 WHERE a2.user_id = ? AND a2.area = ?)"
 	}
 
 	// 获取玩家数值
@@ -0,0 +1,148 @@
+package controllers
+
+import (
+	"errors"
+	"ggo/services"
+	"ggo/utils"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// LoadoutController 装备方案：保存多套装备和皮肤，一键整套切换
+type LoadoutController struct {
+	db *gorm.DB
+}
+
+// NewLoadoutController 创建装备方案控制器实例
+func NewLoadoutController(db *gorm.DB) *LoadoutController {
+	return &LoadoutController{db: db}
+}
+
+// GetLoadouts 获取我的装备方案，已失效的装备和皮肤会被标记
+func (lc *LoadoutController) GetLoadouts(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "用户未登录")
+		return
+	}
+
+	loadouts, err := services.NewLoadoutService(lc.db).List(userID.(uint))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "查询装备方案失败: "+err.Error())
+		return
+	}
+	utils.SuccessResponse(c, loadouts)
+}
+
+// CreateLoadout 保存新的装备方案
+func (lc *LoadoutController) CreateLoadout(c *gin.Context) {
+	lc.save(c, 0)
+}
+
+// UpdateLoadout 覆盖装备方案
+func (lc *LoadoutController) UpdateLoadout(c *gin.Context) {
+	loadoutID, ok := parseLoadoutID(c)
+	if !ok {
+		return
+	}
+	lc.save(c, loadoutID)
+}
+
+func (lc *LoadoutController) save(c *gin.Context, loadoutID uint) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "用户未登录")
+		return
+	}
+
+	var request services.LoadoutRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "参数错误: "+err.Error())
+		return
+	}
+
+	loadout, err := services.NewLoadoutService(lc.db).Save(userID.(uint), loadoutID, request)
+	if err != nil {
+		respondLoadoutError(c, err, "保存装备方案失败")
+		return
+	}
+	utils.SuccessResponse(c, loadout)
+}
+
+// DeleteLoadout 删除装备方案
+func (lc *LoadoutController) DeleteLoadout(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "用户未登录")
+		return
+	}
+	loadoutID, ok := parseLoadoutID(c)
+	if !ok {
+		return
+	}
+
+	if err := services.NewLoadoutService(lc.db).Delete(userID.(uint), loadoutID); err != nil {
+		respondLoadoutError(c, err, "删除装备方案失败")
+		return
+	}
+	utils.SuccessResponse(c, gin.H{"message": "装备方案删除成功"})
+}
+
+// ApplyLoadout 整套切换装备方案；已失效的部位在 missing 中列出并保持原有穿戴
+func (lc *LoadoutController) ApplyLoadout(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "用户未登录")
+		return
+	}
+	loadoutID, ok := parseLoadoutID(c)
+	if !ok {
+		return
+	}
+
+	result, err := services.NewLoadoutService(lc.db).Apply(userID.(uint), loadoutID)
+	if err != nil {
+		respondLoadoutError(c, err, "切换装备方案失败")
+		return
+	}
+
+	message := "装备方案切换成功"
+	if len(result.Missing) > 0 || result.SkinMissing {
+		message = "装备方案部分装备或皮肤已失效，其余已切换"
+	}
+	utils.SuccessResponse(c, gin.H{
+		"message":      message,
+		"loadout":      result.Loadout,
+		"equipped":     result.Equipped,
+		"missing":      result.Missing,
+		"skin_missing": result.SkinMissing,
+	})
+}
+
+func parseLoadoutID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "无效的装备方案ID")
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func respondLoadoutError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, services.ErrLoadoutNotFound),
+		errors.Is(err, services.ErrEquipmentNotFound):
+		utils.ErrorResponse(c, http.StatusNotFound, err.Error())
+	case errors.Is(err, services.ErrLoadoutNameTaken):
+		utils.ErrorResponse(c, http.StatusConflict, err.Error())
+	case errors.Is(err, services.ErrLoadoutInvalid),
+		errors.Is(err, services.ErrLoadoutTooMany),
+		errors.Is(err, services.ErrInventoryFull):
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+	default:
+		utils.ErrorResponse(c, http.StatusInternalServerError, message+": "+err.Error())
+	}
+}
@@ -3,9 +3,12 @@ package controllers
 import (
 	"errors"
 	"ggo/models"
+	"ggo/services"
 	"ggo/utils"
+	"math/rand"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
@@ -79,8 +82,14 @@ func (mc *MailController) ClaimMail(c *gin.Context) {
 			if mail.Num <= 0 {
 				return errors.New("金币数量无效")
 			}
-			if err := tx.Model(&models.User{}).Where("id = ?", userID.(uint)).
-				Update("gold", gorm.Expr("gold + ?", mail.Num)).Error; err != nil {
+			if _, err := services.ChangeCurrency(tx, services.CurrencyChange{
+				UserID:     userID.(uint),
+				Currency:   models.CurrencyGold,
+				Delta:      mail.Num,
+				Reason:     models.CurrencyReasonMailClaim,
+				SourceType: "mail",
+				SourceID:   mail.ID,
+			}); err != nil {
 				return err
 			}
 			rewardResult = gin.H{"type": "gold", "num": mail.Num}
@@ -88,8 +97,14 @@ func (mc *MailController) ClaimMail(c *gin.Context) {
 			if mail.Num <= 0 {
 				return errors.New("钻石数量无效")
 			}
-			if err := tx.Model(&models.User{}).Where("id = ?", userID.(uint)).
-				Update("diamond", gorm.Expr("diamond + ?", mail.Num)).Error; err != nil {
+			if _, err := services.ChangeCurrency(tx, services.CurrencyChange{
+				UserID:     userID.(uint),
+				Currency:   models.CurrencyDiamond,
+				Delta:      mail.Num,
+				Reason:     models.CurrencyReasonMailClaim,
+				SourceType: "mail",
+				SourceID:   mail.ID,
+			}); err != nil {
 				return err
 			}
 			rewardResult = gin.H{"type": "diamond", "num": mail.Num}
@@ -101,12 +116,26 @@ func (mc *MailController) ClaimMail(c *gin.Context) {
 			if err := tx.First(&tpl, mail.ItemID).Error; err != nil {
 				return errors.New("装备不存在")
 			}
+			free, err := services.FreeSlots(tx, userID.(uint), models.PositionBackpack)
+			if err != nil {
+				return err
+			}
+			if free <= 0 {
+				return errors.New("背包已满，请整理背包后再领取")
+			}
+			registry, err := services.GetAffixRegistry(tx)
+			if err != nil {
+				return err
+			}
+			// 邮件发放的装备（掉落溢出、首领掉落等）与直接掉落一致，领取时按概率带隐藏词条
+			rng := rand.New(rand.NewSource(time.Now().UnixNano()))
 			userEquipment := models.UserEquipment{
-				UserID:       userID.(uint),
-				EquipmentID:  mail.ItemID,
-				IsEquipped:   false,
-				Position:     "backpack",
-				EnhanceLevel: 0,
+				UserID:        userID.(uint),
+				EquipmentID:   mail.ItemID,
+				IsEquipped:    false,
+				Position:      "backpack",
+				EnhanceLevel:  0,
+				HiddenAffixes: registry.RollHiddenAffixes(rng, tpl.Level),
 			}
 			if err := tx.Create(&userEquipment).Error; err != nil {
 				return err
@@ -115,6 +144,16 @@ func (mc *MailController) ClaimMail(c *gin.Context) {
 				return err
 			}
 			rewardResult = gin.H{"type": "equipment", "equipment": userEquipment}
+		case models.MailItemTypeMarketEquipment:
+			// 交易行退回的装备：领取托管中的原装备（保留强化和词条）
+			equipment, err := services.ClaimMarketEquipment(tx, userID.(uint), mail.ItemID)
+			if errors.Is(err, services.ErrInventoryFull) {
+				return errors.New("背包已满，请整理背包后再领取")
+			}
+			if err != nil {
+				return err
+			}
+			rewardResult = gin.H{"type": "equipment", "equipment": equipment}
 		case "treasures":
 			if mail.ItemID <= 0 {
 				return errors.New("宝物ID无效")
@@ -127,33 +166,24 @@ func (mc *MailController) ClaimMail(c *gin.Context) {
 				return errors.New("宝物不存在")
 			}
 
-			var myItem models.MyItem
-			result := tx.Where("user_id = ? AND item_type = ? AND item_id = ?", userID.(uint), "treasure", mail.ItemID).First(&myItem)
-			if result.Error != nil {
-				if !errors.Is(result.Error, gorm.ErrRecordNotFound) {
-					return result.Error
-				}
-				myItem = models.MyItem{
-					UserID:    userID.(uint),
-					ItemID:    mail.ItemID,
-					ItemType:  "treasure",
-					Position:  "backpack",
-					Quantity:  mail.Num,
-					IsActive:  true,
-					SellPrice: 0,
-				}
-				if err := tx.Create(&myItem).Error; err != nil {
-					return err
-				}
-			} else {
-				if err := tx.Model(&models.MyItem{}).Where("id = ?", myItem.ID).
-					Update("quantity", gorm.Expr("quantity + ?", mail.Num)).Error; err != nil {
-					return err
-				}
-				myItem.Quantity += mail.Num
+			if err := services.GrantMyItem(tx, userID.(uint), models.MyItemTypeTreasure, mail.ItemID, mail.Num); err != nil {
+				return err
 			}
 
 			rewardResult = gin.H{"type": "treasures", "treasure_id": mail.ItemID, "num": mail.Num}
+		case "consumables":
+			if mail.ItemID <= 0 || mail.Num <= 0 {
+				return errors.New("消耗品ID或数量无效")
+			}
+			var consumable models.Consumable
+			if err := tx.First(&consumable, mail.ItemID).Error; err != nil {
+				return errors.New("消耗品不存在")
+			}
+			if err := services.GrantMyItem(tx, userID.(uint), models.MyItemTypeConsumable, mail.ItemID, mail.Num); err != nil {
+				return err
+			}
+
+			rewardResult = gin.H{"type": "consumables", "consumable_id": mail.ItemID, "num": mail.Num}
 		default:
 			return errors.New("未知物品类型")
 		}
@@ -203,7 +233,7 @@ func (mc *MailController) SendMail(c *gin.Context) {
 
 	switch req.Type {
 	case "gold", "diamond":
-	case "equipment", "treasures":
+	case "equipment", "treasures", "consumables":
 		if req.ItemID == 0 {
 			utils.ErrorResponse(c, http.StatusBadRequest, "item_id必填")
 			return
@@ -277,8 +307,12 @@ const mailSendHTML = `<!doctype html>
 
   <div class="row">
     <div class="col">
-      <label>管理员Token（固定：1108）</label>
-      <input id="token" value="1108" placeholder="1108"/>
+      <label>管理员账号</label>
+      <input id="adminUsername" placeholder="后台账号"/>
+      <label>管理员密码</label>
+      <input id="adminPassword" type="password" placeholder="后台密码"/>
+      <button id="login">登录</button>
+      <div class="hint" id="loginState">未登录</div>
       <div class="hint">点击“加载用户”会调用 /api/v1/admin/users</div>
       <button id="loadUsers">加载用户</button>
       <label>选择用户（可多选）</label>
@@ -326,10 +360,16 @@ const mailSendHTML = `<!doctype html>
 
   <script>
     function authHeader() {
-      const t = document.getElementById('token').value.trim();
-      return t;
+      const t = sessionStorage.getItem('adminToken') || '';
+      return 'Bearer ' + t;
     }
 
+    function showLoginState() {
+      const name = sessionStorage.getItem('adminName');
+      document.getElementById('loginState').textContent = name ? ('已登录：' + name) : '未登录';
+    }
+    showLoginState();
+
     function setResult(obj) {
       document.getElementById('result').textContent = typeof obj === 'string' ? obj : JSON.stringify(obj, null, 2);
     }
@@ -350,6 +390,32 @@ const mailSendHTML = `<!doctype html>
       return raw.split(',').map(s => parseInt(s.trim(), 10)).filter(n => !Number.isNaN(n) && n >= 0);
     }
 
+    document.getElementById('login').addEventListener('click', async () => {
+      setResult('登录中...');
+      try {
+        const resp = await fetch('/api/v1/admin/login', {
+          method: 'POST',
+          headers: { 'Content-Type': 'application/json' },
+          body: JSON.stringify({
+            username: document.getElementById('adminUsername').value.trim(),
+            password: document.getElementById('adminPassword').value
+          })
+        });
+        const data = await resp.json();
+        if (!resp.ok || !data.success) {
+          setResult(data);
+          return;
+        }
+        sessionStorage.setItem('adminToken', data.data.token);
+        sessionStorage.setItem('adminName', data.data.admin.username + '（' + data.data.admin.role + '）');
+        document.getElementById('adminPassword').value = '';
+        showLoginState();
+        setResult({ message: '登录成功', permissions: data.data.permissions });
+      } catch (e) {
+        setResult(String(e));
+      }
+    });
+
     document.getElementById('loadUsers').addEventListener('click', async () => {
       setResult('加载中...');
       try {
@@ -0,0 +1,207 @@
+package controllers
+
+import (
+	"errors"
+	"ggo/models"
+	"ggo/services"
+	"ggo/utils"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// MarketController 交易行：玩家之间寄售和购买物品、装备
+type MarketController struct {
+	db *gorm.DB
+}
+
+// NewMarketController 创建交易行控制器实例
+func NewMarketController(db *gorm.DB) *MarketController {
+	return &MarketController{db: db}
+}
+
+// SearchListings 搜索某个区服寄售中的物品，支持按类型、等级、部位、词条和货币筛选
+func (mc *MarketController) SearchListings(c *gin.Context) {
+	area, err := strconv.Atoi(c.DefaultQuery("area", "1"))
+	if err != nil || area <= 0 {
+		utils.ErrorResponse(c, http.StatusBadRequest, "无效的area参数")
+		return
+	}
+	level, err := strconv.Atoi(c.DefaultQuery("level", "0"))
+	if err != nil || level < 0 {
+		utils.ErrorResponse(c, http.StatusBadRequest, "无效的level参数")
+		return
+	}
+	kind := c.Query("kind")
+	if kind != "" && kind != models.MarketKindItem && kind != models.MarketKindEquipment {
+		utils.ErrorResponse(c, http.StatusBadRequest, "无效的kind参数，支持: item, equipment")
+		return
+	}
+	currency := c.Query("currency")
+	if currency != "" && currency != models.CurrencyGold && currency != models.CurrencyDiamond {
+		utils.ErrorResponse(c, http.StatusBadRequest, "无效的currency参数，支持: gold, diamond")
+		return
+	}
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page <= 0 {
+		utils.ErrorResponse(c, http.StatusBadRequest, "无效的page参数")
+		return
+	}
+	pageSize, err := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+	if err != nil || pageSize <= 0 || pageSize > 100 {
+		utils.ErrorResponse(c, http.StatusBadRequest, "无效的page_size参数")
+		return
+	}
+
+	listings, total, err := services.NewMarketService(mc.db).Search(services.MarketSearch{
+		Area:      area,
+		Kind:      kind,
+		Level:     level,
+		Slot:      c.Query("slot"),
+		AffixType: c.Query("affix_type"),
+		Currency:  currency,
+		Sort:      c.Query("sort"),
+		Page:      page,
+		PageSize:  pageSize,
+	})
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "查询交易行失败: "+err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, gin.H{
+		"total":       total,
+		"page":        page,
+		"page_size":   pageSize,
+		"fee_percent": services.MarketFeePercent,
+		"listings":    listings,
+	})
+}
+
+// GetMyListings 获取我在某个区服的寄售记录
+func (mc *MarketController) GetMyListings(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "用户未认证")
+		return
+	}
+
+	area, err := strconv.Atoi(c.DefaultQuery("area", "1"))
+	if err != nil || area <= 0 {
+		utils.ErrorResponse(c, http.StatusBadRequest, "无效的area参数")
+		return
+	}
+
+	listings, err := services.NewMarketService(mc.db).MyListings(userID.(uint), area, c.Query("status"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "查询寄售记录失败: "+err.Error())
+		return
+	}
+	utils.SuccessResponse(c, listings)
+}
+
+// CreateListing 上架物品或装备
+func (mc *MarketController) CreateListing(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "用户未认证")
+		return
+	}
+
+	var req services.MarketListRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "参数错误: "+err.Error())
+		return
+	}
+
+	listing, err := services.NewMarketService(mc.db).List(userID.(uint), req)
+	if err != nil {
+		respondMarketError(c, err, "上架失败: ")
+		return
+	}
+	utils.SuccessResponse(c, gin.H{
+		"message": "上架成功",
+		"listing": listing,
+	})
+}
+
+// BuyListing 购买整单寄售
+func (mc *MarketController) BuyListing(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "用户未认证")
+		return
+	}
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil || id == 0 {
+		utils.ErrorResponse(c, http.StatusBadRequest, "无效的寄售ID")
+		return
+	}
+
+	result, err := services.NewMarketService(mc.db).Buy(userID.(uint), uint(id))
+	if err != nil {
+		respondMarketError(c, err, "购买失败: ")
+		return
+	}
+	utils.SuccessResponse(c, gin.H{
+		"message":         "购买成功",
+		"listing":         result.Listing,
+		"item":            result.Item,
+		"equipment":       result.Equipment,
+		"current_balance": result.CurrentBalance,
+	})
+}
+
+// CancelListing 下架寄售中的物品，物品以邮件退回
+func (mc *MarketController) CancelListing(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "用户未认证")
+		return
+	}
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil || id == 0 {
+		utils.ErrorResponse(c, http.StatusBadRequest, "无效的寄售ID")
+		return
+	}
+
+	listing, err := services.NewMarketService(mc.db).Cancel(userID.(uint), uint(id))
+	if err != nil {
+		respondMarketError(c, err, "下架失败: ")
+		return
+	}
+	utils.SuccessResponse(c, gin.H{
+		"message": "已下架，物品已通过邮件退回",
+		"listing": listing,
+	})
+}
+
+func respondMarketError(c *gin.Context, err error, prefix string) {
+	switch {
+	case errors.Is(err, services.ErrMarketListingNotFound),
+		errors.Is(err, services.ErrEquipmentNotFound),
+		errors.Is(err, services.ErrMyItemNotEnough):
+		utils.ErrorResponse(c, http.StatusNotFound, err.Error())
+	case errors.Is(err, services.ErrInsufficientGold):
+		utils.ErrorResponse(c, http.StatusBadRequest, "金币不足")
+	case errors.Is(err, services.ErrInsufficientDiamond):
+		utils.ErrorResponse(c, http.StatusBadRequest, "钻石不足")
+	case errors.Is(err, services.ErrMarketKind),
+		errors.Is(err, services.ErrMarketCurrency),
+		errors.Is(err, services.ErrMarketPrice),
+		errors.Is(err, services.ErrMarketDuration),
+		errors.Is(err, services.ErrMarketTooMany),
+		errors.Is(err, services.ErrMarketOwnListing),
+		errors.Is(err, services.ErrEquipmentLocked),
+		errors.Is(err, services.ErrEquipmentEquipped),
+		errors.Is(err, services.ErrInventoryFull),
+		errors.Is(err, services.ErrInventoryStackFull):
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+	default:
+		utils.ErrorResponse(c, http.StatusInternalServerError, prefix+err.Error())
+	}
+}
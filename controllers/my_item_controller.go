@@ -1,7 +1,10 @@
 package controllers
 
 import (
+	"errors"
+	"fmt"
 	"ggo/models"
+	"ggo/services"
 	"ggo/utils"
 	"net/http"
 	"strconv"
@@ -18,14 +21,14 @@ func NewMyItemController(db *gorm.DB) *MyItemController {
 	return &MyItemController{db: db}
 }
 
-// AddMyItem 添加我的物品
+// AddMyItem 向玩家背包发放宝物（管理员功能）。玩家的宝物只能来自对局掉落、邮件、打造等服务端发放
 func (mic *MyItemController) AddMyItem(c *gin.Context) {
-	// 从context中获取用户ID
-	userID, exists := c.Get("userID")
-	if !exists {
-		utils.ErrorResponse(c, http.StatusUnauthorized, "用户未认证")
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil || id == 0 {
+		utils.ErrorResponse(c, http.StatusBadRequest, "无效的用户ID")
 		return
 	}
+	userID := uint(id)
 
 	// 支持物品数组的请求参数
 	type ItemRequest struct {
@@ -46,16 +49,11 @@ func (mic *MyItemController) AddMyItem(c *gin.Context) {
 
 	// 验证用户是否存在
 	var user models.User
-	if err := mic.db.First(&user, userID.(uint)).Error; err != nil {
+	if err := mic.db.First(&user, userID).Error; err != nil {
 		utils.ErrorResponse(c, http.StatusNotFound, "用户不存在")
 		return
 	}
 
-	// 设置默认值
-	itemType := "treasure" // 默认物品类型为宝物
-	position := "backpack" // 默认位置为背包
-	sellPrice := 0         // 默认售价为0
-
 	// 创建响应结构，包含物品详细信息
 	type MyItemResponse struct {
 		models.MyItem
@@ -64,37 +62,31 @@ func (mic *MyItemController) AddMyItem(c *gin.Context) {
 
 	var responses []MyItemResponse
 
-	// 批量处理物品
-	for _, req := range requests {
-		// 验证宝物是否存在
-		var treasure models.Treasure
-		if err := mic.db.First(&treasure, req.ItemID).Error; err != nil {
-			utils.ErrorResponse(c, http.StatusNotFound, "宝物ID "+strconv.Itoa(int(req.ItemID))+" 不存在")
-			return
-		}
-		itemName := treasure.Name
-
-		// 创建我的物品记录
-		myItem := models.MyItem{
-			UserID:    userID.(uint),
-			ItemID:    req.ItemID,
-			ItemType:  itemType,
-			SellPrice: sellPrice,
-			Position:  position,
-			Quantity:  req.Quantity,
-		}
+	// 批量处理物品：叠加到背包中已有的堆叠，任意一个失败时全部回滚
+	err = mic.db.Transaction(func(tx *gorm.DB) error {
+		for _, req := range requests {
+			// 验证宝物是否存在
+			var treasure models.Treasure
+			if err := tx.First(&treasure, req.ItemID).Error; err != nil {
+				return fmt.Errorf("宝物ID %d 不存在", req.ItemID)
+			}
 
-		if err := mic.db.Create(&myItem).Error; err != nil {
-			utils.ErrorResponse(c, http.StatusInternalServerError, "添加物品失败: "+err.Error())
-			return
-		}
+			myItem, err := services.AddMyItem(tx, userID, models.MyItemTypeTreasure, req.ItemID, models.PositionBackpack, req.Quantity)
+			if err != nil {
+				return err
+			}
 
-		// 添加到响应列表
-		response := MyItemResponse{
-			MyItem:   myItem,
-			ItemName: itemName,
+			// 添加到响应列表
+			responses = append(responses, MyItemResponse{
+				MyItem:   *myItem,
+				ItemName: treasure.Name,
+			})
 		}
-		responses = append(responses, response)
+		return nil
+	})
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "添加物品失败: "+err.Error())
+		return
 	}
 
 	utils.SuccessResponse(c, responses)
@@ -379,6 +371,7 @@ func (mic *MyItemController) SellMultipleTreasures(c *gin.Context) {
 
 	// 7. 处理每个出售请求
 	totalPrice := 0
+	newGold := 0
 	var soldItems []gin.H
 
 	for _, reqItem := range request.Items {
@@ -428,40 +421,34 @@ func (mic *MyItemController) SellMultipleTreasures(c *gin.Context) {
 			"sold_price":    itemTotalPrice,
 		})
 
-		// 更新或删除物品
-		if sellQuantity == myItem.Quantity {
-			// 数量相等，删除物品
-			if err := tx.Delete(&models.MyItem{}, myItem.ID).Error; err != nil {
-				tx.Rollback()
-				utils.ErrorResponse(c, http.StatusInternalServerError, "出售物品失败: "+err.Error())
-				return
-			}
-		} else {
-			// 数量不等，更新数量
-			newQuantity := myItem.Quantity - sellQuantity
-			if err := tx.Model(&models.MyItem{}).Where("id = ?", myItem.ID).Update("quantity", newQuantity).Error; err != nil {
-				tx.Rollback()
-				utils.ErrorResponse(c, http.StatusInternalServerError, "更新物品数量失败: "+err.Error())
+		// 增加金币并记录流水
+		goldTx, err := services.ChangeCurrency(tx, services.CurrencyChange{
+			UserID:     userID.(uint),
+			Currency:   models.CurrencyGold,
+			Delta:      itemTotalPrice,
+			Reason:     models.CurrencyReasonTreasureSell,
+			SourceType: "my_item",
+			SourceID:   myItem.ID,
+		})
+		if err != nil {
+			tx.Rollback()
+			utils.ErrorResponse(c, http.StatusInternalServerError, "更新金币失败: "+err.Error())
+			return
+		}
+		newGold = goldTx.BalanceAfter
+
+		// 扣减物品数量（同一物品在请求中出现多次时按最新数量校验）
+		if _, err := services.TakeMyItem(tx, userID.(uint), models.MyItemTypeTreasure, myItem.ID, sellQuantity); err != nil {
+			tx.Rollback()
+			if errors.Is(err, services.ErrMyItemNotEnough) {
+				utils.ErrorResponse(c, http.StatusBadRequest, "ID为 "+strconv.Itoa(int(reqItem.MyItemID))+" 的宝物数量不足")
 				return
 			}
+			utils.ErrorResponse(c, http.StatusInternalServerError, "出售物品失败: "+err.Error())
+			return
 		}
 	}
 
-	// 8. 更新用户金币
-	var user models.User
-	if err := tx.First(&user, userID.(uint)).Error; err != nil {
-		tx.Rollback()
-		utils.ErrorResponse(c, http.StatusNotFound, "用户不存在")
-		return
-	}
-
-	newGold := user.Gold + totalPrice
-	if err := tx.Model(&user).Update("gold", newGold).Error; err != nil {
-		tx.Rollback()
-		utils.ErrorResponse(c, http.StatusInternalServerError, "更新金币失败: "+err.Error())
-		return
-	}
-
 	// 提交事务
 	tx.Commit()
 
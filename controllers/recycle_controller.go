@@ -0,0 +1,138 @@
+package controllers
+
+import (
+	"errors"
+	"ggo/models"
+	"ggo/services"
+	"ggo/utils"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// RecycleController 装备回收换取钻石，以及回收价格配置（管理后台）
+type RecycleController struct {
+	db *gorm.DB
+}
+
+// NewRecycleController 创建装备回收控制器实例
+func NewRecycleController(db *gorm.DB) *RecycleController {
+	return &RecycleController{db: db}
+}
+
+// RecycleEquipment 回收单件装备
+func (rc *RecycleController) RecycleEquipment(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "用户未登录")
+		return
+	}
+
+	equipmentID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "无效的装备ID")
+		return
+	}
+
+	rc.recycle(c, userID.(uint), []uint{uint(equipmentID)}, false)
+}
+
+// BatchRecycleEquipment 批量回收装备，任意一件不可回收（包括收藏的装备）时整批失败
+func (rc *RecycleController) BatchRecycleEquipment(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "用户未登录")
+		return
+	}
+
+	var request struct {
+		EquipmentIDs []uint `json:"equipment_ids" binding:"required,min=1"` // 玩家装备ID
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "参数错误: "+err.Error())
+		return
+	}
+
+	rc.recycle(c, userID.(uint), request.EquipmentIDs, true)
+}
+
+func (rc *RecycleController) recycle(c *gin.Context, userID uint, equipmentIDs []uint, batch bool) {
+	result, err := services.NewRecycleService(rc.db).Recycle(userID, equipmentIDs, batch)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrEquipmentNotFound):
+			utils.ErrorResponse(c, http.StatusNotFound, err.Error())
+		case errors.Is(err, services.ErrRecycleEmpty),
+			errors.Is(err, services.ErrRecycleTooMany),
+			errors.Is(err, services.ErrEquipmentEquipped),
+			errors.Is(err, services.ErrEquipmentLocked),
+			errors.Is(err, services.ErrEquipmentFavorite):
+			utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		default:
+			utils.ErrorResponse(c, http.StatusInternalServerError, "回收装备失败: "+err.Error())
+		}
+		return
+	}
+
+	utils.SuccessResponse(c, gin.H{
+		"message":         "回收成功",
+		"diamond":         result.Diamond,
+		"current_diamond": result.CurrentDiamond,
+		"items":           result.Items,
+	})
+}
+
+// GetRecycleConfigs 获取各品级的回收价格配置
+func (rc *RecycleController) GetRecycleConfigs(c *gin.Context) {
+	var configs []models.EquipmentRecycleConfig
+	if err := rc.db.Order("level").Find(&configs).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "查询回收价格失败: "+err.Error())
+		return
+	}
+	utils.SuccessResponse(c, configs)
+}
+
+// UpdateRecycleConfig 修改某个品级的回收价格，不存在时创建
+func (rc *RecycleController) UpdateRecycleConfig(c *gin.Context) {
+	level, err := strconv.Atoi(c.Param("level"))
+	if err != nil || level < 1 || level > 6 {
+		utils.ErrorResponse(c, http.StatusBadRequest, "无效的装备品级")
+		return
+	}
+
+	var request struct {
+		BaseDiamond        int `json:"base_diamond" binding:"min=0"`
+		EnhanceDiamond     int `json:"enhance_diamond" binding:"min=0"`
+		CommonAffixDiamond int `json:"common_affix_diamond" binding:"min=0"`
+		RareAffixDiamond   int `json:"rare_affix_diamond" binding:"min=0"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "参数错误: "+err.Error())
+		return
+	}
+
+	config := models.EquipmentRecycleConfig{
+		Level:              level,
+		BaseDiamond:        request.BaseDiamond,
+		EnhanceDiamond:     request.EnhanceDiamond,
+		CommonAffixDiamond: request.CommonAffixDiamond,
+		RareAffixDiamond:   request.RareAffixDiamond,
+		UpdatedBy:          c.GetString("adminUsername"),
+	}
+	if err := rc.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "level"}},
+		DoUpdates: clause.AssignmentColumns([]string{"base_diamond", "enhance_diamond", "common_affix_diamond", "rare_affix_diamond", "updated_by", "updated_at"}),
+	}).Create(&config).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "修改回收价格失败: "+err.Error())
+		return
+	}
+
+	if err := rc.db.Where("level = ?", level).First(&config).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "查询回收价格失败: "+err.Error())
+		return
+	}
+	utils.SuccessResponse(c, config)
+}
@@ -0,0 +1,181 @@
+package controllers
+
+import (
+	"errors"
+	"ggo/models"
+	"ggo/services"
+	"ggo/utils"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ReforgeController 词条重铸：先预览，接受后才生效
+type ReforgeController struct {
+	db *gorm.DB
+}
+
+// NewReforgeController 创建词条重铸控制器实例
+func NewReforgeController(db *gorm.DB) *ReforgeController {
+	return &ReforgeController{db: db}
+}
+
+// ReforgeAffix 重铸装备词条，返回待确认的预览结果（消耗立即扣除，装备词条不变）
+func (rc *ReforgeController) ReforgeAffix(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "用户未登录")
+		return
+	}
+
+	equipmentID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "无效的装备ID")
+		return
+	}
+
+	var request services.ReforgeRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "参数错误: "+err.Error())
+		return
+	}
+
+	reforge, err := services.NewReforgeService(rc.db).Preview(userID.(uint), uint(equipmentID), request)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrEquipmentNotFound),
+			errors.Is(err, services.ErrReforgeAttrMissing):
+			utils.ErrorResponse(c, http.StatusNotFound, err.Error())
+		case errors.Is(err, services.ErrReforgePending):
+			utils.ErrorResponse(c, http.StatusConflict, err.Error())
+		case errors.Is(err, services.ErrInsufficientGold):
+			utils.ErrorResponse(c, http.StatusBadRequest, "金币不足")
+		case errors.Is(err, services.ErrReforgeInvalid),
+			errors.Is(err, services.ErrReforgeMaterial),
+			errors.Is(err, services.ErrReforgeUnavailable):
+			utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		default:
+			utils.ErrorResponse(c, http.StatusInternalServerError, "重铸失败")
+		}
+		return
+	}
+
+	rc.respondReforge(c, reforge)
+}
+
+// GetPendingReforge 获取装备当前待确认的重铸结果，没有时返回 null
+func (rc *ReforgeController) GetPendingReforge(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "用户未登录")
+		return
+	}
+
+	equipmentID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "无效的装备ID")
+		return
+	}
+
+	reforge, err := services.NewReforgeService(rc.db).Pending(userID.(uint), uint(equipmentID))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "查询重铸结果失败: "+err.Error())
+		return
+	}
+	if reforge == nil {
+		utils.SuccessResponse(c, nil)
+		return
+	}
+	rc.respondReforge(c, reforge)
+}
+
+// AcceptReforge 接受重铸结果，写入装备词条
+func (rc *ReforgeController) AcceptReforge(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "用户未登录")
+		return
+	}
+
+	reforgeID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "无效的重铸ID")
+		return
+	}
+
+	equipment, err := services.NewReforgeService(rc.db).Accept(userID.(uint), uint(reforgeID))
+	if err != nil {
+		rc.respondReforgeError(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, gin.H{
+		"message":   "重铸结果已生效",
+		"equipment": equipment,
+	})
+}
+
+// DiscardReforge 放弃重铸结果，保留原词条
+func (rc *ReforgeController) DiscardReforge(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "用户未登录")
+		return
+	}
+
+	reforgeID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "无效的重铸ID")
+		return
+	}
+
+	if err := services.NewReforgeService(rc.db).Discard(userID.(uint), uint(reforgeID)); err != nil {
+		rc.respondReforgeError(c, err)
+		return
+	}
+
+	utils.SuccessResponse(c, gin.H{"message": "已放弃重铸结果"})
+}
+
+func (rc *ReforgeController) respondReforgeError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrReforgeNotFound):
+		utils.ErrorResponse(c, http.StatusNotFound, err.Error())
+	case errors.Is(err, services.ErrReforgeNotPending),
+		errors.Is(err, services.ErrReforgeExpired),
+		errors.Is(err, services.ErrReforgeStale):
+		utils.ErrorResponse(c, http.StatusConflict, err.Error())
+	default:
+		utils.ErrorResponse(c, http.StatusInternalServerError, "处理重铸结果失败")
+	}
+}
+
+// respondReforge 返回重铸记录，并附带词条变化的展示文本
+func (rc *ReforgeController) respondReforge(c *gin.Context, reforge *models.AffixReforge) {
+	registry, err := services.GetAffixRegistry(rc.db)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "加载词条配置失败: "+err.Error())
+		return
+	}
+
+	display := make([]gin.H, 0, len(reforge.Changes))
+	for _, change := range reforge.Changes {
+		oldAttr := models.EquipmentAdditionalAttr{AttrType: change.OldType, Value: change.OldValue, IsPercent: change.IsPercent}
+		if def, ok := registry.Definition(change.OldType); ok {
+			oldAttr.IsPercent = def.IsPercent
+		}
+		newAttr := models.EquipmentAdditionalAttr{AttrType: change.NewType, Value: change.NewValue, IsPercent: change.IsPercent}
+		display = append(display, gin.H{
+			"attr_id": change.AttrID,
+			"old":     registry.DisplayName(change.OldType) + " " + registry.FormatValue(&oldAttr),
+			"new":     registry.DisplayName(change.NewType) + " " + registry.FormatValue(&newAttr),
+		})
+	}
+
+	utils.SuccessResponse(c, gin.H{
+		"reforge": reforge,
+		"display": display,
+	})
+}
@@ -0,0 +1,104 @@
+package controllers
+
+import (
+	"errors"
+	"ggo/services"
+	"ggo/utils"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// RunController 对局控制器
+type RunController struct {
+	runService *services.RunService
+}
+
+// NewRunController 创建对局控制器实例
+func NewRunController(db *gorm.DB) *RunController {
+	return &RunController{
+		runService: services.NewRunService(db),
+	}
+}
+
+// StartRun 开局：返回服务端生成的刷怪/场景和对局token
+func (rc *RunController) StartRun(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "未授权")
+		return
+	}
+
+	var req struct {
+		Area   int    `json:"area" binding:"required,min=1"`
+		Region string `json:"region" binding:"omitempty,max=100"` // 区域（可选），按怪物出现地点和场景所属区域筛选
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "参数错误: "+err.Error())
+		return
+	}
+
+	run, token, err := rc.runService.Start(userID.(uint), req.Area, req.Region)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrRunNoMonsters):
+			utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		case errors.Is(err, services.ErrRunInProgress):
+			utils.ErrorResponse(c, http.StatusConflict, err.Error())
+		default:
+			utils.ErrorResponse(c, http.StatusInternalServerError, "开局失败: "+err.Error())
+		}
+		return
+	}
+
+	utils.SuccessResponse(c, gin.H{
+		"run":       run,
+		"run_token": token,
+	})
+}
+
+// FinishRun 结算：校验时长和击杀数后由服务端发放奖励
+func (rc *RunController) FinishRun(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "未授权")
+		return
+	}
+
+	runID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "无效的对局ID")
+		return
+	}
+
+	var req struct {
+		RunToken string             `json:"run_token" binding:"required"`
+		Kills    []services.RunKill `json:"kills" binding:"dive"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "参数错误: "+err.Error())
+		return
+	}
+
+	result, err := rc.runService.Finish(userID.(uint), uint(runID), req.RunToken, req.Kills)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrRunTokenInvalid):
+			utils.ErrorResponse(c, http.StatusForbidden, err.Error())
+		case errors.Is(err, services.ErrRunNotFound):
+			utils.ErrorResponse(c, http.StatusNotFound, err.Error())
+		case errors.Is(err, services.ErrRunNotActive), errors.Is(err, services.ErrRunExpired):
+			utils.ErrorResponse(c, http.StatusConflict, err.Error())
+		case errors.Is(err, services.ErrRunKillsInvalid), errors.Is(err, services.ErrRunFinishedEarly),
+			errors.Is(err, services.ErrRunTooShort):
+			utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		default:
+			utils.ErrorResponse(c, http.StatusInternalServerError, "结算失败: "+err.Error())
+		}
+		return
+	}
+
+	utils.SuccessResponse(c, result)
+}
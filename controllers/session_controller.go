@@ -0,0 +1,127 @@
+package controllers
+
+import (
+	"errors"
+	"ggo/config"
+	"ggo/services"
+	"ggo/utils"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// SessionController 登录会话控制器（刷新token、设备会话管理）
+type SessionController struct {
+	sessions *services.SessionService
+}
+
+// NewSessionController 创建会话控制器实例
+func NewSessionController(db *gorm.DB, cfg *config.Config) *SessionController {
+	return &SessionController{
+		sessions: services.NewSessionService(db, cfg),
+	}
+}
+
+// RefreshToken 使用刷新token换取新的访问token，刷新token同时轮换
+func (sc *SessionController) RefreshToken(c *gin.Context) {
+	var req struct {
+		RefreshToken string `json:"refresh_token" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "参数错误: "+err.Error())
+		return
+	}
+
+	tokens, err := sc.sessions.Refresh(req.RefreshToken, services.ClientInfo{
+		IP:        c.ClientIP(),
+		UserAgent: c.GetHeader("User-Agent"),
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidRefreshToken),
+			errors.Is(err, services.ErrRefreshTokenReused),
+			errors.Is(err, services.ErrSessionExpired):
+			utils.ErrorResponse(c, http.StatusUnauthorized, err.Error())
+		default:
+			utils.ErrorResponse(c, http.StatusInternalServerError, "刷新token失败: "+err.Error())
+		}
+		return
+	}
+
+	utils.SuccessResponseWithToken(c, tokens, tokens.AccessToken)
+}
+
+// GetSessions 获取当前用户的登录设备列表
+func (sc *SessionController) GetSessions(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "未授权")
+		return
+	}
+	currentSessionID := c.GetString("sessionID")
+
+	sessions, err := sc.sessions.ListSessions(userID.(uint))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "查询会话失败: "+err.Error())
+		return
+	}
+
+	result := make([]gin.H, 0, len(sessions))
+	for _, session := range sessions {
+		result = append(result, gin.H{
+			"session_id":   session.SessionID,
+			"device_id":    session.DeviceID,
+			"device_name":  session.DeviceName,
+			"user_agent":   session.UserAgent,
+			"ip":           session.IP,
+			"last_seen_at": session.LastSeenAt,
+			"created_at":   session.CreatedAt,
+			"expires_at":   session.ExpiresAt,
+			"current":      session.SessionID == currentSessionID,
+		})
+	}
+
+	utils.SuccessResponse(c, result)
+}
+
+// RevokeSession 吊销指定会话（踢下线某台设备）
+func (sc *SessionController) RevokeSession(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "未授权")
+		return
+	}
+
+	if err := sc.sessions.Revoke(userID.(uint), c.Param("id")); err != nil {
+		if errors.Is(err, services.ErrSessionNotFound) {
+			utils.ErrorResponse(c, http.StatusNotFound, err.Error())
+			return
+		}
+		utils.ErrorResponse(c, http.StatusInternalServerError, "吊销会话失败: "+err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, gin.H{"message": "会话已吊销"})
+}
+
+// RevokeAllSessions 吊销全部会话，except_current=true 时保留当前设备
+func (sc *SessionController) RevokeAllSessions(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "未授权")
+		return
+	}
+
+	exceptSessionID := ""
+	if c.Query("except_current") == "true" {
+		exceptSessionID = c.GetString("sessionID")
+	}
+
+	if err := sc.sessions.RevokeAll(userID.(uint), exceptSessionID); err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "吊销会话失败: "+err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, gin.H{"message": "会话已全部吊销"})
+}
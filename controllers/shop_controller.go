@@ -0,0 +1,132 @@
+package controllers
+
+import (
+	"errors"
+	"ggo/services"
+	"ggo/utils"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ShopController 神秘商店：商品由服务端生成和校验，替代存档中客户端生成的 mystery_shop
+type ShopController struct {
+	db *gorm.DB
+}
+
+// NewShopController 创建神秘商店控制器实例
+func NewShopController(db *gorm.DB) *ShopController {
+	return &ShopController{db: db}
+}
+
+type shopAreaRequest struct {
+	Area int `json:"area" binding:"required,min=1"`
+}
+
+// GetShop 获取神秘商店的当前商品，到了定时刷新时间时自动刷新
+func (sc *ShopController) GetShop(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "用户未认证")
+		return
+	}
+
+	area, err := strconv.Atoi(c.DefaultQuery("area", "1"))
+	if err != nil || area <= 0 {
+		utils.ErrorResponse(c, http.StatusBadRequest, "无效的area参数")
+		return
+	}
+
+	shop, err := services.NewShopService(sc.db).Get(userID.(uint), area)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "获取神秘商店失败: "+err.Error())
+		return
+	}
+	utils.SuccessResponse(c, gin.H{
+		"shop":               shop,
+		"refresh_diamond":    services.ShopRefreshDiamond,
+		"max_paid_refreshes": services.ShopMaxPaidRefreshes,
+	})
+}
+
+// RefreshShop 花费钻石刷新神秘商店
+func (sc *ShopController) RefreshShop(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "用户未认证")
+		return
+	}
+
+	var req shopAreaRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "参数错误: "+err.Error())
+		return
+	}
+
+	shop, currentDiamond, err := services.NewShopService(sc.db).Refresh(userID.(uint), req.Area)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInsufficientDiamond):
+			utils.ErrorResponse(c, http.StatusBadRequest, "钻石不足")
+		case errors.Is(err, services.ErrShopRefreshLimit):
+			utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		default:
+			utils.ErrorResponse(c, http.StatusInternalServerError, "刷新失败: "+err.Error())
+		}
+		return
+	}
+	utils.SuccessResponse(c, gin.H{
+		"message":         "刷新成功",
+		"shop":            shop,
+		"current_diamond": currentDiamond,
+	})
+}
+
+// BuyShopOffer 购买神秘商店的商品
+func (sc *ShopController) BuyShopOffer(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "用户未认证")
+		return
+	}
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil || id == 0 {
+		utils.ErrorResponse(c, http.StatusBadRequest, "无效的商品ID")
+		return
+	}
+	var req shopAreaRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "参数错误: "+err.Error())
+		return
+	}
+
+	result, err := services.NewShopService(sc.db).Buy(userID.(uint), req.Area, uint(id))
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrShopOfferNotFound):
+			utils.ErrorResponse(c, http.StatusNotFound, err.Error())
+		case errors.Is(err, services.ErrInsufficientGold):
+			utils.ErrorResponse(c, http.StatusBadRequest, "金币不足")
+		case errors.Is(err, services.ErrInsufficientDiamond):
+			utils.ErrorResponse(c, http.StatusBadRequest, "钻石不足")
+		case errors.Is(err, services.ErrShopOfferPurchased),
+			errors.Is(err, services.ErrShopExpired),
+			errors.Is(err, services.ErrInventoryFull),
+			errors.Is(err, services.ErrInventoryStackFull):
+			utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		default:
+			utils.ErrorResponse(c, http.StatusInternalServerError, "购买失败: "+err.Error())
+		}
+		return
+	}
+	utils.SuccessResponse(c, gin.H{
+		"message":         "购买成功",
+		"offer":           result.Offer,
+		"item":            result.Item,
+		"equipment":       result.Equipment,
+		"current_balance": result.CurrentBalance,
+	})
+}
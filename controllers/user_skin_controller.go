@@ -2,6 +2,7 @@ package controllers
 
 import (
 	"ggo/models"
+	"ggo/services/stats"
 	"ggo/utils"
 	"net/http"
 	"strconv"
@@ -137,6 +138,7 @@ func (usc *UserSkinController) ActivateSkin(c *gin.Context) {
 	}
 
 	tx.Commit()
+	stats.Refresh(usc.db, userID.(uint))
 
 	utils.SuccessResponse(c, gin.H{"message": "皮肤启用成功"})
 }
@@ -185,6 +187,8 @@ func (usc *UserSkinController) DeleteUserSkin(c *gin.Context) {
 		utils.ErrorResponse(c, http.StatusNotFound, "皮肤不存在或不属于该用户")
 		return
 	}
+	// 删除的可能是启用中的皮肤
+	stats.Refresh(usc.db, userID.(uint))
 
 	utils.SuccessResponse(c, gin.H{"message": "皮肤删除成功"})
 }
@@ -1,32 +1,36 @@
 package controllers
 
 import (
-	"encoding/json"
-	"fmt"
+	"errors"
 	"ggo/config"
+	"ggo/services"
 	"ggo/utils"
-	"io/ioutil"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 // WeChatController 微信小程序控制器
 type WeChatController struct {
-	cfg *config.Config
+	wechatService *services.WeChatService
 }
 
 // NewWeChatController 创建微信控制器实例
-func NewWeChatController(cfg *config.Config) *WeChatController {
+func NewWeChatController(db *gorm.DB, cfg *config.Config) *WeChatController {
+	client := services.NewWeChatClient(cfg.WeChatAppID, cfg.WeChatAppSecret, cfg.WeChatAPIBase)
 	return &WeChatController{
-		cfg: cfg,
+		wechatService: services.NewWeChatService(db, client, services.NewSessionService(db, cfg)),
 	}
 }
 
-// GetOpenID 通过临时登录凭证code获取微信小游戏的openid
-func (wc *WeChatController) GetOpenID(c *gin.Context) {
+// Login 微信登录：用 wx.login 的 code 登录绑定的游戏账号，未绑定时自动注册
+// session_key 只保存在服务端，返回与账号密码登录相同的 UserLoginResponse
+func (wc *WeChatController) Login(c *gin.Context) {
 	var req struct {
-		Code string `json:"code" binding:"required"`
+		Code       string `json:"code" binding:"required"`
+		DeviceID   string `json:"device_id" binding:"omitempty,max=100"`
+		DeviceName string `json:"device_name" binding:"omitempty,max=100"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -34,57 +38,59 @@ func (wc *WeChatController) GetOpenID(c *gin.Context) {
 		return
 	}
 
-	// 调用微信API获取openid和session_key
-	// 微信API文档：https://developers.weixin.qq.com/miniprogram/dev/api-backend/open-api/login/auth.code2Session.html
-	url := fmt.Sprintf("https://api.weixin.qq.com/sns/jscode2session?appid=%s&secret=%s&js_code=%s&grant_type=authorization_code",
-		wc.cfg.WeChatAppID, wc.cfg.WeChatAppSecret, req.Code)
-
-	resp, err := http.Get(url)
+	response, err := wc.wechatService.Login(c.Request.Context(), req.Code, services.ClientInfo{
+		IP:         c.ClientIP(),
+		UserAgent:  c.GetHeader("User-Agent"),
+		DeviceID:   req.DeviceID,
+		DeviceName: req.DeviceName,
+	})
 	if err != nil {
-		utils.ErrorResponse(c, http.StatusInternalServerError, "调用微信API失败: "+err.Error())
+		respondWeChatError(c, err, "微信登录失败")
 		return
 	}
-	defer resp.Body.Close()
 
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		utils.ErrorResponse(c, http.StatusInternalServerError, "读取响应失败: "+err.Error())
-		return
-	}
+	utils.SuccessResponseWithToken(c, response, response.Token)
+}
 
-	// 解析微信API返回的数据
-	var response map[string]interface{}
-	if err := json.Unmarshal(body, &response); err != nil {
-		utils.ErrorResponse(c, http.StatusInternalServerError, "解析响应数据失败: "+err.Error())
+// Bind 将当前登录的用户名账号绑定到微信，绑定后可直接使用微信登录
+func (wc *WeChatController) Bind(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "未授权")
 		return
 	}
 
-	// 检查是否有错误
-	if errCode, ok := response["errcode"]; ok && errCode.(float64) != 0 {
-		errMsg := "获取openid失败"
-		if msg, ok := response["errmsg"]; ok {
-			errMsg = fmt.Sprintf("获取openid失败: %s", msg)
-		}
-		utils.ErrorResponse(c, http.StatusBadRequest, errMsg)
-		return
+	var req struct {
+		Code string `json:"code" binding:"required"`
 	}
-
-	// 提取openid和session_key
-	openid, ok := response["openid"]
-	if !ok {
-		utils.ErrorResponse(c, http.StatusBadRequest, "响应中缺少openid")
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "参数错误: "+err.Error())
 		return
 	}
 
-	sessionKey, ok := response["session_key"]
-	if !ok {
-		utils.ErrorResponse(c, http.StatusBadRequest, "响应中缺少session_key")
+	account, err := wc.wechatService.Bind(c.Request.Context(), userID.(uint), req.Code)
+	if err != nil {
+		respondWeChatError(c, err, "绑定微信失败")
 		return
 	}
 
-	// 返回openid和session_key
 	utils.SuccessResponse(c, gin.H{
-		"openid":      openid,
-		"session_key": sessionKey,
+		"message": "绑定成功",
+		"user_id": account.UserID,
+		"openid":  account.OpenID,
 	})
 }
+
+// respondWeChatError code被拒绝和绑定冲突返回400，微信接口异常返回502，其他错误返回500且不暴露内部错误
+func respondWeChatError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, services.ErrWeChatCodeRejected):
+		utils.ErrorResponse(c, http.StatusBadRequest, "微信登录code无效或已使用，请重新调用wx.login")
+	case errors.Is(err, services.ErrWeChatAlreadyBound), errors.Is(err, services.ErrWeChatBoundToOther):
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+	case errors.Is(err, services.ErrWeChatUnavailable):
+		utils.ErrorResponse(c, http.StatusBadGateway, "微信服务暂不可用，请稍后重试")
+	default:
+		utils.ErrorResponse(c, http.StatusInternalServerError, message)
+	}
+}
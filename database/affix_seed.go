@@ -0,0 +1,194 @@
+package database
+
+import (
+	"ggo/models"
+	"log"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// 旧版本打造装备写入的属性类型 -> 统一后的属性类型
+var legacyAffixTypes = map[string]string{
+	"attack_bonus": "attack_pct",
+	"attack_fixed": "attack",
+	"hp_bonus":     "hp_pct",
+}
+
+// 旧数据中 attr_type=enhance 的七宗罪词条按名称还原属性类型
+var legacyRareAffixNames = map[string]string{
+	"傲慢": "sloth",
+	"嫉妒": "envy",
+	"暴食": "gluttony",
+	"贪婪": "greed",
+	"色欲": "lust",
+	"暴怒": "wrath",
+	"怠惰": "pride",
+}
+
+// defaultAffixDefinitions 词条表为空时写入的默认配置，与规则集上线前代码中的数值一致
+func defaultAffixDefinitions() []models.AffixDefinition {
+	forge := func(min, max float64) models.AffixRange {
+		return models.AffixRange{Pool: models.AffixPoolForge, Level: 0, Min: min, Max: max, Weight: 1}
+	}
+	merge := func(weight int, ranges map[int][2]float64) []models.AffixRange {
+		out := make([]models.AffixRange, 0, len(ranges))
+		for level := 1; level <= 6; level++ {
+			if r, ok := ranges[level]; ok {
+				out = append(out, models.AffixRange{Pool: models.AffixPoolMerge, Level: level, Min: r[0], Max: r[1], Weight: weight})
+			}
+		}
+		return out
+	}
+	common := func(attrType, name string, isPercent bool, decimals int, ranges ...models.AffixRange) models.AffixDefinition {
+		return models.AffixDefinition{AttrType: attrType, Name: name, Tier: models.AffixTierCommon, IsPercent: isPercent, Decimals: decimals, IsActive: true, Ranges: ranges}
+	}
+	rare := func(attrType, title, name string, isPercent bool, min, max float64) models.AffixDefinition {
+		decimals := 0
+		if isPercent {
+			decimals = 1
+		}
+		return models.AffixDefinition{AttrType: attrType, Name: name, Title: title, Tier: models.AffixTierRare, IsPercent: isPercent, Decimals: decimals, IsActive: true,
+			Ranges: []models.AffixRange{forge(min, max)}}
+	}
+
+	return []models.AffixDefinition{
+		common("hp", "生命值", false, 0, merge(3, map[int][2]float64{1: {100, 200}, 2: {200, 300}, 3: {300, 400}, 4: {500, 600}, 5: {700, 800}, 6: {1000, 2000}})...),
+		common("hp_pct", "血量加成", true, 1, forge(1, 5)),
+		common("attack", "攻击力", false, 0, append([]models.AffixRange{forge(10, 30)},
+			merge(3, map[int][2]float64{1: {5, 10}, 2: {10, 15}, 3: {20, 25}, 4: {25, 30}, 5: {35, 40}, 6: {55, 100}})...)...),
+		common("attack_pct", "攻击力加成", true, 1, forge(1, 3)),
+		common("attack_speed", "攻击速度", false, 2, merge(3, map[int][2]float64{1: {0.1, 0.3}, 2: {0.3, 0.5}, 3: {0.5, 0.8}, 4: {0.8, 1.0}, 5: {1.0, 2.0}, 6: {2.0, 3.0}})...),
+		common("bullet_speed", "子弹速度", false, 2, merge(3, map[int][2]float64{1: {0.3, 0.5}, 2: {0.5, 0.8}, 3: {0.8, 1.0}, 4: {1.0, 1.2}, 5: {1.3, 1.5}, 6: {1.5, 2.5}})...),
+		common("drain", "吸血", true, 1, append([]models.AffixRange{forge(1, 3)},
+			merge(3, map[int][2]float64{1: {1, 3}, 2: {2, 4}, 3: {3, 5}, 4: {3, 7}, 5: {5, 8}, 6: {8, 10}})...)...),
+		common("critical", "暴击", false, 0, merge(3, map[int][2]float64{1: {1, 3}, 2: {2, 4}, 3: {3, 5}, 4: {3, 7}, 5: {5, 8}, 6: {8, 15}})...),
+		common("critical_rate", "暴击率", true, 1, forge(1, 3)),
+		common("damage_reduction", "减伤", true, 1, forge(1, 3)),
+		common("recovery", "生命恢复", false, 0, append([]models.AffixRange{forge(20, 100)},
+			merge(1, map[int][2]float64{2: {3, 5}, 3: {5, 10}, 4: {15, 20}, 5: {25, 30}, 6: {35, 50}})...)...),
+		common("instant_kill", "秒杀", true, 0, merge(1, map[int][2]float64{4: {1, 2}, 5: {2, 3}, 6: {3, 4}})...),
+		common("trajectory", "弹道", false, 0, merge(1, map[int][2]float64{4: {1, 1}, 5: {2, 2}, 6: {3, 3}})...),
+		rare("sloth", "傲慢", "最大HP提升", true, 5, 20),
+		rare("envy", "嫉妒", "暴击伤害提升", true, 10, 20),
+		rare("gluttony", "暴食", "攻速提升", true, 10, 20),
+		rare("greed", "贪婪", "秒杀几率", true, 1, 1),
+		rare("lust", "色欲", "生命恢复", false, 100, 200),
+		rare("wrath", "暴怒", "暴击率", true, 8, 10),
+		rare("pride", "怠惰", "攻击力提升", true, 5, 20),
+	}
+}
+
+// seedAffixes 写入默认词条配置，并把旧属性类型迁移为统一的属性类型
+func seedAffixes(db *gorm.DB) {
+	var count int64
+	if err := db.Model(&models.AffixDefinition{}).Count(&count).Error; err != nil {
+		log.Println("Warning: Failed to count affix definitions:", err)
+		return
+	}
+	if count == 0 {
+		if err := db.Create(defaultAffixDefinitions()).Error; err != nil {
+			log.Println("Warning: Failed to seed affix definitions:", err)
+			return
+		}
+		log.Println("Seeded default affix definitions")
+	}
+
+	for legacy, current := range legacyAffixTypes {
+		if err := db.Model(&models.EquipmentAdditionalAttr{}).Where("attr_type = ?", legacy).
+			Update("attr_type", current).Error; err != nil {
+			log.Printf("Warning: Failed to migrate affix type %s: %v", legacy, err)
+		}
+	}
+	for name, attrType := range legacyRareAffixNames {
+		if err := db.Model(&models.EquipmentAdditionalAttr{}).Where("attr_type = ? AND attr_name = ?", "enhance", name).
+			Update("attr_type", attrType).Error; err != nil {
+			log.Printf("Warning: Failed to migrate rare affix %s: %v", name, err)
+		}
+	}
+
+	// 旧版本普通词条也写了名称，导致显示时被当成稀有词条；名称统一以词条定义的称号为准
+	if err := db.Exec("UPDATE equipment_additional_attrs a SET attr_name = d.title FROM affix_definitions d " +
+		"WHERE a.attr_type = d.attr_type AND a.attr_name <> d.title").Error; err != nil {
+		log.Println("Warning: Failed to normalize affix names:", err)
+	}
+}
+
+// migrateAffixValues 把旧的字符串属性值（如 "12.3%"、"秒杀1%"、"150"）解析写入 value/is_percent，
+// 迁移成功的行清空 attr_value；全部迁移完成后删除 attr_value 列，列不存在说明已迁移过。
+// 有无法解析的值时保留这些行的原值和 attr_value 列，修正数据后重启会继续迁移剩余的行
+func migrateAffixValues(db *gorm.DB) {
+	if !db.Migrator().HasColumn(&models.EquipmentAdditionalAttr{}, "attr_value") {
+		return
+	}
+
+	var rows []struct {
+		ID        uint
+		AttrType  string
+		AttrValue string
+	}
+	if err := db.Table("equipment_additional_attrs").Select("id, attr_type, attr_value").
+		Where("attr_value IS NOT NULL").Find(&rows).Error; err != nil {
+		log.Println("Warning: Failed to load legacy affix values:", err)
+		return
+	}
+
+	var definitions []models.AffixDefinition
+	if err := db.Find(&definitions).Error; err != nil {
+		log.Println("Warning: Failed to load affix definitions:", err)
+		return
+	}
+	percentTypes := make(map[string]bool, len(definitions))
+	for _, def := range definitions {
+		percentTypes[def.AttrType] = def.IsPercent
+	}
+
+	migrated, failed := 0, 0
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// 新词条不再写入 attr_value，迁移期间允许为空
+		if err := tx.Exec("ALTER TABLE equipment_additional_attrs ALTER COLUMN attr_value DROP NOT NULL").Error; err != nil {
+			return err
+		}
+		for _, row := range rows {
+			value, ok := parseLegacyAffixValue(row.AttrValue)
+			if !ok {
+				log.Printf("Warning: Unparseable affix value %q (id=%d), kept in attr_value", row.AttrValue, row.ID)
+				failed++
+				continue
+			}
+			isPercent, known := percentTypes[row.AttrType]
+			if !known {
+				isPercent = strings.Contains(row.AttrValue, "%")
+			}
+			if err := tx.Model(&models.EquipmentAdditionalAttr{}).Where("id = ?", row.ID).
+				Updates(map[string]interface{}{"value": value, "is_percent": isPercent, "attr_value": nil}).Error; err != nil {
+				return err
+			}
+			migrated++
+		}
+		if failed > 0 {
+			return nil
+		}
+		return tx.Migrator().DropColumn(&models.EquipmentAdditionalAttr{}, "attr_value")
+	})
+	if err != nil {
+		log.Println("Warning: Failed to migrate affix values:", err)
+		return
+	}
+	if failed > 0 {
+		log.Printf("Warning: Migrated %d affix values, %d unparseable values kept in attr_value; fix them and restart to finish the migration", migrated, failed)
+		return
+	}
+	log.Printf("Migrated %d affix values to typed columns", migrated)
+}
+
+// parseLegacyAffixValue 解析旧的字符串属性值，去掉 % 后缀和“秒杀”前缀
+func parseLegacyAffixValue(value string) (float64, bool) {
+	value = strings.TrimSpace(strings.NewReplacer("%", "", "秒杀", "").Replace(value))
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, false
+	}
+	return parsed, true
+}
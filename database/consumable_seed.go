@@ -0,0 +1,31 @@
+package database
+
+import (
+	"ggo/models"
+	"log"
+
+	"gorm.io/gorm"
+)
+
+// seedConsumables 消耗品表为空时写入默认的强化保护符和幸运符
+func seedConsumables(db *gorm.DB) {
+	var count int64
+	if err := db.Model(&models.Consumable{}).Count(&count).Error; err != nil {
+		log.Println("Warning: Failed to count consumables:", err)
+		return
+	}
+	if count > 0 {
+		return
+	}
+
+	consumables := []models.Consumable{
+		{Name: "强化保护符", Kind: models.ConsumableEnhanceProtect, Description: "强化失败时强化等级不下降", IsActive: true},
+		{Name: "幸运符", Kind: models.ConsumableEnhanceLuck, Value: 0.1, Description: "强化成功率+10%", IsActive: true},
+		{Name: "高级幸运符", Kind: models.ConsumableEnhanceLuck, Value: 0.2, Description: "强化成功率+20%", IsActive: true},
+	}
+	if err := db.Create(&consumables).Error; err != nil {
+		log.Println("Warning: Failed to seed consumables:", err)
+		return
+	}
+	log.Println("Seeded default consumables")
+}
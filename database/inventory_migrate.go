@@ -0,0 +1,41 @@
+package database
+
+import (
+	"ggo/models"
+	"log"
+
+	"gorm.io/gorm"
+)
+
+// consolidateMyItems 合并同一用户同一物品在同一位置的多条堆叠记录（唯一索引 idx_my_item_stack 上线前的旧数据），
+// 必须在 AutoMigrate 建立唯一索引之前执行；索引已存在时说明已合并过，直接跳过
+func consolidateMyItems(db *gorm.DB) {
+	if !db.Migrator().HasTable(&models.MyItem{}) || db.Migrator().HasIndex(&models.MyItem{}, "idx_my_item_stack") {
+		return
+	}
+
+	var removed int64
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("DELETE FROM my_items WHERE quantity <= 0").Error; err != nil {
+			return err
+		}
+		if err := tx.Exec("UPDATE my_items SET position = 'backpack' WHERE position IS NULL OR position = ''").Error; err != nil {
+			return err
+		}
+		// 数量合并到每组ID最小的记录上，再删除其余记录
+		if err := tx.Exec("UPDATE my_items m SET quantity = s.total FROM (" +
+			"SELECT MIN(id) AS id, SUM(quantity) AS total FROM my_items " +
+			"GROUP BY user_id, item_type, item_id, position HAVING COUNT(*) > 1) s WHERE m.id = s.id").Error; err != nil {
+			return err
+		}
+		result := tx.Exec("DELETE FROM my_items m USING my_items k " +
+			"WHERE m.user_id = k.user_id AND m.item_type = k.item_type AND m.item_id = k.item_id " +
+			"AND m.position = k.position AND m.id > k.id")
+		removed = result.RowsAffected
+		return result.Error
+	})
+	if err != nil {
+		log.Fatal("Failed to consolidate my_items stacks:", err)
+	}
+	log.Printf("Consolidated my_items stacks, removed %d duplicate rows", removed)
+}
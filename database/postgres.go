@@ -18,6 +18,9 @@ func InitPostgres(dsn string) {
 	}
 	log.Println("Connected to PostgreSQL")
 
+	// 背包物品改为唯一堆叠前，先合并旧的重复堆叠，否则无法建立唯一索引
+	consolidateMyItems(DB)
+
 	// 自动迁移表结构
 	err = DB.AutoMigrate(
 		&models.User{},
@@ -34,6 +37,32 @@ func InitPostgres(dsn string) {
 		&models.EquipmentAdditionalAttr{},
 		&models.Archive{},
 		&models.Area{},
+		&models.CurrencyTransaction{},
+		&models.IdempotencyRecord{},
+		&models.UserSession{},
+		&models.WeChatAccount{},
+		&models.AdminUser{},
+		&models.AdminAuditLog{},
+		&models.GameRun{},
+		&models.GameRunSpawn{},
+		&models.DropTable{},
+		&models.DropTableEntry{},
+		&models.DropTableBinding{},
+		&models.AffixDefinition{},
+		&models.AffixRange{},
+		&models.GameConfig{},
+		&models.Consumable{},
+		&models.EnhanceLog{},
+		&models.EquipmentRecycleConfig{},
+		&models.AffixReforge{},
+		&models.EquipmentSet{},
+		&models.EquipmentSetItem{},
+		&models.EquipmentSetBonus{},
+		&models.Loadout{},
+		&models.LoadoutItem{},
+		&models.MarketListing{},
+		&models.MysteryShop{},
+		&models.MysteryShopOffer{},
 	)
 	if err != nil {
 		log.Fatal("Failed to migrate database:", err)
@@ -55,4 +84,25 @@ func InitPostgres(dsn string) {
 	} else {
 		log.Println("Created GIN index on archives.json_data for better performance")
 	}
+
+	// 账本上线前已有余额的用户，补一条期初余额流水，保证余额与流水合计一致
+	for _, currency := range []string{models.CurrencyGold, models.CurrencyDiamond} {
+		err = DB.Exec("INSERT INTO currency_transactions (user_id, currency, delta, balance_after, reason, source_type, source_id, created_at) "+
+			"SELECT u.id, ?, u."+currency+", u."+currency+", ?, 'user', u.id, EXTRACT(EPOCH FROM NOW())::bigint FROM users u "+
+			"WHERE u."+currency+" <> 0 AND NOT EXISTS (SELECT 1 FROM currency_transactions t WHERE t.user_id = u.id AND t.currency = ?)",
+			currency, models.CurrencyReasonOpeningBalance, currency).Error
+		if err != nil {
+			log.Printf("Warning: Failed to backfill %s opening balances: %v", currency, err)
+		}
+	}
+
+	// 旧版穿戴接口替换同部位装备时只取消了穿戴状态，位置仍为 equipped，修正为背包
+	if err := DB.Exec("UPDATE user_equipments SET position = 'backpack' WHERE is_equipped = false AND position = 'equipped'").Error; err != nil {
+		log.Printf("Warning: Failed to fix position of unequipped equipment: %v", err)
+	}
+
+	seedAffixes(DB)
+	migrateAffixValues(DB)
+	seedConsumables(DB)
+	seedRecycleConfigs(DB)
 }
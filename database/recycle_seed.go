@@ -0,0 +1,34 @@
+package database
+
+import (
+	"ggo/models"
+	"log"
+
+	"gorm.io/gorm"
+)
+
+// seedRecycleConfigs 回收价格表为空时写入默认价格
+func seedRecycleConfigs(db *gorm.DB) {
+	var count int64
+	if err := db.Model(&models.EquipmentRecycleConfig{}).Count(&count).Error; err != nil {
+		log.Println("Warning: Failed to count recycle configs:", err)
+		return
+	}
+	if count > 0 {
+		return
+	}
+
+	configs := []models.EquipmentRecycleConfig{
+		{Level: 1, BaseDiamond: 2, EnhanceDiamond: 1, CommonAffixDiamond: 1, RareAffixDiamond: 5},
+		{Level: 2, BaseDiamond: 5, EnhanceDiamond: 1, CommonAffixDiamond: 2, RareAffixDiamond: 8},
+		{Level: 3, BaseDiamond: 10, EnhanceDiamond: 2, CommonAffixDiamond: 3, RareAffixDiamond: 12},
+		{Level: 4, BaseDiamond: 20, EnhanceDiamond: 3, CommonAffixDiamond: 4, RareAffixDiamond: 16},
+		{Level: 5, BaseDiamond: 40, EnhanceDiamond: 4, CommonAffixDiamond: 5, RareAffixDiamond: 20},
+		{Level: 6, BaseDiamond: 80, EnhanceDiamond: 5, CommonAffixDiamond: 6, RareAffixDiamond: 30},
+	}
+	if err := db.Create(&configs).Error; err != nil {
+		log.Println("Warning: Failed to seed recycle configs:", err)
+		return
+	}
+	log.Println("Seeded default recycle configs")
+}
@@ -16,7 +16,12 @@ func main() {
 	database.InitPostgres(cfg.PostgresDSN)
 	database.InitRedis(cfg.RedisAddr, cfg.RedisPassword, cfg.RedisDB)
 
+	services.EnsureBootstrapAdmin(database.DB, cfg)
+
 	services.StartDailyBossDamageRewardScheduler()
+	services.StartCurrencyReconcileScheduler()
+	services.StartIdempotencyCleanupScheduler()
+	services.StartMarketExpireScheduler()
 
 	// 设置路由并启动服务
 	router := routes.SetupRoutes(cfg)
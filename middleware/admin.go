@@ -1,27 +1,144 @@
 package middleware
 
 import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"ggo/database"
+	"ggo/models"
+	"ggo/services"
+	"ggo/utils"
+	"io"
 	"net/http"
 	"strings"
 
 	"github.com/gin-gonic/gin"
 )
 
+// 操作日志中请求体的最大保存长度
+const adminAuditBodyLimit = 4096
+
+// AdminAuth 管理后台认证中间件：校验后台token（与玩家token受众不同，不能混用）
 func AdminAuth() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		authHeader := strings.TrimSpace(c.GetHeader("Authorization"))
-		token := authHeader
-		if strings.HasPrefix(strings.ToLower(authHeader), "bearer ") {
-			token = strings.TrimSpace(authHeader[7:])
+		authHeader := c.GetHeader("Authorization")
+		parts := strings.SplitN(authHeader, " ", 2)
+		if !(len(parts) == 2 && parts[0] == "Bearer") {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "未授权"})
+			c.Abort()
+			return
 		}
 
-		if token != "1108" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "未授权"})
+		claims, err := utils.ParseAdminToken(strings.TrimSpace(parts[1]))
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "token无效"})
 			c.Abort()
 			return
 		}
 
+		// 每次请求都回查账号，停用或改角色后立即生效
+		var admin models.AdminUser
+		if err := database.DB.First(&admin, claims.AdminID).Error; err != nil || !admin.IsActive {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "账号不存在或已停用"})
+			c.Abort()
+			return
+		}
+
+		c.Set("adminID", admin.ID)
+		c.Set("adminUsername", admin.Username)
+		c.Set("adminRole", admin.Role)
+
 		c.Next()
 	}
 }
 
+// RequirePermission 要求当前管理员拥有指定权限，需放在 AdminAuth 之后
+func RequirePermission(permission string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !models.AdminHasPermission(c.GetString("adminRole"), permission) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "权限不足"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// AdminAudit 记录管理后台的每一次请求（包括认证失败和被拒绝的请求），需放在 AdminAuth 之前；
+// 管理员身份在请求处理完成后读取，认证失败时为空
+func AdminAudit() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		body := readAuditBody(c)
+
+		c.Next()
+
+		services.RecordAdminAudit(database.DB, &models.AdminAuditLog{
+			AdminID:    c.GetUint("adminID"),
+			AdminName:  c.GetString("adminUsername"),
+			Role:       c.GetString("adminRole"),
+			Method:     c.Request.Method,
+			Route:      c.FullPath(),
+			Path:       c.Request.URL.Path,
+			Query:      truncateString(c.Request.URL.RawQuery, 1000),
+			Body:       body,
+			IP:         c.ClientIP(),
+			StatusCode: c.Writer.Status(),
+		})
+	}
+}
+
+// readAuditBody 读取请求体用于审计（读取后放回，不影响后续绑定），密码字段脱敏
+func readAuditBody(c *gin.Context) string {
+	if c.Request.Body == nil {
+		return ""
+	}
+	raw, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		return ""
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(raw))
+	return truncateString(redactAuditBody(raw), adminAuditBodyLimit)
+}
+
+// redactAuditBody 将JSON请求体中的密码类字段（包括嵌套对象和数组中的字段）替换为 ***；
+// 非JSON请求体（表单等）无法逐字段脱敏，只记录长度
+func redactAuditBody(raw []byte) string {
+	if len(raw) == 0 {
+		return ""
+	}
+	var payload interface{}
+	if err := json.Unmarshal(raw, &payload); err != nil {
+		return fmt.Sprintf("<non-JSON body omitted, %d bytes>", len(raw))
+	}
+	redacted, err := json.Marshal(redactAuditValue(payload))
+	if err != nil {
+		return ""
+	}
+	return string(redacted)
+}
+
+// redactAuditValue 递归脱敏JSON值
+func redactAuditValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			if strings.Contains(strings.ToLower(key), "password") {
+				v[key] = "***"
+				continue
+			}
+			v[key] = redactAuditValue(item)
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = redactAuditValue(item)
+		}
+	}
+	return value
+}
+
+func truncateString(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	return s[:max]
+}
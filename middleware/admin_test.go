@@ -0,0 +1,23 @@
+package middleware
+
+import "testing"
+
+func TestRedactAuditBody(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+		want string
+	}{
+		{name: "空请求体", raw: "", want: ""},
+		{name: "密码字段", raw: `{"username":"admin","password":"secret"}`, want: `{"password":"***","username":"admin"}`},
+		{name: "嵌套字段", raw: `{"users":[{"new_password":"secret"}]}`, want: `{"users":[{"new_password":"***"}]}`},
+		{name: "表单请求体", raw: "username=admin&password=secret", want: "<non-JSON body omitted, 30 bytes>"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := redactAuditBody([]byte(tt.raw)); got != tt.want {
+				t.Fatalf("redactAuditBody(%q) = %q, want %q", tt.raw, got, tt.want)
+			}
+		})
+	}
+}
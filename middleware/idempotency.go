@@ -0,0 +1,351 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"ggo/database"
+	"ggo/models"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	idempotencyHeader = "Idempotency-Key"
+	idempotencyTTL    = 24 * time.Hour // 首次响应保留时间
+	// 处理中状态的最长占用时间，超时后重试可以接管该key并重新执行。服务没有设置写超时，
+	// 写接口都是秒级完成的数据库事务，占用时间远大于任何正常请求的耗时，避免首次请求仍在执行时被接管
+	idempotencyLockTTL         = 10 * time.Minute
+	idempotencyMaxKeySize      = 255
+	idempotencyCompleteRetries = 3 // 保存首次响应失败时的尝试次数
+
+	idempotencyProcessing = "processing"
+	idempotencyCompleted  = "completed"
+)
+
+// idempotencyEntry 一个 (用户, key) 的处理状态及首次响应
+type idempotencyEntry struct {
+	Fingerprint string `json:"fingerprint"`
+	Status      string `json:"status"`
+	Code        int    `json:"code,omitempty"`
+	ContentType string `json:"content_type,omitempty"`
+	Body        string `json:"body,omitempty"`
+}
+
+// Idempotency 幂等中间件：带 Idempotency-Key 请求头的写请求只执行一次，重试时回放首次响应
+// 需要放在 JWTAuth 之后使用，按 (userID, key) 区分
+func Idempotency() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := strings.TrimSpace(c.GetHeader(idempotencyHeader))
+		method := c.Request.Method
+		if key == "" || method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions {
+			c.Next()
+			return
+		}
+
+		if len(key) > idempotencyMaxKeySize {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Idempotency-Key过长"})
+			c.Abort()
+			return
+		}
+
+		userIDValue, exists := c.Get("userID")
+		if !exists {
+			c.Next()
+			return
+		}
+		userID := userIDValue.(uint)
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "读取请求体失败"})
+			c.Abort()
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		fingerprint := idempotencyFingerprint(method, c.Request.URL.Path, body)
+
+		ctx := context.Background()
+		store, err := newIdempotencyStore()
+		if err != nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "幂等服务未就绪"})
+			c.Abort()
+			return
+		}
+		existing, lock, err := store.acquire(ctx, userID, key, fingerprint)
+		if err != nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "幂等服务未就绪"})
+			c.Abort()
+			return
+		}
+
+		if existing != nil {
+			if existing.Fingerprint != fingerprint {
+				c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Idempotency-Key已用于其他请求"})
+				c.Abort()
+				return
+			}
+			if existing.Status != idempotencyCompleted {
+				c.JSON(http.StatusConflict, gin.H{"error": "相同请求正在处理中"})
+				c.Abort()
+				return
+			}
+
+			// 回放首次响应
+			c.Header("Idempotent-Replayed", "true")
+			c.Data(existing.Code, existing.ContentType, []byte(existing.Body))
+			c.Abort()
+			return
+		}
+
+		writer := &idempotencyWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+		c.Next()
+
+		// 服务端错误时事务已回滚，释放key让客户端可以重试
+		if writer.Status() >= http.StatusInternalServerError {
+			if err := store.release(ctx, userID, key, lock); err != nil {
+				log.Printf("Warning: Failed to release idempotency key %d/%s: %v", userID, key, err)
+			}
+			return
+		}
+
+		entry := &idempotencyEntry{
+			Fingerprint: fingerprint,
+			Status:      idempotencyCompleted,
+			Code:        writer.Status(),
+			ContentType: writer.Header().Get("Content-Type"),
+			Body:        writer.body.String(),
+		}
+		if err := store.complete(ctx, userID, key, lock, entry); err != nil {
+			// 请求已执行成功，不能释放key；记录保持处理中，重试返回409，直到占用超时
+			log.Printf("Error: Failed to save idempotent response %d/%s: %v", userID, key, err)
+		}
+	}
+}
+
+// idempotencyStore 幂等记录存储：Postgres 为唯一的记录来源，每个key的占用、完成和释放都只在Postgres中判定；
+// Redis 只缓存已完成的响应，用于加速回放，不可用时直接跳过
+type idempotencyStore struct {
+	db    *postgresIdempotencyStore
+	cache *redisIdempotencyCache
+}
+
+func newIdempotencyStore() (*idempotencyStore, error) {
+	if database.DB == nil {
+		return nil, errors.New("idempotency store unavailable")
+	}
+	store := &idempotencyStore{db: &postgresIdempotencyStore{db: database.DB}}
+	if database.RedisClient != nil {
+		store.cache = &redisIdempotencyCache{client: database.RedisClient}
+	}
+	return store, nil
+}
+
+// errIdempotencyLockLost 占用已超时并被重试请求接管，本次请求不能再修改该key的记录
+var errIdempotencyLockLost = errors.New("idempotency lock lost")
+
+// acquire 尝试占用key，占用成功返回nil和占用标记（占用的到期时间），否则返回已有记录
+func (s *idempotencyStore) acquire(ctx context.Context, userID uint, key, fingerprint string) (*idempotencyEntry, int64, error) {
+	if s.cache != nil {
+		if cached, err := s.cache.get(ctx, userID, key); err == nil && cached != nil {
+			return cached, 0, nil
+		}
+	}
+	return s.db.acquire(ctx, userID, key, fingerprint)
+}
+
+// complete 保存首次响应；写入Postgres失败时重试，Redis缓存写入失败不影响结果
+func (s *idempotencyStore) complete(ctx context.Context, userID uint, key string, lock int64, entry *idempotencyEntry) error {
+	var err error
+	for attempt := 0; attempt < idempotencyCompleteRetries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(time.Duration(attempt) * 100 * time.Millisecond)
+		}
+		if err = s.db.complete(ctx, userID, key, lock, entry); err == nil || errors.Is(err, errIdempotencyLockLost) {
+			break
+		}
+	}
+	if errors.Is(err, errIdempotencyLockLost) {
+		// key 已被接管，缓存本次响应会覆盖接管请求的结果
+		return err
+	}
+	if s.cache != nil {
+		// Postgres 写入失败时缓存仍能回放首次响应，降低重复执行的可能
+		if cacheErr := s.cache.set(ctx, userID, key, entry); cacheErr != nil && err == nil {
+			log.Printf("Warning: Failed to cache idempotent response %d/%s: %v", userID, key, cacheErr)
+		}
+	}
+	return err
+}
+
+// release 释放占用，允许客户端重试
+func (s *idempotencyStore) release(ctx context.Context, userID uint, key string, lock int64) error {
+	return s.db.release(ctx, userID, key, lock)
+}
+
+// idempotencyFingerprint 计算请求指纹，防止同一个key被用于不同的请求
+func idempotencyFingerprint(method, path string, body []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(method))
+	hash.Write([]byte{0})
+	hash.Write([]byte(path))
+	hash.Write([]byte{0})
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// idempotencyWriter 记录响应体，便于保存首次响应
+type idempotencyWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *idempotencyWriter) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *idempotencyWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// redisIdempotencyCache 已完成响应的Redis缓存
+type redisIdempotencyCache struct {
+	client *redis.Client
+}
+
+func redisIdempotencyKey(userID uint, key string) string {
+	return fmt.Sprintf("idempotency:%d:%s", userID, key)
+}
+
+// get 读取缓存的已完成响应，未缓存时返回nil
+func (s *redisIdempotencyCache) get(ctx context.Context, userID uint, key string) (*idempotencyEntry, error) {
+	raw, err := s.client.Get(ctx, redisIdempotencyKey(userID, key)).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var entry idempotencyEntry
+	if err := json.Unmarshal([]byte(raw), &entry); err != nil {
+		return nil, err
+	}
+	if entry.Status != idempotencyCompleted {
+		return nil, nil
+	}
+	return &entry, nil
+}
+
+func (s *redisIdempotencyCache) set(ctx context.Context, userID uint, key string, entry *idempotencyEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	return s.client.Set(ctx, redisIdempotencyKey(userID, key), data, idempotencyTTL).Err()
+}
+
+// postgresIdempotencyStore 基于Postgres的幂等记录存储
+type postgresIdempotencyStore struct {
+	db *gorm.DB
+}
+
+// acquire 占用key。key已存在时在同一事务内锁定记录并判断状态：
+// 未过期的记录（处理中或已完成）原样返回；过期的记录由本次请求接管，以新的占用标记重新进入处理中，
+// 首次请求若仍在执行，它的 complete/release 因占用标记不匹配而不会覆盖或删除接管后的记录
+func (s *postgresIdempotencyStore) acquire(ctx context.Context, userID uint, key, fingerprint string) (*idempotencyEntry, int64, error) {
+	now := time.Now()
+	lock := now.Add(idempotencyLockTTL).Unix()
+
+	var existing *idempotencyEntry
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		record := models.IdempotencyRecord{
+			UserID:      userID,
+			Key:         key,
+			Fingerprint: fingerprint,
+			Status:      idempotencyProcessing,
+			ExpiresAt:   lock,
+		}
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&record)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 1 {
+			return nil
+		}
+
+		var current models.IdempotencyRecord
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("user_id = ? AND key = ?", userID, key).First(&current).Error; err != nil {
+			return err
+		}
+		if current.ExpiresAt >= now.Unix() {
+			existing = &idempotencyEntry{
+				Fingerprint: current.Fingerprint,
+				Status:      current.Status,
+				Code:        current.ResponseStatus,
+				ContentType: current.ResponseType,
+				Body:        current.ResponseBody,
+			}
+			return nil
+		}
+		return tx.Model(&models.IdempotencyRecord{}).Where("id = ?", current.ID).
+			Updates(map[string]interface{}{
+				"fingerprint":     fingerprint,
+				"status":          idempotencyProcessing,
+				"response_status": 0,
+				"response_type":   "",
+				"response_body":   "",
+				"expires_at":      lock,
+			}).Error
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	if existing != nil {
+		return existing, 0, nil
+	}
+	return nil, lock, nil
+}
+
+// complete 保存首次响应，只更新本次请求仍然占用的处理中记录
+func (s *postgresIdempotencyStore) complete(ctx context.Context, userID uint, key string, lock int64, entry *idempotencyEntry) error {
+	result := s.db.WithContext(ctx).Model(&models.IdempotencyRecord{}).
+		Where("user_id = ? AND key = ? AND status = ? AND expires_at = ?", userID, key, idempotencyProcessing, lock).
+		Updates(map[string]interface{}{
+			"status":          entry.Status,
+			"response_status": entry.Code,
+			"response_type":   entry.ContentType,
+			"response_body":   entry.Body,
+			"expires_at":      time.Now().Add(idempotencyTTL).Unix(),
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errIdempotencyLockLost
+	}
+	return nil
+}
+
+// release 删除本次请求占用的处理中记录
+func (s *postgresIdempotencyStore) release(ctx context.Context, userID uint, key string, lock int64) error {
+	return s.db.WithContext(ctx).
+		Where("user_id = ? AND key = ? AND status = ? AND expires_at = ?", userID, key, idempotencyProcessing, lock).
+		Delete(&models.IdempotencyRecord{}).Error
+}
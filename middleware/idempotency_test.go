@@ -0,0 +1,73 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"ggo/models"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func newTestIdempotencyStore(t *testing.T) *postgresIdempotencyStore {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("open test db: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("open test db: %v", err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+	if err := db.AutoMigrate(&models.IdempotencyRecord{}); err != nil {
+		t.Fatalf("migrate test db: %v", err)
+	}
+	return &postgresIdempotencyStore{db: db}
+}
+
+func TestIdempotencyStoreTakeover(t *testing.T) {
+	s := newTestIdempotencyStore(t)
+	ctx := context.Background()
+
+	existing, first, err := s.acquire(ctx, 1, "key", "fp")
+	if err != nil || existing != nil || first == 0 {
+		t.Fatalf("first acquire = %+v, %d, %v", existing, first, err)
+	}
+
+	// 占用未超时：重试返回处理中
+	existing, lock, err := s.acquire(ctx, 1, "key", "fp")
+	if err != nil || existing == nil || existing.Status != idempotencyProcessing || lock != 0 {
+		t.Fatalf("retry while processing = %+v, %d, %v", existing, lock, err)
+	}
+
+	// 占用超时后由重试接管（模拟首次请求的占用已到期）
+	first = time.Now().Add(-time.Second).Unix()
+	s.db.Model(&models.IdempotencyRecord{}).Where("key = ?", "key").Update("expires_at", first)
+	existing, second, err := s.acquire(ctx, 1, "key", "fp")
+	if err != nil || existing != nil || second <= first {
+		t.Fatalf("takeover = %+v, %d, %v", existing, second, err)
+	}
+
+	// 被接管的首次请求不能释放或完成接管后的记录
+	if err := s.release(ctx, 1, "key", first); err != nil {
+		t.Fatal(err)
+	}
+	stale := &idempotencyEntry{Fingerprint: "fp", Status: idempotencyCompleted, Code: 200, Body: "stale"}
+	if err := s.complete(ctx, 1, "key", first, stale); !errors.Is(err, errIdempotencyLockLost) {
+		t.Fatalf("stale complete err = %v, want %v", err, errIdempotencyLockLost)
+	}
+
+	entry := &idempotencyEntry{Fingerprint: "fp", Status: idempotencyCompleted, Code: 200, Body: "ok"}
+	if err := s.complete(ctx, 1, "key", second, entry); err != nil {
+		t.Fatalf("complete: %v", err)
+	}
+	existing, _, err = s.acquire(ctx, 1, "key", "fp")
+	if err != nil || existing == nil || existing.Status != idempotencyCompleted || existing.Body != "ok" {
+		t.Fatalf("replay = %+v, %v", existing, err)
+	}
+}
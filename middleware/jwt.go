@@ -1,9 +1,8 @@
 package middleware
 
 import (
-	"context"
-	"fmt"
 	"ggo/database"
+	"ggo/services"
 	"ggo/utils"
 	"net/http"
 	"strings"
@@ -44,16 +43,18 @@ func JWTAuth() gin.HandlerFunc {
 			return
 		}
 
-		currentToken, err := database.RedisClient.Get(context.Background(), fmt.Sprintf("auth:token:%d", claims.UserID)).Result()
-		if err != nil || currentToken != token {
+		// 会话被吊销（退出登录、踢下线、单设备登录）后token立即作废
+		if !services.IsSessionActive(claims.UserID, claims.SessionID) {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "token已作废"})
 			c.Abort()
 			return
 		}
+		services.TouchSession(claims.SessionID, c.ClientIP())
 
 		// 将用户信息存入context
 		c.Set("userID", claims.UserID)
 		c.Set("username", claims.Username)
+		c.Set("sessionID", claims.SessionID)
 
 		c.Next()
 	}
@@ -73,13 +74,7 @@ func OptionalJWTAuth() gin.HandlerFunc {
 			token := parts[1]
 			claims, err := utils.ParseToken(token)
 			if err == nil {
-				if database.RedisClient == nil {
-					c.Next()
-					return
-				}
-
-				currentToken, err := database.RedisClient.Get(context.Background(), fmt.Sprintf("auth:token:%d", claims.UserID)).Result()
-				if err != nil || currentToken != token {
+				if !services.IsSessionActive(claims.UserID, claims.SessionID) {
 					c.Next()
 					return
 				}
@@ -87,6 +82,7 @@ func OptionalJWTAuth() gin.HandlerFunc {
 				// 将用户信息存入context
 				c.Set("userID", claims.UserID)
 				c.Set("username", claims.Username)
+				c.Set("sessionID", claims.SessionID)
 			}
 		}
 
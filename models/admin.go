@@ -0,0 +1,81 @@
+package models
+
+// 管理员角色
+const (
+	AdminRoleGM       = "gm"       // GM：全部权限
+	AdminRoleOperator = "operator" // 运营：查看用户、发送邮件
+	AdminRoleViewer   = "viewer"   // 只读：查看数据
+)
+
+// 管理后台权限
+const (
+	PermUserRead        = "user:read"         // 查看用户
+	PermUserWrite       = "user:write"        // 创建/删除用户
+	PermMailSend        = "mail:send"         // 发送邮件
+	PermCurrencyRead    = "currency:read"     // 查看货币流水/对账
+	PermAdminManage     = "admin:manage"      // 管理后台账号
+	PermAuditLogRead    = "audit_log:read"    // 查看操作日志
+	PermDropTableManage = "drop_table:manage" // 管理掉落表
+	PermConfigManage    = "config:manage"     // 管理玩法配置（词条等）
+)
+
+// AdminRolePermissions 各角色拥有的权限
+var AdminRolePermissions = map[string][]string{
+	AdminRoleGM:       {PermUserRead, PermUserWrite, PermMailSend, PermCurrencyRead, PermAdminManage, PermAuditLogRead, PermDropTableManage, PermConfigManage},
+	AdminRoleOperator: {PermUserRead, PermMailSend, PermCurrencyRead},
+	AdminRoleViewer:   {PermUserRead, PermCurrencyRead},
+}
+
+// AdminHasPermission 判断角色是否拥有指定权限
+func AdminHasPermission(role, permission string) bool {
+	for _, p := range AdminRolePermissions[role] {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// IsValidAdminRole 判断角色是否有效
+func IsValidAdminRole(role string) bool {
+	_, ok := AdminRolePermissions[role]
+	return ok
+}
+
+// AdminUser 管理后台账号（与玩家账号完全独立）
+type AdminUser struct {
+	ID        uint   `json:"id" gorm:"primarykey"`
+	Username  string `json:"username" gorm:"size:50;uniqueIndex;not null"` // 账号
+	Password  string `json:"-" gorm:"size:255;not null"`                   // 密码哈希（不序列化到JSON）
+	Role      string `json:"role" gorm:"size:20;not null"`                 // 角色：gm, operator, viewer
+	IsActive  bool   `json:"is_active" gorm:"not null;default:true"`       // 是否启用
+	LastLogin int64  `json:"last_login" gorm:"default:0"`                  // 最后登录时间
+	CreatedAt int64  `json:"created_at" gorm:"autoCreateTime"`             // 创建时间
+	UpdatedAt int64  `json:"updated_at" gorm:"autoUpdateTime"`             // 更新时间
+}
+
+// TableName 指定表名
+func (AdminUser) TableName() string {
+	return "admin_users"
+}
+
+// AdminAuditLog 管理后台操作日志
+type AdminAuditLog struct {
+	ID         uint   `json:"id" gorm:"primarykey"`
+	AdminID    uint   `json:"admin_id" gorm:"not null;index"`         // 管理员ID（登录失败时为0）
+	AdminName  string `json:"admin_name" gorm:"size:50"`              // 管理员账号
+	Role       string `json:"role" gorm:"size:20"`                    // 操作时的角色
+	Method     string `json:"method" gorm:"size:10;not null"`         // 请求方法
+	Route      string `json:"route" gorm:"size:200;index"`            // 路由模板，如 /api/v1/admin/users/:id
+	Path       string `json:"path" gorm:"size:500"`                   // 实际请求路径
+	Query      string `json:"query" gorm:"size:1000"`                 // 查询参数
+	Body       string `json:"body" gorm:"type:text"`                  // 请求体（密码字段已脱敏）
+	IP         string `json:"ip" gorm:"size:64"`                      // 来源IP
+	StatusCode int    `json:"status_code" gorm:"not null"`            // 响应状态码
+	CreatedAt  int64  `json:"created_at" gorm:"autoCreateTime;index"` // 操作时间
+}
+
+// TableName 指定表名
+func (AdminAuditLog) TableName() string {
+	return "admin_audit_logs"
+}
@@ -0,0 +1,50 @@
+package models
+
+// 词条池：同一词条在不同玩法中可以有不同的数值范围和权重
+const (
+	AffixPoolForge = "forge" // 打造装备时随机的词条
+	AffixPoolMerge = "merge" // 融合装备时新增的词条
+)
+
+// 词条品质
+const (
+	AffixTierCommon = "common" // 普通词条
+	AffixTierRare   = "rare"   // 稀有词条（七宗罪）
+)
+
+// AffixDefinition 装备附加属性（词条）定义，每种属性类型一条
+type AffixDefinition struct {
+	ID          uint         `json:"id" gorm:"primarykey"`
+	AttrType    string       `json:"attr_type" gorm:"size:20;uniqueIndex;not null"` // 属性类型，写入 EquipmentAdditionalAttr.AttrType
+	Name        string       `json:"name" gorm:"size:20;not null"`                  // 效果名称，如 攻击力加成
+	Title       string       `json:"title" gorm:"size:20"`                          // 稀有词条称号，如 傲慢（普通词条为空）
+	Tier        string       `json:"tier" gorm:"size:20;not null"`                  // 品质：common, rare
+	IsPercent   bool         `json:"is_percent" gorm:"default:false"`               // 是否百分比数值
+	Decimals    int          `json:"decimals" gorm:"default:0"`                     // 显示保留的小数位
+	Description string       `json:"description" gorm:"size:200"`                   // 说明
+	IsActive    bool         `json:"is_active" gorm:"default:true"`                 // 是否启用
+	Ranges      []AffixRange `json:"ranges" gorm:"foreignKey:AffixDefinitionID"`
+	CreatedAt   int64        `json:"created_at" gorm:"autoCreateTime"` // 创建时间
+	UpdatedAt   int64        `json:"updated_at" gorm:"autoUpdateTime"` // 更新时间
+}
+
+// TableName 指定表名
+func (AffixDefinition) TableName() string {
+	return "affix_definitions"
+}
+
+// AffixRange 词条在某个词条池、某个装备品级下的数值范围和权重
+type AffixRange struct {
+	ID                uint    `json:"id" gorm:"primarykey"`
+	AffixDefinitionID uint    `json:"affix_definition_id" gorm:"not null;index"`
+	Pool              string  `json:"pool" gorm:"size:20;not null"`     // 词条池：forge, merge
+	Level             int     `json:"level" gorm:"not null;default:0"`  // 装备品级1-6，0表示所有品级通用
+	Min               float64 `json:"min" gorm:"not null"`              // 最小值
+	Max               float64 `json:"max" gorm:"not null"`              // 最大值
+	Weight            int     `json:"weight" gorm:"not null;default:1"` // 权重
+}
+
+// TableName 指定表名
+func (AffixRange) TableName() string {
+	return "affix_ranges"
+}
@@ -0,0 +1,49 @@
+package models
+
+// 重铸方式
+const (
+	ReforgeModeValue = "value" // 在原范围内重新随机数值
+	ReforgeModeType  = "type"  // 替换为同品质的其他词条
+)
+
+// 重铸状态
+const (
+	ReforgeStatusPending   = "pending"   // 待确认（装备词条未改变）
+	ReforgeStatusAccepted  = "accepted"  // 已接受，结果已写入装备
+	ReforgeStatusDiscarded = "discarded" // 已放弃，保留原词条
+	ReforgeStatusExpired   = "expired"   // 超时未确认，保留原词条
+)
+
+// AffixReforge 词条重铸记录：先生成预览并扣除消耗，玩家接受后才写入装备
+type AffixReforge struct {
+	ID              uint                 `json:"id" gorm:"primarykey"`
+	UserID          uint                 `json:"user_id" gorm:"not null;index"`             // 用户ID
+	UserEquipmentID uint                 `json:"user_equipment_id" gorm:"not null;index"`   // 玩家装备ID
+	AttrID          uint                 `json:"attr_id" gorm:"not null"`                   // 选择重铸的词条ID
+	Mode            string               `json:"mode" gorm:"size:10;not null"`              // 重铸方式：value, type
+	LockOthers      bool                 `json:"lock_others" gorm:"not null;default:false"` // 是否锁定其他词条
+	CostGold        int                  `json:"cost_gold" gorm:"not null;default:0"`       // 消耗金币
+	CostTreasures   int                  `json:"cost_treasures" gorm:"not null;default:0"`  // 消耗宝物数量
+	Changes         []AffixReforgeChange `json:"changes" gorm:"type:json;serializer:json"`  // 词条变化（预览结果）
+	Status          string               `json:"status" gorm:"size:20;not null;index"`      // 状态：pending, accepted, discarded, expired
+	ExpiresAt       int64                `json:"expires_at" gorm:"not null"`                // 预览过期时间
+	CreatedAt       int64                `json:"created_at" gorm:"autoCreateTime"`          // 创建时间
+	UpdatedAt       int64                `json:"updated_at" gorm:"autoUpdateTime"`          // 更新时间
+}
+
+// AffixReforgeChange 单条词条的变化
+type AffixReforgeChange struct {
+	AttrID         uint    `json:"attr_id"`         // 词条ID
+	OldType        string  `json:"old_type"`        // 原属性类型
+	OldValue       float64 `json:"old_value"`       // 原数值
+	NewType        string  `json:"new_type"`        // 新属性类型
+	NewName        string  `json:"new_name"`        // 新属性名称（稀有词条称号）
+	NewValue       float64 `json:"new_value"`       // 新数值
+	IsPercent      bool    `json:"is_percent"`      // 新数值是否百分比
+	RulesetVersion int     `json:"ruleset_version"` // 新词条的规则集版本
+}
+
+// TableName 指定表名
+func (AffixReforge) TableName() string {
+	return "affix_reforges"
+}
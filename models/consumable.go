@@ -0,0 +1,29 @@
+package models
+
+// 消耗品类型
+const (
+	ConsumableEnhanceProtect = "enhance_protect" // 强化保护符：强化失败时不降级
+	ConsumableEnhanceLuck    = "enhance_luck"    // 幸运符：强化成功率增加 Value（0-1）
+)
+
+// MyItem.ItemType 为 consumable 时，ItemID 为消耗品ID
+const MyItemTypeConsumable = "consumable"
+
+// Consumable 消耗品定义
+type Consumable struct {
+	ID          uint    `json:"id" gorm:"primarykey"`
+	Name        string  `json:"name" gorm:"size:100;not null"`    // 名称
+	Kind        string  `json:"kind" gorm:"size:30;not null"`     // 类型：enhance_protect, enhance_luck
+	Value       float64 `json:"value" gorm:"default:0"`           // 效果数值，如幸运符增加的成功率
+	MaxStack    int     `json:"max_stack" gorm:"default:999"`     // 单个堆叠的数量上限
+	ImageURL    string  `json:"image_url" gorm:"size:500"`        // 图片
+	Description string  `json:"description" gorm:"size:500"`      // 描述
+	IsActive    bool    `json:"is_active" gorm:"default:true"`    // 是否激活
+	CreatedAt   int64   `json:"created_at" gorm:"autoCreateTime"` // 创建时间
+	UpdatedAt   int64   `json:"updated_at" gorm:"autoUpdateTime"` // 更新时间
+}
+
+// TableName 指定表名
+func (Consumable) TableName() string {
+	return "consumables"
+}
@@ -0,0 +1,45 @@
+package models
+
+// 货币类型
+const (
+	CurrencyGold    = "gold"    // 金币
+	CurrencyDiamond = "diamond" // 钻石
+)
+
+// 货币变动原因
+const (
+	CurrencyReasonOpeningBalance    = "opening_balance"    // 期初余额（账本上线前的存量）
+	CurrencyReasonRegister          = "register"           // 新用户注册赠送
+	CurrencyReasonAdminCreate       = "admin_create"       // 管理员创建用户
+	CurrencyReasonEquipmentForge    = "equipment_forge"    // 打造装备
+	CurrencyReasonEquipmentEnhance  = "equipment_enhance"  // 强化装备
+	CurrencyReasonEquipmentRecycle  = "equipment_recycle"  // 回收装备
+	CurrencyReasonAffixReforge      = "affix_reforge"      // 重铸词条
+	CurrencyReasonEquipmentIdentify = "equipment_identify" // 鉴定装备
+	CurrencyReasonInventoryExpand   = "inventory_expand"   // 扩充背包/仓库
+	CurrencyReasonTreasureSell      = "treasure_sell"      // 出售宝物
+	CurrencyReasonMailClaim         = "mail_claim"         // 领取邮件
+	CurrencyReasonMarketBuy         = "market_buy"         // 交易行购买
+	CurrencyReasonMarketSale        = "market_sale"        // 交易行售出（已扣手续费）
+	CurrencyReasonShopBuy           = "shop_buy"           // 神秘商店购买
+	CurrencyReasonShopRefresh       = "shop_refresh"       // 神秘商店付费刷新
+	CurrencyReasonRunReward         = "run_reward"         // 对局结算
+)
+
+// CurrencyTransaction 货币流水（只追加，不修改）
+type CurrencyTransaction struct {
+	ID           uint   `json:"id" gorm:"primarykey"`
+	UserID       uint   `json:"user_id" gorm:"not null;index:idx_currency_tx_user_currency"`          // 用户ID
+	Currency     string `json:"currency" gorm:"size:20;not null;index:idx_currency_tx_user_currency"` // 货币类型：gold(金币), diamond(钻石)
+	Delta        int    `json:"delta" gorm:"not null"`                                                // 变动值，正数为增加，负数为扣除
+	BalanceAfter int    `json:"balance_after" gorm:"not null"`                                        // 变动后余额
+	Reason       string `json:"reason" gorm:"size:50;not null;index"`                                 // 变动原因
+	SourceType   string `json:"source_type" gorm:"size:50;default:''"`                                // 来源实体类型，如 user_equipment、mail
+	SourceID     uint   `json:"source_id" gorm:"default:0"`                                           // 来源实体ID
+	CreatedAt    int64  `json:"created_at" gorm:"autoCreateTime"`                                     // 创建时间
+}
+
+// TableName 指定表名
+func (CurrencyTransaction) TableName() string {
+	return "currency_transactions"
+}
@@ -0,0 +1,86 @@
+package models
+
+// 掉落物类型
+const (
+	DropKindTreasure   = "treasure"   // 宝物，RefID 为宝物ID
+	DropKindEquipment  = "equipment"  // 装备，RefID 为装备模板ID
+	DropKindGold       = "gold"       // 金币
+	DropKindDiamond    = "diamond"    // 钻石
+	DropKindConsumable = "consumable" // 消耗品，RefID 为消耗品ID
+)
+
+// 掉落来源类型
+const (
+	DropSourceMonster = "monster" // 怪物，SourceID 为怪物ID
+	DropSourceScene   = "scene"   // 场景，SourceID 为场景ID
+	DropSourceBoss    = "boss"    // 首领，SourceID 为0表示每日首领
+)
+
+// DropTable 掉落表：保底条目必掉，其余条目按权重抽取 Rolls 次
+type DropTable struct {
+	ID          uint             `json:"id" gorm:"primarykey"`
+	Name        string           `json:"name" gorm:"size:100;not null"`         // 名称
+	Description string           `json:"description" gorm:"size:500"`           // 描述
+	Rolls       int              `json:"rolls" gorm:"not null;default:1"`       // 每次掉落抽取次数
+	NoneWeight  int              `json:"none_weight" gorm:"not null;default:0"` // 不掉落的权重
+	IsActive    bool             `json:"is_active" gorm:"default:true"`         // 是否启用
+	Entries     []DropTableEntry `json:"entries" gorm:"foreignKey:DropTableID"`
+	CreatedAt   int64            `json:"created_at" gorm:"autoCreateTime"` // 创建时间
+	UpdatedAt   int64            `json:"updated_at" gorm:"autoUpdateTime"` // 更新时间
+}
+
+// TableName 指定表名
+func (DropTable) TableName() string {
+	return "drop_tables"
+}
+
+// DropTableEntry 掉落条目
+type DropTableEntry struct {
+	ID          uint   `json:"id" gorm:"primarykey"`
+	DropTableID uint   `json:"drop_table_id" gorm:"not null;index"`
+	Kind        string `json:"kind" gorm:"size:20;not null"`      // 类型：treasure, equipment, gold, diamond
+	RefID       uint   `json:"ref_id" gorm:"default:0"`           // 宝物ID或装备模板ID，金币/钻石为0
+	MinQty      int    `json:"min_qty" gorm:"not null;default:1"` // 最小数量
+	MaxQty      int    `json:"max_qty" gorm:"not null;default:1"` // 最大数量（金币范围即 MinQty~MaxQty）
+	Weight      int    `json:"weight" gorm:"not null;default:1"`  // 权重（保底条目忽略）
+	Guaranteed  bool   `json:"guaranteed" gorm:"default:false"`   // 是否保底必掉
+	MinLevel    int    `json:"min_level" gorm:"default:0"`        // 玩家最低等级，0不限
+	MaxLevel    int    `json:"max_level" gorm:"default:0"`        // 玩家最高等级，0不限
+}
+
+// TableName 指定表名
+func (DropTableEntry) TableName() string {
+	return "drop_table_entries"
+}
+
+// DropTableBinding 掉落表挂载到怪物/场景/首领
+type DropTableBinding struct {
+	ID          uint   `json:"id" gorm:"primarykey"`
+	DropTableID uint   `json:"drop_table_id" gorm:"not null;uniqueIndex:idx_drop_binding"`
+	SourceType  string `json:"source_type" gorm:"size:20;not null;uniqueIndex:idx_drop_binding;index:idx_drop_binding_source"` // 来源：monster, scene, boss
+	SourceID    uint   `json:"source_id" gorm:"not null;uniqueIndex:idx_drop_binding;index:idx_drop_binding_source"`           // 来源ID
+	CreatedAt   int64  `json:"created_at" gorm:"autoCreateTime"`
+}
+
+// TableName 指定表名
+func (DropTableBinding) TableName() string {
+	return "drop_table_bindings"
+}
+
+// IsValidDropKind 判断掉落物类型是否有效
+func IsValidDropKind(kind string) bool {
+	switch kind {
+	case DropKindTreasure, DropKindEquipment, DropKindGold, DropKindDiamond, DropKindConsumable:
+		return true
+	}
+	return false
+}
+
+// IsValidDropSource 判断掉落来源类型是否有效
+func IsValidDropSource(sourceType string) bool {
+	switch sourceType {
+	case DropSourceMonster, DropSourceScene, DropSourceBoss:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,28 @@
+package models
+
+// EnhanceLog 装备强化记录，每次强化（无论成败）一条，用于客服查询
+type EnhanceLog struct {
+	ID              uint    `json:"id" gorm:"primarykey"`
+	UserID          uint    `json:"user_id" gorm:"not null;index"`           // 玩家ID
+	UserEquipmentID uint    `json:"user_equipment_id" gorm:"not null;index"` // 玩家装备ID
+	EquipmentID     uint    `json:"equipment_id" gorm:"not null"`            // 装备模板ID
+	Rarity          int     `json:"rarity" gorm:"not null"`                  // 装备品级
+	OldLevel        int     `json:"old_level" gorm:"not null"`               // 强化前等级
+	NewLevel        int     `json:"new_level" gorm:"not null"`               // 强化后等级
+	Success         bool    `json:"success" gorm:"not null"`                 // 是否成功
+	BaseRate        float64 `json:"base_rate" gorm:"not null"`               // 基础成功率
+	FinalRate       float64 `json:"final_rate" gorm:"not null"`              // 计入保底和幸运符后的成功率
+	FailCount       int     `json:"fail_count" gorm:"not null"`              // 强化前的连续失败次数
+	Guaranteed      bool    `json:"guaranteed" gorm:"not null"`              // 是否触发保底必成
+	ProtectItemID   uint    `json:"protect_item_id" gorm:"default:0"`        // 使用的保护符（消耗品ID）
+	LuckItemID      uint    `json:"luck_item_id" gorm:"default:0"`           // 使用的幸运符（消耗品ID）
+	Protected       bool    `json:"protected" gorm:"not null"`               // 失败时是否因保护符未降级
+	Breakthrough    bool    `json:"breakthrough" gorm:"not null"`            // 是否为突破
+	CostGold        int     `json:"cost_gold" gorm:"not null"`               // 消耗金币
+	CreatedAt       int64   `json:"created_at" gorm:"autoCreateTime;index"`  // 强化时间
+}
+
+// TableName 指定表名
+func (EnhanceLog) TableName() string {
+	return "enhance_logs"
+}
@@ -1,23 +1,45 @@
 package models
 
+import (
+	"strconv"
+
+	"gorm.io/gorm"
+)
+
 type EquipmentAdditionalAttr struct {
 	ID              uint   `json:"id" gorm:"primarykey"`
 	UserEquipmentID uint   `json:"user_equipment_id" gorm:"not null;index"` // 玩家装备ID
-	AttrType        string `json:"attr_type" gorm:"size:20;not null"`       // 属性类型：
-	// hp - 生命值，增加角色的最大生命值
-	// attack - 攻击力，增加角色的基础攻击力
-	// attack_speed - 攻击速度，增加角色的攻击频率
-	// move_speed - 移动速度，增加角色的移动速度
-	// bullet_speed - 子弹速度，增加角色发射子弹的飞行速度
-	// drain - 吸血，攻击时吸取敌方生命值的百分比
-	// critical - 暴击率，增加攻击暴击的概率
-	// dodge - 闪避率，减少被敌方攻击命中的概率
-	// instant_kill - 秒杀概率，攻击时有概率直接秒杀敌人
-	// recovery - 生命恢复，每秒自动恢复的生命值
-	// trajectory - 弹道数，增加角色一次发射的子弹数量
-	// enhance - 强化加成，增加装备的总体属性效果
-	AttrName  string `json:"attr_name" gorm:"size:20"`           // 属性名称（用于显示稀有属性的名称，如七宗罪）
-	AttrValue string `json:"attr_value" gorm:"size:50;not null"` // 属性值（字符串存储，可能是数字或描述）
-	CreatedAt int64  `json:"created_at" gorm:"autoCreateTime"`   // 创建时间
-	UpdatedAt int64  `json:"updated_at" gorm:"autoUpdateTime"`   // 更新时间
+	AttrType        string `json:"attr_type" gorm:"size:20;not null"`       // 属性类型，对应 AffixDefinition.AttrType：
+	// 普通词条：hp, hp_pct, attack, attack_pct, attack_speed, bullet_speed, drain, critical,
+	// critical_rate, damage_reduction, recovery, instant_kill, trajectory
+	// 稀有词条（七宗罪）：sloth, envy, gluttony, greed, lust, wrath, pride
+	// 名称、显示小数位等以 affix_definitions 为准
+	AttrName       string  `json:"attr_name" gorm:"size:20"`                 // 属性名称（用于显示稀有属性的名称，如七宗罪）
+	Value          float64 `json:"value" gorm:"not null;default:0"`          // 属性数值，百分比词条存百分数（12.5 表示 12.5%）
+	IsPercent      bool    `json:"is_percent" gorm:"not null;default:false"` // 是否百分比数值
+	AttrValue      string  `json:"attr_value" gorm:"-"`                      // 显示用的属性值，由 Value/IsPercent 计算，不入库
+	RulesetVersion int     `json:"ruleset_version" gorm:"default:0"`         // 生成该词条时的词条规则集版本，0为规则集上线前生成
+	CreatedAt      int64   `json:"created_at" gorm:"autoCreateTime"`         // 创建时间
+	UpdatedAt      int64   `json:"updated_at" gorm:"autoUpdateTime"`         // 更新时间
+}
+
+// FormatValue 格式化属性值，百分比词条带 % 后缀
+func (a *EquipmentAdditionalAttr) FormatValue() string {
+	formatted := strconv.FormatFloat(a.Value, 'f', -1, 64)
+	if a.IsPercent {
+		return formatted + "%"
+	}
+	return formatted
+}
+
+// AfterFind 查询后填充显示用的属性值
+func (a *EquipmentAdditionalAttr) AfterFind(tx *gorm.DB) error {
+	a.AttrValue = a.FormatValue()
+	return nil
+}
+
+// AfterCreate 创建后填充显示用的属性值
+func (a *EquipmentAdditionalAttr) AfterCreate(tx *gorm.DB) error {
+	a.AttrValue = a.FormatValue()
+	return nil
 }
@@ -0,0 +1,20 @@
+package models
+
+// EquipmentRecycleConfig 装备回收价格配置，按装备模板品级一条
+// 回收获得钻石 = 基础钻石 + 强化等级 × 每级强化钻石 + 普通词条数 × 普通词条钻石 + 稀有词条数 × 稀有词条钻石
+type EquipmentRecycleConfig struct {
+	ID                 uint   `json:"id" gorm:"primarykey"`
+	Level              int    `json:"level" gorm:"uniqueIndex;not null"`              // 装备品级1-6
+	BaseDiamond        int    `json:"base_diamond" gorm:"not null;default:0"`         // 基础钻石
+	EnhanceDiamond     int    `json:"enhance_diamond" gorm:"not null;default:0"`      // 每级强化增加的钻石
+	CommonAffixDiamond int    `json:"common_affix_diamond" gorm:"not null;default:0"` // 每条普通词条增加的钻石
+	RareAffixDiamond   int    `json:"rare_affix_diamond" gorm:"not null;default:0"`   // 每条稀有词条增加的钻石
+	UpdatedBy          string `json:"updated_by" gorm:"size:50"`                      // 最后修改的管理员
+	CreatedAt          int64  `json:"created_at" gorm:"autoCreateTime"`               // 创建时间
+	UpdatedAt          int64  `json:"updated_at" gorm:"autoUpdateTime"`               // 更新时间
+}
+
+// TableName 指定表名
+func (EquipmentRecycleConfig) TableName() string {
+	return "equipment_recycle_configs"
+}
@@ -0,0 +1,44 @@
+package models
+
+// EquipmentSet 套装：若干装备模板组成一套，穿戴件数达到要求时获得套装加成
+type EquipmentSet struct {
+	ID          uint                `json:"id" gorm:"primarykey"`
+	Name        string              `json:"name" gorm:"size:50;not null"`  // 套装名称
+	Description string              `json:"description" gorm:"size:500"`   // 描述
+	IsActive    bool                `json:"is_active" gorm:"default:true"` // 是否启用，停用后不再生效
+	Items       []EquipmentSetItem  `json:"items" gorm:"foreignKey:EquipmentSetID"`
+	Bonuses     []EquipmentSetBonus `json:"bonuses" gorm:"foreignKey:EquipmentSetID"`
+	CreatedAt   int64               `json:"created_at" gorm:"autoCreateTime"` // 创建时间
+	UpdatedAt   int64               `json:"updated_at" gorm:"autoUpdateTime"` // 更新时间
+}
+
+// TableName 指定表名
+func (EquipmentSet) TableName() string {
+	return "equipment_sets"
+}
+
+// EquipmentSetItem 套装包含的装备模板，每个模板最多属于一个套装
+type EquipmentSetItem struct {
+	ID                  uint `json:"id" gorm:"primarykey"`
+	EquipmentSetID      uint `json:"equipment_set_id" gorm:"not null;index"`
+	EquipmentTemplateID uint `json:"equipment_template_id" gorm:"not null;uniqueIndex"` // 装备模板ID
+}
+
+// TableName 指定表名
+func (EquipmentSetItem) TableName() string {
+	return "equipment_set_items"
+}
+
+// EquipmentSetBonus 套装加成：穿戴件数达到 Pieces 时生效，属性类型与装备词条相同
+type EquipmentSetBonus struct {
+	ID             uint    `json:"id" gorm:"primarykey"`
+	EquipmentSetID uint    `json:"equipment_set_id" gorm:"not null;index"`
+	Pieces         int     `json:"pieces" gorm:"not null"`            // 需要的件数：2、4、6
+	AttrType       string  `json:"attr_type" gorm:"size:20;not null"` // 属性类型，对应 AffixDefinition.AttrType
+	Value          float64 `json:"value" gorm:"not null"`             // 数值，百分比属性存百分数
+}
+
+// TableName 指定表名
+func (EquipmentSetBonus) TableName() string {
+	return "equipment_set_bonuses"
+}
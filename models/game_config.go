@@ -0,0 +1,22 @@
+package models
+
+// 配置键
+const (
+	GameConfigAffixRules = "affix_rules" // 词条规则：打造/融合概率等，版本号同时作为词条规则集版本
+)
+
+// GameConfig 可热更新的玩法配置，Value 为JSON；每次修改 Version 加1
+type GameConfig struct {
+	ID        uint   `json:"id" gorm:"primarykey"`
+	Key       string `json:"key" gorm:"size:50;uniqueIndex;not null"` // 配置键
+	Value     string `json:"value" gorm:"type:text;not null"`         // 配置内容（JSON）
+	Version   int    `json:"version" gorm:"not null;default:1"`       // 版本号
+	UpdatedBy string `json:"updated_by" gorm:"size:50"`               // 最后修改的管理员
+	CreatedAt int64  `json:"created_at" gorm:"autoCreateTime"`        // 创建时间
+	UpdatedAt int64  `json:"updated_at" gorm:"autoUpdateTime"`        // 更新时间
+}
+
+// TableName 指定表名
+func (GameConfig) TableName() string {
+	return "game_configs"
+}
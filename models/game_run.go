@@ -0,0 +1,52 @@
+package models
+
+// 对局状态
+const (
+	GameRunStatusActive   = "active"   // 进行中
+	GameRunStatusFinished = "finished" // 已结算
+	GameRunStatusExpired  = "expired"  // 超时作废
+)
+
+// 对局刷新物类型
+const (
+	GameRunSpawnMonster = "monster"
+	GameRunSpawnScene   = "scene"
+)
+
+// GameRun 对局：开局时由服务端生成刷怪，结算时由服务端发放奖励
+type GameRun struct {
+	ID         uint           `json:"id" gorm:"primarykey"`
+	UserID     uint           `json:"user_id" gorm:"not null;index"`        // 用户ID
+	Area       int            `json:"area" gorm:"not null;default:1"`       // 区服ID
+	Region     string         `json:"region" gorm:"size:100"`               // 区域（为空表示不限）
+	Seed       int64          `json:"-" gorm:"not null"`                    // 随机种子，结算掉落使用，不下发客户端
+	Status     string         `json:"status" gorm:"size:20;not null;index"` // 状态：active, finished, expired
+	StartedAt  int64          `json:"started_at" gorm:"not null"`           // 开局时间
+	FinishedAt int64          `json:"finished_at" gorm:"default:0"`         // 结算时间
+	Kills      int            `json:"kills" gorm:"default:0"`               // 结算击杀数
+	GoldReward int            `json:"gold_reward" gorm:"default:0"`         // 结算金币
+	ExpReward  int            `json:"exp_reward" gorm:"default:0"`          // 结算经验
+	Spawns     []GameRunSpawn `json:"spawns" gorm:"foreignKey:GameRunID"`   // 刷新的怪物和场景物
+	CreatedAt  int64          `json:"created_at" gorm:"autoCreateTime"`     // 创建时间
+	UpdatedAt  int64          `json:"updated_at" gorm:"autoUpdateTime"`     // 更新时间
+}
+
+// TableName 指定表名
+func (GameRun) TableName() string {
+	return "game_runs"
+}
+
+// GameRunSpawn 对局刷新记录
+type GameRunSpawn struct {
+	ID        uint   `json:"-" gorm:"primarykey"`
+	GameRunID uint   `json:"-" gorm:"not null;index"`          // 对局ID
+	Kind      string `json:"kind" gorm:"size:20;not null"`     // 类型：monster, scene
+	RefID     uint   `json:"ref_id" gorm:"not null"`           // 怪物ID或场景ID
+	Count     int    `json:"count" gorm:"not null;default:1"`  // 数量
+	Killed    int    `json:"killed" gorm:"not null;default:0"` // 结算时的击杀数（仅怪物）
+}
+
+// TableName 指定表名
+func (GameRunSpawn) TableName() string {
+	return "game_run_spawns"
+}
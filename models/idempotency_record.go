@@ -0,0 +1,21 @@
+package models
+
+// IdempotencyRecord 幂等请求记录（每个key以此表为准，Redis 只缓存已完成的响应）
+type IdempotencyRecord struct {
+	ID             uint   `json:"id" gorm:"primarykey"`
+	UserID         uint   `json:"user_id" gorm:"not null;uniqueIndex:idx_idempotency_user_key"`      // 用户ID
+	Key            string `json:"key" gorm:"size:255;not null;uniqueIndex:idx_idempotency_user_key"` // 客户端传入的 Idempotency-Key
+	Fingerprint    string `json:"fingerprint" gorm:"size:64;not null"`                               // 请求指纹（方法+路径+请求体的哈希）
+	Status         string `json:"status" gorm:"size:20;not null"`                                    // 状态：processing(处理中), completed(已完成)
+	ResponseStatus int    `json:"response_status" gorm:"default:0"`                                  // 首次响应的HTTP状态码
+	ResponseType   string `json:"response_type" gorm:"size:100;default:''"`                          // 首次响应的Content-Type
+	ResponseBody   string `json:"response_body" gorm:"type:text"`                                    // 首次响应的响应体
+	ExpiresAt      int64  `json:"expires_at" gorm:"not null;index"`                                  // 过期时间（Unix秒）
+	CreatedAt      int64  `json:"created_at" gorm:"autoCreateTime"`                                  // 创建时间
+	UpdatedAt      int64  `json:"updated_at" gorm:"autoUpdateTime"`                                  // 更新时间
+}
+
+// TableName 指定表名
+func (IdempotencyRecord) TableName() string {
+	return "idempotency_records"
+}
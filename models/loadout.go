@@ -0,0 +1,30 @@
+package models
+
+// Loadout 装备方案：每个部位一件装备，以及可选的皮肤，可一键整套切换
+type Loadout struct {
+	ID        uint          `json:"id" gorm:"primarykey"`
+	UserID    uint          `json:"user_id" gorm:"not null;uniqueIndex:idx_loadout_user_name"`      // 用户ID
+	Name      string        `json:"name" gorm:"size:20;not null;uniqueIndex:idx_loadout_user_name"` // 方案名称，如 boss、farming
+	SkinID    uint          `json:"skin_id" gorm:"default:0"`                                       // 皮肤ID，0表示切换时不改变皮肤
+	Items     []LoadoutItem `json:"items" gorm:"foreignKey:LoadoutID"`                              // 各部位的装备
+	CreatedAt int64         `json:"created_at" gorm:"autoCreateTime"`                               // 创建时间
+	UpdatedAt int64         `json:"updated_at" gorm:"autoUpdateTime"`                               // 更新时间
+}
+
+// TableName 指定表名
+func (Loadout) TableName() string {
+	return "loadouts"
+}
+
+// LoadoutItem 装备方案中某个部位的装备
+type LoadoutItem struct {
+	ID              uint   `json:"id" gorm:"primarykey"`
+	LoadoutID       uint   `json:"loadout_id" gorm:"not null;index"`
+	Slot            string `json:"slot" gorm:"size:20;not null"`      // 部位：weapon, helmet, chest, gloves, pants, boots
+	UserEquipmentID uint   `json:"user_equipment_id" gorm:"not null"` // 玩家装备ID（装备被回收或融合后失效）
+}
+
+// TableName 指定表名
+func (LoadoutItem) TableName() string {
+	return "loadout_items"
+}
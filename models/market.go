@@ -0,0 +1,52 @@
+package models
+
+// 寄售物品类型
+const (
+	MarketKindItem      = "item"      // 背包物品堆叠（宝物、消耗品）
+	MarketKindEquipment = "equipment" // 装备
+)
+
+// 寄售状态
+const (
+	MarketStatusActive    = "active"    // 寄售中
+	MarketStatusSold      = "sold"      // 已售出
+	MarketStatusExpired   = "expired"   // 到期退回
+	MarketStatusCancelled = "cancelled" // 卖家下架
+)
+
+// PositionMarket 寄售中的装备位置：托管期间装备的 user_id 为0，不属于任何玩家
+const PositionMarket = "market"
+
+// MailItemTypeMarketEquipment 退回寄售装备的邮件物品类型，ItemID 为托管中的玩家装备ID
+const MailItemTypeMarketEquipment = "market_equipment"
+
+// MarketListing 交易行寄售：上架时物品转入托管，售出后转给买家，到期或下架后以邮件退回卖家
+type MarketListing struct {
+	ID              uint   `json:"id" gorm:"primarykey"`
+	Area            int    `json:"area" gorm:"not null;default:1;index:idx_market_search,priority:1"` // 区服ID
+	SellerID        uint   `json:"seller_id" gorm:"not null;index"`                                   // 卖家ID
+	BuyerID         uint   `json:"buyer_id" gorm:"default:0"`                                         // 买家ID
+	Kind            string `json:"kind" gorm:"size:20;not null"`                                      // 类型：item, equipment
+	ItemType        string `json:"item_type" gorm:"size:20;default:''"`                               // 物品类型（item）：treasure, consumable
+	ItemID          uint   `json:"item_id" gorm:"default:0"`                                          // 宝物或消耗品ID（item）
+	Quantity        int    `json:"quantity" gorm:"default:1"`                                         // 数量，装备为1
+	UserEquipmentID uint   `json:"user_equipment_id" gorm:"default:0;index"`                          // 托管的玩家装备ID（equipment）
+	Name            string `json:"name" gorm:"size:100"`                                              // 名称，上架时记录，用于展示
+	Level           int    `json:"level" gorm:"default:0;index"`                                      // 宝物等级或装备品级，消耗品为0
+	Slot            string `json:"slot" gorm:"size:20;default:''"`                                    // 装备部位
+	Currency        string `json:"currency" gorm:"size:20;not null"`                                  // 标价货币：gold, diamond
+	Price           int    `json:"price" gorm:"not null"`                                             // 整单售价
+	Fee             int    `json:"fee" gorm:"default:0"`                                              // 交易行手续费，售出时从售价中扣除
+	Status          string `json:"status" gorm:"size:20;not null;index:idx_market_search,priority:2"` // 状态：active, sold, expired, cancelled
+	ExpiresAt       int64  `json:"expires_at" gorm:"not null;index:idx_market_search,priority:3"`     // 到期时间
+	ClosedAt        int64  `json:"closed_at" gorm:"default:0"`                                        // 售出、退回或下架时间
+	CreatedAt       int64  `json:"created_at" gorm:"autoCreateTime"`                                  // 创建时间
+	UpdatedAt       int64  `json:"updated_at" gorm:"autoUpdateTime"`                                  // 更新时间
+
+	Equipment *UserEquipment `json:"equipment,omitempty" gorm:"foreignKey:UserEquipmentID"` // 寄售的装备（equipment）
+}
+
+// TableName 指定表名
+func (MarketListing) TableName() string {
+	return "market_listings"
+}
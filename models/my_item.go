@@ -1,16 +1,29 @@
 package models
 
+// MyItem.ItemType 为 treasure 时，ItemID 为宝物ID
+const MyItemTypeTreasure = "treasure"
+
+// DefaultMaxStack 物品未配置堆叠上限时使用的默认值
+const DefaultMaxStack = 9999
+
+// 物品位置：背包和仓库分别计算容量（一个物品堆叠或一件未穿戴的装备占一格）
+const (
+	PositionBackpack  = "backpack"
+	PositionWarehouse = "warehouse"
+)
+
+// MyItem 背包中的可堆叠物品：同一用户的同一物品在同一位置只有一个堆叠
 type MyItem struct {
 	ID        uint   `json:"id" gorm:"primarykey"`
-	UserID    uint   `json:"user_id" gorm:"not null;index"`              // 用户ID
-	ItemID    uint   `json:"item_id" gorm:"not null"`                    // 物品ID
-	ItemType  string `json:"item_type" gorm:"size:20;not null"`          // 物品类型：treasure(宝物), equipment(装备)
-	SellPrice int    `json:"sell_price" gorm:"default:0"`                // 出售价格
-	Position  string `json:"position" gorm:"size:20;default:'backpack'"` // 物品位置：backpack(背包), warehouse(仓库), equipped(装备中)
-	Quantity  int    `json:"quantity" gorm:"default:1"`                  // 数量
-	IsActive  bool   `json:"is_active" gorm:"default:true"`              // 是否激活
-	CreatedAt int64  `json:"created_at" gorm:"autoCreateTime"`           // 创建时间
-	UpdatedAt int64  `json:"updated_at" gorm:"autoUpdateTime"`           // 更新时间
+	UserID    uint   `json:"user_id" gorm:"not null;index;uniqueIndex:idx_my_item_stack,priority:1"`              // 用户ID
+	ItemID    uint   `json:"item_id" gorm:"not null;uniqueIndex:idx_my_item_stack,priority:3"`                    // 物品ID
+	ItemType  string `json:"item_type" gorm:"size:20;not null;uniqueIndex:idx_my_item_stack,priority:2"`          // 物品类型：treasure(宝物), consumable(消耗品)
+	SellPrice int    `json:"sell_price" gorm:"default:0"`                                                         // 出售价格
+	Position  string `json:"position" gorm:"size:20;default:'backpack';uniqueIndex:idx_my_item_stack,priority:4"` // 物品位置：backpack(背包), warehouse(仓库)
+	Quantity  int    `json:"quantity" gorm:"default:1"`                                                           // 数量
+	IsActive  bool   `json:"is_active" gorm:"default:true"`                                                       // 是否激活
+	CreatedAt int64  `json:"created_at" gorm:"autoCreateTime"`                                                    // 创建时间
+	UpdatedAt int64  `json:"updated_at" gorm:"autoUpdateTime"`                                                    // 更新时间
 }
 
 // TreasureInfo 用于返回物品的详细信息
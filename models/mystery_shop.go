@@ -0,0 +1,49 @@
+package models
+
+// 神秘商店商品类型
+const (
+	ShopOfferTreasure  = "treasure"  // 宝物，RefID 为宝物ID
+	ShopOfferEquipment = "equipment" // 装备，RefID 为装备模板ID
+)
+
+// MysteryShop 玩家在某个区服的神秘商店：商品由服务端按种子生成，定时刷新或花费钻石刷新
+type MysteryShop struct {
+	ID             uint               `json:"id" gorm:"primarykey"`
+	UserID         uint               `json:"user_id" gorm:"not null;uniqueIndex:idx_mystery_shop_user,priority:1"`        // 用户ID
+	Area           int                `json:"area" gorm:"not null;default:1;uniqueIndex:idx_mystery_shop_user,priority:2"` // 区服ID
+	Seed           int64              `json:"-" gorm:"not null"`                                                           // 本次刷新的随机种子，用同一种子可复现商品，不下发客户端
+	RefreshCount   int                `json:"refresh_count" gorm:"default:0"`                                              // 累计刷新次数（定时和付费）
+	RefreshedAt    int64              `json:"refreshed_at" gorm:"not null"`                                                // 最近一次刷新时间
+	NextRefreshAt  int64              `json:"next_refresh_at" gorm:"not null"`                                             // 下次定时刷新时间
+	PaidRefreshes  int                `json:"paid_refreshes" gorm:"default:0"`                                             // 当天已付费刷新次数
+	PaidRefreshDay string             `json:"-" gorm:"size:8;default:''"`                                                  // 付费刷新次数所属日期（20060102）
+	Offers         []MysteryShopOffer `json:"offers" gorm:"foreignKey:ShopID"`                                             // 当前商品
+	CreatedAt      int64              `json:"created_at" gorm:"autoCreateTime"`                                            // 创建时间
+	UpdatedAt      int64              `json:"updated_at" gorm:"autoUpdateTime"`                                            // 更新时间
+}
+
+// TableName 指定表名
+func (MysteryShop) TableName() string {
+	return "mystery_shops"
+}
+
+// MysteryShopOffer 神秘商店的一件商品，每次刷新整体替换
+type MysteryShopOffer struct {
+	ID          uint   `json:"id" gorm:"primarykey"`
+	ShopID      uint   `json:"shop_id" gorm:"not null;index"`    // 神秘商店ID
+	Slot        int    `json:"slot" gorm:"not null"`             // 商品位置，从0开始
+	Kind        string `json:"kind" gorm:"size:20;not null"`     // 类型：treasure, equipment
+	RefID       uint   `json:"ref_id" gorm:"not null"`           // 宝物ID或装备模板ID
+	Name        string `json:"name" gorm:"size:100"`             // 名称
+	ImageURL    string `json:"image_url" gorm:"size:500"`        // 图片
+	Level       int    `json:"level" gorm:"default:1"`           // 宝物等级或装备品级
+	Currency    string `json:"currency" gorm:"size:20;not null"` // 价格货币：gold, diamond
+	Price       int    `json:"price" gorm:"not null"`            // 价格
+	Purchased   bool   `json:"purchased" gorm:"default:false"`   // 是否已购买
+	PurchasedAt int64  `json:"purchased_at" gorm:"default:0"`    // 购买时间
+}
+
+// TableName 指定表名
+func (MysteryShopOffer) TableName() string {
+	return "mystery_shop_offers"
+}
@@ -6,6 +6,7 @@ type Treasure struct {
 	ImageURL    string `json:"image_url" gorm:"size:500"`        // 宝物图片
 	Value       int    `json:"value" gorm:"default:0"`           // 价值（金币）
 	Level       int    `json:"level" gorm:"default:1"`           // 等级
+	MaxStack    int    `json:"max_stack" gorm:"default:9999"`    // 单个堆叠的数量上限
 	IsActive    bool   `json:"is_active" gorm:"default:true"`    // 是否激活
 	Description string `json:"description" gorm:"size:500"`      // 描述（可选）
 	CreatedAt   int64  `json:"created_at" gorm:"autoCreateTime"` // 创建时间
@@ -5,32 +5,66 @@ import (
 )
 
 type User struct {
-	ID        uint      `json:"id" gorm:"primarykey"`
-	Img       string    `json:"img" gorm:"size:255;not null"`                 // 账号
-	Username  string    `json:"username" gorm:"size:50;uniqueIndex;not null"` // 账号
-	Password  string    `json:"-" gorm:"size:255;not null"`                   // 密码（不序列化到JSON）
-	Gold      int       `json:"gold" gorm:"default:0"`                        // 金币
-	Diamond   int       `json:"diamond" gorm:"default:0"`                     // 钻石
-	Level     int       `json:"level" gorm:"default:1"`                       // 等级
-	LastLogin time.Time `json:"last_login"`                                   // 最后登录时间
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID                uint      `json:"id" gorm:"primarykey"`
+	Img               string    `json:"img" gorm:"size:255;not null"`                 // 账号
+	Username          string    `json:"username" gorm:"size:50;uniqueIndex;not null"` // 账号
+	Password          string    `json:"-" gorm:"size:255;not null"`                   // 密码（不序列化到JSON）
+	Gold              int       `json:"gold" gorm:"default:0"`                        // 金币
+	Diamond           int       `json:"diamond" gorm:"default:0"`                     // 钻石
+	Level             int       `json:"level" gorm:"default:1"`                       // 等级
+	Exp               int       `json:"exp" gorm:"default:0"`                         // 经验（对局结算获得）
+	CombatPower       int       `json:"combat_power" gorm:"default:0;index"`          // 各区服存档中的最高战力（装备、皮肤或存档变化时重新计算）
+	ForgePity         int       `json:"forge_pity" gorm:"default:0"`                  // 连续打造未出稀有词条的次数（出稀有词条后清零），用于保底
+	BackpackCapacity  int       `json:"backpack_capacity" gorm:"default:200"`         // 背包容量（格），可用钻石扩充
+	WarehouseCapacity int       `json:"warehouse_capacity" gorm:"default:100"`        // 仓库容量（格），可用钻石扩充
+	LastLogin         time.Time `json:"last_login"`                                   // 最后登录时间
+	CreatedAt         time.Time `json:"created_at"`
+	UpdatedAt         time.Time `json:"updated_at"`
 }
 
 // UserLoginRequest 登录请求
 type UserLoginRequest struct {
-	Username string `json:"username" binding:"required,min=1,max=50"`
-	Password string `json:"password" binding:"required,min=3,max=50"`
-	IsToken  int    `json:"is_token" binding:"omitempty,min=0,max=1"` // 0: 返回完整信息，1: 只返回token
+	Username   string `json:"username" binding:"required,min=1,max=50"`
+	Password   string `json:"password" binding:"required,min=3,max=50"`
+	IsToken    int    `json:"is_token" binding:"omitempty,min=0,max=1"` // 0: 返回完整信息，1: 只返回token
+	DeviceID   string `json:"device_id" binding:"omitempty,max=100"`    // 设备标识（可选，用于会话列表）
+	DeviceName string `json:"device_name" binding:"omitempty,max=100"`  // 设备名称（可选）
 }
 
 // UserLoginResponse 登录响应
 type UserLoginResponse struct {
-	UserID   uint   `json:"user_id"`
-	Username string `json:"username"`
-	Img      string `json:"img"`
-	Gold     int    `json:"gold"`
-	Diamond  int    `json:"diamond"`
-	Level    int    `json:"level"`
-	Token    string `json:"token"`
+	UserID       uint   `json:"user_id"`
+	Username     string `json:"username"`
+	Img          string `json:"img"`
+	Gold         int    `json:"gold"`
+	Diamond      int    `json:"diamond"`
+	Level        int    `json:"level"`
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"` // 刷新token，访问token过期后调用 /token/refresh 换取
+	ExpiresIn    int64  `json:"expires_in"`    // 访问token有效秒数
+	SessionID    string `json:"session_id"`    // 当前登录会话ID
+}
+
+// UpdateProfileRequest 玩家修改个人资料，只允许修改头像和密码
+type UpdateProfileRequest struct {
+	Img         *string `json:"img" binding:"omitempty,max=255"`         // 头像
+	OldPassword string  `json:"old_password" binding:"omitempty,max=50"` // 原密码（修改密码时必填）
+	NewPassword string  `json:"new_password" binding:"omitempty,max=50"` // 新密码
+}
+
+// AdminCreateUserRequest 管理后台创建用户
+type AdminCreateUserRequest struct {
+	Username string `json:"username" binding:"required,min=1,max=50"`
+	Password string `json:"password" binding:"required,min=3,max=50"`
+	Img      string `json:"img" binding:"omitempty,max=255"`
+	Gold     int    `json:"gold" binding:"omitempty,min=0"`
+	Diamond  int    `json:"diamond" binding:"omitempty,min=0"`
+	Level    int    `json:"level" binding:"omitempty,min=1"`
+}
+
+// AdminUpdateUserRequest 管理后台修改用户（金币/钻石需通过邮件发放，不能直接改）
+type AdminUpdateUserRequest struct {
+	Img      *string `json:"img" binding:"omitempty,max=255"`
+	Level    *int    `json:"level" binding:"omitempty,min=1"`
+	Password string  `json:"password" binding:"omitempty,min=3,max=50"` // 重置密码，重置后该用户所有会话失效
 }
@@ -1,14 +1,19 @@
 package models
 
 type UserEquipment struct {
-	ID           uint   `json:"id" gorm:"primarykey"`
-	UserID       uint   `json:"user_id" gorm:"not null;index"`
-	EquipmentID  uint   `json:"equipment_id" gorm:"not null"`
-	IsEquipped   bool   `json:"is_equipped" gorm:"default:false"`
-	Position     string `json:"position" gorm:"size:20;default:'backpack'"`
-	EnhanceLevel int    `json:"enhance_level" gorm:"default:0"` // 强化等级
-	CreatedAt    int64  `json:"created_at" gorm:"autoCreateTime"`
-	UpdatedAt    int64  `json:"updated_at" gorm:"autoUpdateTime"`
+	ID            uint   `json:"id" gorm:"primarykey"`
+	UserID        uint   `json:"user_id" gorm:"not null;index"`
+	EquipmentID   uint   `json:"equipment_id" gorm:"not null"`
+	IsEquipped    bool   `json:"is_equipped" gorm:"default:false"`
+	Position      string `json:"position" gorm:"size:20;default:'backpack'"`
+	EnhanceLevel  int    `json:"enhance_level" gorm:"default:0"`   // 强化等级
+	EnhanceFails  int    `json:"enhance_fails" gorm:"default:0"`   // 连续强化失败次数（成功后清零），用于保底
+	IsLocked      bool   `json:"is_locked" gorm:"default:false"`   // 是否锁定，锁定的装备不能被回收、融合或出售
+	IsFavorite    bool   `json:"is_favorite" gorm:"default:false"` // 是否收藏，收藏的装备不能被批量回收
+	HiddenAffixes int    `json:"hidden_affixes" gorm:"default:0"`  // 未鉴定的隐藏词条数，鉴定时逐条揭示
+	IdentifyCount int    `json:"identify_count" gorm:"default:0"`  // 已鉴定次数，次数越多揭示词条的概率越低
+	CreatedAt     int64  `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt     int64  `json:"updated_at" gorm:"autoUpdateTime"`
 
 	EquipmentTemplate EquipmentTemplate `json:"equipment_template" gorm:"foreignKey:EquipmentID"`
 	// 关联附属属性
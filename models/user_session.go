@@ -0,0 +1,24 @@
+package models
+
+// UserSession 用户登录会话（每台设备一条，刷新token轮换时更新）
+type UserSession struct {
+	ID                  uint   `json:"-" gorm:"primarykey"`
+	SessionID           string `json:"session_id" gorm:"size:64;not null;uniqueIndex"` // 会话ID，写入访问token的sid
+	UserID              uint   `json:"user_id" gorm:"not null;index"`                  // 用户ID
+	RefreshTokenHash    string `json:"-" gorm:"size:64;not null;uniqueIndex"`          // 当前刷新token的哈希
+	PreviousRefreshHash string `json:"-" gorm:"size:64;index"`                         // 上一个刷新token的哈希，用于发现token被盗用后重放
+	DeviceID            string `json:"device_id" gorm:"size:100;default:''"`           // 客户端设备标识
+	DeviceName          string `json:"device_name" gorm:"size:100;default:''"`         // 设备名称
+	UserAgent           string `json:"user_agent" gorm:"size:255;default:''"`          // 客户端UA
+	IP                  string `json:"ip" gorm:"size:64;default:''"`                   // 最近一次访问IP
+	LastSeenAt          int64  `json:"last_seen_at" gorm:"not null;default:0"`         // 最近活跃时间
+	ExpiresAt           int64  `json:"expires_at" gorm:"not null"`                     // 刷新token过期时间
+	RevokedAt           int64  `json:"revoked_at" gorm:"not null;default:0;index"`     // 吊销时间，0表示有效
+	CreatedAt           int64  `json:"created_at" gorm:"autoCreateTime"`               // 创建时间
+	UpdatedAt           int64  `json:"updated_at" gorm:"autoUpdateTime"`               // 更新时间
+}
+
+// TableName 指定表名
+func (UserSession) TableName() string {
+	return "user_sessions"
+}
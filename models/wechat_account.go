@@ -0,0 +1,17 @@
+package models
+
+// WeChatAccount 微信账号绑定（一个游戏账号最多绑定一个微信）
+type WeChatAccount struct {
+	ID         uint   `json:"id" gorm:"primarykey"`
+	UserID     uint   `json:"user_id" gorm:"not null;uniqueIndex"`        // 游戏账号ID
+	OpenID     string `json:"openid" gorm:"size:64;not null;uniqueIndex"` // 小游戏openid
+	UnionID    string `json:"unionid" gorm:"size:64;index"`               // 开放平台unionid（可能为空）
+	SessionKey string `json:"-" gorm:"size:64"`                           // 会话密钥，只保存在服务端
+	CreatedAt  int64  `json:"created_at" gorm:"autoCreateTime"`           // 创建时间
+	UpdatedAt  int64  `json:"updated_at" gorm:"autoUpdateTime"`           // 更新时间
+}
+
+// TableName 指定表名
+func (WeChatAccount) TableName() string {
+	return "wechat_accounts"
+}
@@ -5,6 +5,7 @@ import (
 	"ggo/controllers"
 	"ggo/database"
 	"ggo/middleware"
+	"ggo/models"
 
 	"github.com/gin-gonic/gin"
 )
@@ -16,7 +17,8 @@ func SetupRoutes(cfg *config.Config) *gin.Engine {
 	router.Use(middleware.CORS())
 
 	// 创建控制器实例
-	userController := controllers.NewUserController(database.DB)
+	userController := controllers.NewUserController(database.DB, cfg)
+	sessionController := controllers.NewSessionController(database.DB, cfg)
 	skinController := controllers.NewSkinController(database.DB)
 	monsterController := controllers.NewMonsterController(database.DB)
 	userSkinController := controllers.NewUserSkinController(database.DB)
@@ -28,19 +30,32 @@ func SetupRoutes(cfg *config.Config) *gin.Engine {
 	equipmentEnhanceController := controllers.NewEquipmentEnhanceController(database.DB)
 	archiveController := controllers.NewArchiveController(database.DB)
 	areaController := controllers.NewAreaController(database.DB)
-	wechatController := controllers.NewWeChatController(cfg)
+	wechatController := controllers.NewWeChatController(database.DB, cfg)
 	leaderboardController := controllers.NewLeaderboardController(database.DB)
 	mailController := controllers.NewMailController(database.DB)
+	currencyController := controllers.NewCurrencyController(database.DB)
+	adminController := controllers.NewAdminController(database.DB, cfg)
+	runController := controllers.NewRunController(database.DB)
+	dropTableController := controllers.NewDropTableController(database.DB)
+	affixController := controllers.NewAffixController(database.DB)
+	recycleController := controllers.NewRecycleController(database.DB)
+	reforgeController := controllers.NewReforgeController(database.DB)
+	equipmentSetController := controllers.NewEquipmentSetController(database.DB)
+	loadoutController := controllers.NewLoadoutController(database.DB)
+	inventoryController := controllers.NewInventoryController(database.DB)
+	marketController := controllers.NewMarketController(database.DB)
+	shopController := controllers.NewShopController(database.DB)
 
 	// 公开路由（无需认证）
 	public := router.Group("/api/v1")
 	{
 		public.POST("/login", userController.Login)
-		public.GET("/scenes", sceneController.GetScenes)          // 场景列表设为公开接口
-		public.GET("/treasures", treasureController.GetTreasures) // 宝物列表设为公开接口
+		public.POST("/token/refresh", sessionController.RefreshToken) // 刷新访问token
+		public.GET("/scenes", sceneController.GetScenes)              // 场景列表设为公开接口
+		public.GET("/treasures", treasureController.GetTreasures)     // 宝物列表设为公开接口
 		public.GET("/home-configs", homeConfigController.GetHomeConfigs)
 		public.GET("/equipment-templates", equipmentController.GetEquipmentTemplates) // 装备模板列表设为公开接口
-		public.POST("/wechat/login", wechatController.GetOpenID)                      // 微信登录获取openid
+		public.POST("/wechat/login", wechatController.Login)                          // 微信登录（未绑定时自动注册）
 		public.GET("/leaderboard", leaderboardController.GetLeaderboard)              // 获取排行榜
 		public.GET("/leaderboard/rank", leaderboardController.GetPlayerRank)          // 获取玩家排名
 		public.GET("/areas", areaController.GetAreas)                                 // 区服列表
@@ -48,16 +63,19 @@ func SetupRoutes(cfg *config.Config) *gin.Engine {
 
 	// 受保护路由（需要认证）
 	protected := router.Group("/api/v1")
-	protected.Use(middleware.JWTAuth())
+	protected.Use(middleware.JWTAuth(), middleware.Idempotency()) // 写请求支持 Idempotency-Key 防重放
 	{
 		// 用户相关
 		protected.GET("/profile", userController.GetProfile)
 		protected.GET("/user/attributes", userController.GetPlayerAttributes) // 获取玩家属性
-		protected.GET("/users", userController.GetUsers)
-		protected.GET("/users/:id", userController.GetUser)
-		protected.POST("/users", userController.CreateUser)
-		protected.PUT("/users/:id", userController.UpdateUser)
-		protected.DELETE("/users/:id", userController.DeleteUser)
+		protected.PUT("/profile", userController.UpdateProfile)               // 修改头像/密码
+
+		protected.POST("/wechat/bind", wechatController.Bind) // 绑定微信
+
+		// 登录会话相关
+		protected.GET("/sessions", sessionController.GetSessions)          // 登录设备列表
+		protected.DELETE("/sessions/:id", sessionController.RevokeSession) // 踢下线指定设备
+		protected.DELETE("/sessions", sessionController.RevokeAllSessions) // 退出全部设备
 
 		// 皮肤相关
 		protected.GET("/skins", skinController.GetSkins)
@@ -66,9 +84,6 @@ func SetupRoutes(cfg *config.Config) *gin.Engine {
 		// 怪物相关
 		protected.GET("/monsters", monsterController.GetMonsters)
 		protected.GET("/monsters/:id", monsterController.GetMonster)
-		protected.POST("/monsters", monsterController.CreateMonster)
-		protected.PUT("/monsters/:id", monsterController.UpdateMonster)
-		protected.DELETE("/monsters/:id", monsterController.DeleteMonster)
 
 		// 用户皮肤相关
 		protected.POST("/user/skins/acquire", userSkinController.AcquireSkin)
@@ -78,35 +93,140 @@ func SetupRoutes(cfg *config.Config) *gin.Engine {
 		protected.DELETE("/user/skins/:skin_id", userSkinController.DeleteUserSkin)
 
 		// 我的物品相关
-		protected.POST("/my-items", myItemController.AddMyItem)
 		protected.GET("/my-items", myItemController.GetMyItems)                           // 获取未穿戴的装备和其他物品
 		protected.GET("/my-items/equipped", myItemController.GetEquippedItems)            // 获取已穿戴的装备
 		protected.POST("/my-items/sell-multiple", myItemController.SellMultipleTreasures) // 批量出售宝物
 		protected.GET("/my-items/treasures", myItemController.GetMyTreasures)             // 获取我的宝物列表
+		// 背包/仓库相关
+		protected.GET("/inventory", inventoryController.GetInventory)               // 背包和仓库的容量与已用格数
+		protected.POST("/inventory/expand", inventoryController.ExpandInventory)    // 钻石扩充背包或仓库
+		protected.POST("/inventory/move", inventoryController.MoveItem)             // 移动单个物品或装备
+		protected.POST("/inventory/move/batch", inventoryController.BatchMoveItems) // 批量移动
 
 		// 装备相关
-		protected.POST("/equipments/generate", equipmentController.GenerateEquipment) // 生成装备
-		protected.GET("/equipments", equipmentController.GetUserEquipments)           // 获取用户装备列表
-		protected.PUT("/equipments/:id/equip", equipmentController.EquipItem)         // 穿戴装备
-		protected.PUT("/equipments/:id/unequip", equipmentController.UnequipItem)     // 卸下装备
-		protected.GET("/equipments/my", equipmentController.GetMyEquipment)           // 获取我的装备（穿戴中和未穿戴的）
+		protected.POST("/equipments/generate", equipmentController.GenerateEquipment)               // 生成装备
+		protected.GET("/equipments/generate/preview", equipmentController.PreviewGenerateEquipment) // 打造预览（消耗与概率公示）
+		protected.GET("/equipments", equipmentController.GetUserEquipments)                         // 获取用户装备列表
+		protected.PUT("/equipments/:id/equip", equipmentController.EquipItem)                       // 穿戴装备
+		protected.PUT("/equipments/:id/unequip", equipmentController.UnequipItem)                   // 卸下装备
+		protected.GET("/equipments/my", equipmentController.GetMyEquipment)                         // 获取我的装备（穿戴中和未穿戴的）
+		protected.PUT("/equipments/:id/lock", equipmentController.LockEquipment)                    // 锁定/解锁装备
+		protected.PUT("/equipments/:id/favorite", equipmentController.FavoriteEquipment)            // 收藏/取消收藏装备
+		protected.POST("/equipments/:id/identify", equipmentController.IdentifyEquipment)           // 鉴定装备，揭示隐藏词条
 		// 装备强化相关
 		protected.POST("/equipments/merge", equipmentEnhanceController.MergeEquipment)         // 融合装备
 		protected.POST("/equipments/:id/enhance", equipmentEnhanceController.EnhanceEquipment) // 强化装备
+		// 词条重铸相关（先预览，接受后生效）
+		protected.POST("/equipments/:id/reforge", reforgeController.ReforgeAffix)     // 重铸词条，返回预览
+		protected.GET("/equipments/:id/reforge", reforgeController.GetPendingReforge) // 获取待确认的重铸结果
+		protected.POST("/reforges/:id/accept", reforgeController.AcceptReforge)       // 接受重铸结果
+		protected.POST("/reforges/:id/discard", reforgeController.DiscardReforge)     // 放弃重铸结果
+		// 装备方案相关
+		protected.GET("/loadouts", loadoutController.GetLoadouts)
+		protected.POST("/loadouts", loadoutController.CreateLoadout)
+		protected.PUT("/loadouts/:id", loadoutController.UpdateLoadout)
+		protected.DELETE("/loadouts/:id", loadoutController.DeleteLoadout)
+		protected.POST("/loadouts/:id/apply", loadoutController.ApplyLoadout) // 整套切换
+		// 装备回收相关
+		protected.POST("/equipments/:id/recycle", recycleController.RecycleEquipment)  // 回收单件装备
+		protected.POST("/equipments/recycle", recycleController.BatchRecycleEquipment) // 批量回收装备
 		// 存档相关
 		protected.POST("/archive", archiveController.SaveArchive) // 保存存档（包含area参数）
 		protected.GET("/archive", archiveController.LoadArchive)  // 读取存档（支持area参数）
 
+		// 交易行相关
+		protected.GET("/market/listings", marketController.SearchListings)            // 搜索寄售中的物品
+		protected.GET("/market/listings/my", marketController.GetMyListings)          // 我的寄售记录
+		protected.POST("/market/listings", marketController.CreateListing)            // 上架物品或装备
+		protected.POST("/market/listings/:id/buy", marketController.BuyListing)       // 购买
+		protected.POST("/market/listings/:id/cancel", marketController.CancelListing) // 下架，物品以邮件退回
+
+		// 神秘商店相关
+		protected.GET("/shop", shopController.GetShop)                      // 获取当前商品（到时间自动刷新）
+		protected.POST("/shop/refresh", shopController.RefreshShop)         // 钻石刷新
+		protected.POST("/shop/offers/:id/buy", shopController.BuyShopOffer) // 购买商品
+
 		protected.GET("/mails", mailController.GetMails)
 		protected.POST("/mails/:id/claim", mailController.ClaimMail)
 
+		// 对局相关
+		protected.POST("/runs/start", runController.StartRun)       // 开局（服务端生成刷怪）
+		protected.POST("/runs/:id/finish", runController.FinishRun) // 结算（服务端发放奖励）
+
+		// 货币流水
+		protected.GET("/currency/transactions", currencyController.GetTransactions) // 获取我的金币/钻石流水
+
 	}
 
+	// 管理后台登录（公开，但同样记录操作日志）
+	router.POST("/api/v1/admin/login", middleware.AdminAudit(), adminController.Login)
+
+	// 管理后台路由：后台token认证 + 按角色权限控制，所有请求（包括认证失败的请求）写入操作日志
 	admin := router.Group("/api/v1/admin")
-	admin.Use(middleware.AdminAuth())
+	admin.Use(middleware.AdminAudit(), middleware.AdminAuth())
 	{
-		admin.GET("/users", userController.GetUsers)
-		admin.POST("/mails/send", mailController.SendMail)
+		admin.GET("/me", adminController.Me) // 当前管理员信息及权限
+		admin.GET("/users", middleware.RequirePermission(models.PermUserRead), userController.GetUsers)
+		admin.GET("/users/:id", middleware.RequirePermission(models.PermUserRead), userController.GetUser)
+		admin.POST("/users", middleware.RequirePermission(models.PermUserWrite), userController.CreateUser)
+		admin.PUT("/users/:id", middleware.RequirePermission(models.PermUserWrite), userController.UpdateUser)
+		admin.DELETE("/users/:id", middleware.RequirePermission(models.PermUserWrite), userController.DeleteUser)
+		admin.POST("/users/:id/my-items", middleware.RequirePermission(models.PermUserWrite), myItemController.AddMyItem) // 向玩家背包发放宝物
+		admin.POST("/mails/send", middleware.RequirePermission(models.PermMailSend), mailController.SendMail)
+		admin.GET("/currency/reconcile", middleware.RequirePermission(models.PermCurrencyRead), currencyController.Reconcile) // 余额与流水对账
+
+		// 后台账号管理
+		admin.GET("/admins", middleware.RequirePermission(models.PermAdminManage), adminController.ListAdmins)
+		admin.POST("/admins", middleware.RequirePermission(models.PermAdminManage), adminController.CreateAdmin)
+		admin.PUT("/admins/:id", middleware.RequirePermission(models.PermAdminManage), adminController.UpdateAdmin)
+		admin.GET("/audit-logs", middleware.RequirePermission(models.PermAuditLogRead), adminController.GetAuditLogs)            // 操作日志
+		admin.GET("/enhance-logs", middleware.RequirePermission(models.PermUserRead), equipmentEnhanceController.GetEnhanceLogs) // 装备强化记录
+
+		// 掉落表管理
+		dropTables := admin.Group("/drop-tables", middleware.RequirePermission(models.PermDropTableManage))
+		{
+			dropTables.GET("", dropTableController.GetDropTables)
+			dropTables.GET("/:id", dropTableController.GetDropTable)
+			dropTables.POST("", dropTableController.CreateDropTable)
+			dropTables.PUT("/:id", dropTableController.UpdateDropTable)
+			dropTables.DELETE("/:id", dropTableController.DeleteDropTable)
+			dropTables.POST("/:id/bindings", dropTableController.BindDropTable)                 // 挂载到怪物/场景/首领
+			dropTables.DELETE("/:id/bindings/:binding_id", dropTableController.UnbindDropTable) // 取消挂载
+			dropTables.POST("/:id/simulate", dropTableController.SimulateDropTable)             // 模拟掉落分布
+		}
+
+		// 怪物配置：金币、经验和刷新率决定对局结算奖励
+		monsters := admin.Group("/monsters", middleware.RequirePermission(models.PermConfigManage))
+		{
+			monsters.POST("", monsterController.CreateMonster)
+			monsters.PUT("/:id", monsterController.UpdateMonster)
+			monsters.DELETE("/:id", monsterController.DeleteMonster)
+		}
+
+		// 装备词条配置（修改后规则集版本号加1）
+		affixes := admin.Group("/affixes", middleware.RequirePermission(models.PermConfigManage))
+		{
+			affixes.GET("", affixController.GetAffixes) // 词条定义、规则及当前版本
+			affixes.POST("", affixController.CreateAffix)
+			affixes.PUT("/:id", affixController.UpdateAffix)
+			affixes.PUT("/rules", affixController.UpdateAffixRules) // 修改打造/融合概率和词条上限
+		}
+
+		// 套装配置
+		equipmentSets := admin.Group("/equipment-sets", middleware.RequirePermission(models.PermConfigManage))
+		{
+			equipmentSets.GET("", equipmentSetController.GetEquipmentSets)
+			equipmentSets.POST("", equipmentSetController.CreateEquipmentSet)
+			equipmentSets.PUT("/:id", equipmentSetController.UpdateEquipmentSet) // 模板和加成整体替换
+			equipmentSets.DELETE("/:id", equipmentSetController.DeleteEquipmentSet)
+		}
+
+		// 装备回收价格配置
+		recycleConfigs := admin.Group("/recycle-configs", middleware.RequirePermission(models.PermConfigManage))
+		{
+			recycleConfigs.GET("", recycleController.GetRecycleConfigs)
+			recycleConfigs.PUT("/:level", recycleController.UpdateRecycleConfig) // 不存在时创建
+		}
 	}
 
 	router.GET("/admin/mail", mailController.SendMailPage)
@@ -0,0 +1,119 @@
+package services
+
+import (
+	"errors"
+	"ggo/config"
+	"ggo/models"
+	"ggo/utils"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrAdminInvalidCredentials = errors.New("账号或密码错误")
+	ErrAdminDisabled           = errors.New("账号已停用")
+	ErrAdminInvalidRole        = errors.New("无效的角色，支持: gm, operator, viewer")
+	ErrAdminUsernameTaken      = errors.New("账号已存在")
+)
+
+// AdminService 管理后台账号与操作日志
+type AdminService struct {
+	DB       *gorm.DB
+	TokenTTL time.Duration
+}
+
+func NewAdminService(db *gorm.DB, cfg *config.Config) *AdminService {
+	return &AdminService{DB: db, TokenTTL: cfg.AdminTokenTTL}
+}
+
+// Login 校验账号密码并签发后台token
+func (s *AdminService) Login(username, password string) (*models.AdminUser, string, error) {
+	var admin models.AdminUser
+	if err := s.DB.Where("username = ?", username).First(&admin).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, "", ErrAdminInvalidCredentials
+		}
+		return nil, "", err
+	}
+	if !utils.CheckPassword(password, admin.Password) {
+		return nil, "", ErrAdminInvalidCredentials
+	}
+	if !admin.IsActive {
+		return nil, "", ErrAdminDisabled
+	}
+
+	token, err := utils.GenerateAdminToken(admin.ID, admin.Username, admin.Role, s.TokenTTL)
+	if err != nil {
+		return nil, "", err
+	}
+
+	admin.LastLogin = time.Now().Unix()
+	s.DB.Model(&admin).Update("last_login", admin.LastLogin)
+	return &admin, token, nil
+}
+
+// CreateAdmin 创建后台账号
+func (s *AdminService) CreateAdmin(username, password, role string) (*models.AdminUser, error) {
+	if !models.IsValidAdminRole(role) {
+		return nil, ErrAdminInvalidRole
+	}
+
+	var count int64
+	if err := s.DB.Model(&models.AdminUser{}).Where("username = ?", username).Count(&count).Error; err != nil {
+		return nil, err
+	}
+	if count > 0 {
+		return nil, ErrAdminUsernameTaken
+	}
+
+	hashedPassword, err := utils.HashPassword(password)
+	if err != nil {
+		return nil, err
+	}
+
+	admin := models.AdminUser{
+		Username: username,
+		Password: hashedPassword,
+		Role:     role,
+		IsActive: true,
+	}
+	if err := s.DB.Create(&admin).Error; err != nil {
+		return nil, err
+	}
+	return &admin, nil
+}
+
+// EnsureBootstrapAdmin 没有任何后台账号时，按配置创建初始GM账号
+func EnsureBootstrapAdmin(db *gorm.DB, cfg *config.Config) {
+	var count int64
+	if err := db.Model(&models.AdminUser{}).Count(&count).Error; err != nil {
+		log.Printf("查询后台账号失败: %v", err)
+		return
+	}
+	if count > 0 {
+		return
+	}
+	if cfg.AdminBootstrapUsername == "" || cfg.AdminBootstrapPassword == "" {
+		log.Println("尚未创建后台账号，请设置 ADMIN_BOOTSTRAP_USERNAME 和 ADMIN_BOOTSTRAP_PASSWORD 后重启")
+		return
+	}
+
+	service := &AdminService{DB: db}
+	if _, err := service.CreateAdmin(cfg.AdminBootstrapUsername, cfg.AdminBootstrapPassword, models.AdminRoleGM); err != nil {
+		log.Printf("创建初始GM账号失败: %v", err)
+		return
+	}
+	log.Printf("已创建初始GM账号: %s", cfg.AdminBootstrapUsername)
+}
+
+// RecordAdminAudit 写入后台操作日志，失败只记录日志不影响请求
+func RecordAdminAudit(db *gorm.DB, entry *models.AdminAuditLog) {
+	if db == nil {
+		return
+	}
+	if err := db.Create(entry).Error; err != nil {
+		log.Printf("写入后台操作日志失败: %v", err)
+	}
+}
@@ -0,0 +1,478 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"ggo/models"
+	"ggo/services/stats"
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 词条配置缓存多久检查一次版本号（多实例部署时其他实例的修改在此时间内生效）
+const affixRegistryCheckInterval = 30 * time.Second
+
+var ErrAffixInvalid = errors.New("词条配置无效")
+
+// AffixRules 词条规则，保存在 game_configs 的 affix_rules 中
+type AffixRules struct {
+	ForgeRareChance    float64         `json:"forge_rare_chance"`    // 打造时出现稀有词条的概率
+	ForgeCommonChance  float64         `json:"forge_common_chance"`  // 打造时出现普通词条的概率（未出稀有词条时）
+	ForgeGoldCost      int             `json:"forge_gold_cost"`      // 打造消耗的金币
+	ForgeRarePity      int             `json:"forge_rare_pity"`      // 稀有词条保底：连续这么多次打造中前面都未出稀有词条时，本次必出；0 表示关闭
+	MergeInheritChance float64         `json:"merge_inherit_chance"` // 融合时继承材料装备词条的概率
+	MergeSuccessRates  map[int]float64 `json:"merge_success_rates"`  // 融合时按材料装备品级新增词条的概率
+	MaxAffixes         int             `json:"max_affixes"`          // 单件装备最多词条数
+	HiddenAffixChance  float64         `json:"hidden_affix_chance"`  // 打造或掉落的装备带隐藏词条的概率
+	HiddenAffixMax     map[int]int     `json:"hidden_affix_max"`     // 按装备品级的隐藏词条数上限，实际数量在1~上限之间随机
+	IdentifyChance     float64         `json:"identify_chance"`      // 首次鉴定揭示词条的概率
+	IdentifyDecay      float64         `json:"identify_decay"`       // 每鉴定一次，揭示概率乘以该系数
+}
+
+// DefaultAffixRules 默认词条规则，与规则集上线前代码中的数值一致
+func DefaultAffixRules() AffixRules {
+	return AffixRules{
+		ForgeRareChance:    0.5,
+		ForgeCommonChance:  0.4,
+		ForgeGoldCost:      50000,
+		ForgeRarePity:      10,
+		MergeInheritChance: 0.3,
+		MergeSuccessRates:  map[int]float64{1: 0.02, 2: 0.04, 3: 0.08, 4: 0.12, 5: 0.15, 6: 0.25},
+		MaxAffixes:         5,
+		HiddenAffixChance:  0.3,
+		HiddenAffixMax:     map[int]int{1: 1, 2: 1, 3: 2, 4: 2, 5: 3, 6: 3},
+		IdentifyChance:     0.9,
+		IdentifyDecay:      0.7,
+	}
+}
+
+// AffixRegistry 已加载的词条配置（只读），打造、融合和展示共用
+type AffixRegistry struct {
+	Version     int
+	Rules       AffixRules
+	Definitions []models.AffixDefinition
+	byType      map[string]*models.AffixDefinition
+}
+
+var affixCache struct {
+	sync.Mutex
+	registry  *AffixRegistry
+	checkedAt time.Time
+}
+
+// GetAffixRegistry 获取词条配置；缓存超过检查间隔时比对版本号，版本变化才重新加载
+func GetAffixRegistry(db *gorm.DB) (*AffixRegistry, error) {
+	affixCache.Lock()
+	defer affixCache.Unlock()
+
+	if affixCache.registry != nil && time.Since(affixCache.checkedAt) < affixRegistryCheckInterval {
+		return affixCache.registry, nil
+	}
+
+	config, err := loadAffixRulesConfig(db)
+	if err != nil {
+		if affixCache.registry != nil {
+			return affixCache.registry, nil
+		}
+		return nil, err
+	}
+	affixCache.checkedAt = time.Now()
+	if affixCache.registry != nil && affixCache.registry.Version == config.Version {
+		return affixCache.registry, nil
+	}
+
+	registry, err := buildAffixRegistry(db, config)
+	if err != nil {
+		return nil, err
+	}
+	affixCache.registry = registry
+	return registry, nil
+}
+
+// InvalidateAffixRegistry 清空本实例的词条配置缓存
+func InvalidateAffixRegistry() {
+	affixCache.Lock()
+	affixCache.registry = nil
+	affixCache.Unlock()
+}
+
+// loadAffixRulesConfig 读取词条规则配置，不存在时写入默认规则
+func loadAffixRulesConfig(db *gorm.DB) (*models.GameConfig, error) {
+	var config models.GameConfig
+	err := db.Where("key = ?", models.GameConfigAffixRules).First(&config).Error
+	if err == nil {
+		return &config, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	value, _ := json.Marshal(DefaultAffixRules())
+	config = models.GameConfig{Key: models.GameConfigAffixRules, Value: string(value), Version: 1}
+	if err := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&config).Error; err != nil {
+		return nil, err
+	}
+	if err := db.Where("key = ?", models.GameConfigAffixRules).First(&config).Error; err != nil {
+		return nil, err
+	}
+	return &config, nil
+}
+
+func buildAffixRegistry(db *gorm.DB, config *models.GameConfig) (*AffixRegistry, error) {
+	rules := DefaultAffixRules()
+	if err := json.Unmarshal([]byte(config.Value), &rules); err != nil {
+		return nil, err
+	}
+
+	var definitions []models.AffixDefinition
+	if err := db.Preload("Ranges", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).
+		Order("id").Find(&definitions).Error; err != nil {
+		return nil, err
+	}
+
+	registry := &AffixRegistry{
+		Version:     config.Version,
+		Rules:       rules,
+		Definitions: definitions,
+		byType:      make(map[string]*models.AffixDefinition, len(definitions)),
+	}
+	for i := range definitions {
+		registry.byType[definitions[i].AttrType] = &registry.Definitions[i]
+	}
+	return registry, nil
+}
+
+// BumpAffixVersion 词条定义或规则变更后递增规则集版本号；事务提交后需调用 InvalidateAffixRegistry
+func BumpAffixVersion(tx *gorm.DB, adminName string) error {
+	if _, err := loadAffixRulesConfig(tx); err != nil {
+		return err
+	}
+	return tx.Model(&models.GameConfig{}).Where("key = ?", models.GameConfigAffixRules).
+		Updates(map[string]interface{}{"version": gorm.Expr("version + 1"), "updated_by": adminName}).Error
+}
+
+// SaveAffixRules 保存词条规则并递增规则集版本号
+func SaveAffixRules(tx *gorm.DB, rules AffixRules, adminName string) error {
+	if err := ValidateAffixRules(rules); err != nil {
+		return err
+	}
+	if _, err := loadAffixRulesConfig(tx); err != nil {
+		return err
+	}
+	value, err := json.Marshal(rules)
+	if err != nil {
+		return err
+	}
+	if err := tx.Model(&models.GameConfig{}).Where("key = ?", models.GameConfigAffixRules).
+		Update("value", string(value)).Error; err != nil {
+		return err
+	}
+	return BumpAffixVersion(tx, adminName)
+}
+
+// ValidateAffixRules 校验词条规则
+func ValidateAffixRules(rules AffixRules) error {
+	if rules.ForgeRareChance < 0 || rules.ForgeCommonChance < 0 || rules.ForgeRareChance+rules.ForgeCommonChance > 1 {
+		return fmt.Errorf("%w: 打造词条概率之和必须在0~1之间", ErrAffixInvalid)
+	}
+	if rules.ForgeGoldCost < 0 {
+		return fmt.Errorf("%w: 打造金币不能小于0", ErrAffixInvalid)
+	}
+	if rules.ForgeRarePity < 0 {
+		return fmt.Errorf("%w: 稀有词条保底次数不能小于0", ErrAffixInvalid)
+	}
+	if rules.MergeInheritChance < 0 || rules.MergeInheritChance > 1 {
+		return fmt.Errorf("%w: 融合继承概率必须在0~1之间", ErrAffixInvalid)
+	}
+	for level, rate := range rules.MergeSuccessRates {
+		if rate < 0 || rate > 1 {
+			return fmt.Errorf("%w: 品级%d的融合概率必须在0~1之间", ErrAffixInvalid, level)
+		}
+	}
+	if rules.MaxAffixes <= 0 {
+		return fmt.Errorf("%w: 词条上限必须大于0", ErrAffixInvalid)
+	}
+	if rules.HiddenAffixChance < 0 || rules.HiddenAffixChance > 1 {
+		return fmt.Errorf("%w: 隐藏词条概率必须在0~1之间", ErrAffixInvalid)
+	}
+	for level, limit := range rules.HiddenAffixMax {
+		if limit < 0 || limit > rules.MaxAffixes {
+			return fmt.Errorf("%w: 品级%d的隐藏词条上限必须在0~词条上限之间", ErrAffixInvalid, level)
+		}
+	}
+	if rules.IdentifyChance < 0 || rules.IdentifyChance > 1 {
+		return fmt.Errorf("%w: 鉴定概率必须在0~1之间", ErrAffixInvalid)
+	}
+	if rules.IdentifyDecay < 0 || rules.IdentifyDecay > 1 {
+		return fmt.Errorf("%w: 鉴定概率衰减系数必须在0~1之间", ErrAffixInvalid)
+	}
+	return nil
+}
+
+// ValidateAffixDefinition 校验词条定义及其数值范围
+func ValidateAffixDefinition(def *models.AffixDefinition) error {
+	if def.AttrType == "" || def.Name == "" {
+		return fmt.Errorf("%w: attr_type 和 name 不能为空", ErrAffixInvalid)
+	}
+	if !stats.IsAffixType(def.AttrType) {
+		return fmt.Errorf("%w: attr_type %s 不参与属性计算，支持的类型：%s", ErrAffixInvalid, def.AttrType, strings.Join(stats.AffixTypes, ", "))
+	}
+	if def.Tier != models.AffixTierCommon && def.Tier != models.AffixTierRare {
+		return fmt.Errorf("%w: tier 只支持 common, rare", ErrAffixInvalid)
+	}
+	if def.Decimals < 0 || def.Decimals > 4 {
+		return fmt.Errorf("%w: decimals 必须在0~4之间", ErrAffixInvalid)
+	}
+	for i, r := range def.Ranges {
+		if r.Pool != models.AffixPoolForge && r.Pool != models.AffixPoolMerge {
+			return fmt.Errorf("%w: 第%d个范围的词条池无效", ErrAffixInvalid, i+1)
+		}
+		if r.Level < 0 || r.Level > 6 {
+			return fmt.Errorf("%w: 第%d个范围的品级必须在0~6之间", ErrAffixInvalid, i+1)
+		}
+		if r.Max < r.Min || r.Weight < 0 {
+			return fmt.Errorf("%w: 第%d个范围的数值或权重无效", ErrAffixInvalid, i+1)
+		}
+	}
+	return nil
+}
+
+// Definition 按属性类型查找词条定义（包括已停用的，用于展示旧装备）
+func (r *AffixRegistry) Definition(attrType string) (*models.AffixDefinition, bool) {
+	def, ok := r.byType[attrType]
+	return def, ok
+}
+
+// IsRare 是否为稀有词条
+func (r *AffixRegistry) IsRare(attrType string) bool {
+	def, ok := r.byType[attrType]
+	return ok && def.Tier == models.AffixTierRare
+}
+
+// MergeSuccessRate 融合时按材料装备品级新增词条的概率
+func (r *AffixRegistry) MergeSuccessRate(level int) float64 {
+	return r.Rules.MergeSuccessRates[level]
+}
+
+// ForgeRareGuaranteed 玩家已连续 pity 次打造未出稀有词条时，本次是否触发保底
+func (r *AffixRegistry) ForgeRareGuaranteed(pity int) bool {
+	return r.Rules.ForgeRarePity > 0 && pity >= r.Rules.ForgeRarePity-1
+}
+
+// RollForgeAffix 打造装备时随机词条：先按概率决定稀有/普通/无，再在对应品质中按权重抽取；
+// 触发保底时直接抽取稀有词条
+func (r *AffixRegistry) RollForgeAffix(rng *rand.Rand, level int, guaranteedRare bool) *models.EquipmentAdditionalAttr {
+	if guaranteedRare {
+		return r.roll(rng, models.AffixPoolForge, models.AffixTierRare, level, "")
+	}
+	roll := rng.Float64()
+	switch {
+	case roll < r.Rules.ForgeRareChance:
+		return r.roll(rng, models.AffixPoolForge, models.AffixTierRare, level, "")
+	case roll < r.Rules.ForgeRareChance+r.Rules.ForgeCommonChance:
+		return r.roll(rng, models.AffixPoolForge, models.AffixTierCommon, level, "")
+	}
+	return nil
+}
+
+// AffixOdds 单条词条的出现概率
+type AffixOdds struct {
+	AttrType string  `json:"attr_type"`
+	Name     string  `json:"name"`
+	Tier     string  `json:"tier"`
+	Chance   float64 `json:"chance"`
+}
+
+// ForgeAffixOdds 打造时各词条的出现概率，与 RollForgeAffix 的抽取逻辑一致；第二个返回值为没有词条的概率
+func (r *AffixRegistry) ForgeAffixOdds(level int, guaranteedRare bool) ([]AffixOdds, float64) {
+	tiers := []struct {
+		tier   string
+		chance float64
+	}{
+		{models.AffixTierRare, r.Rules.ForgeRareChance},
+		{models.AffixTierCommon, r.Rules.ForgeCommonChance},
+	}
+	if guaranteedRare {
+		tiers = tiers[:1]
+		tiers[0].chance = 1
+	}
+
+	odds := []AffixOdds{}
+	none := 1.0
+	for _, t := range tiers {
+		candidates, totalWeight := r.candidates(models.AffixPoolForge, t.tier, level, "")
+		if totalWeight == 0 || t.chance <= 0 {
+			// 该品质没有可抽取的词条时 roll 返回 nil，这部分概率计入无词条
+			continue
+		}
+		for _, c := range candidates {
+			odds = append(odds, AffixOdds{
+				AttrType: c.def.AttrType,
+				Name:     r.DisplayName(c.def.AttrType),
+				Tier:     c.def.Tier,
+				Chance:   t.chance * float64(c.rng.Weight) / float64(totalWeight),
+			})
+		}
+		none -= t.chance
+	}
+	return odds, math.Max(none, 0)
+}
+
+// RollMergeAffix 融合时新增词条：在融合词条池中按权重抽取
+func (r *AffixRegistry) RollMergeAffix(rng *rand.Rand, level int) *models.EquipmentAdditionalAttr {
+	return r.roll(rng, models.AffixPoolMerge, "", level, "")
+}
+
+// RollHiddenAffixes 新装备（打造或掉落）按概率带隐藏词条，返回隐藏词条数，0 表示没有
+func (r *AffixRegistry) RollHiddenAffixes(rng *rand.Rand, level int) int {
+	limit := r.Rules.HiddenAffixMax[level]
+	if limit <= 0 || rng.Float64() >= r.Rules.HiddenAffixChance {
+		return 0
+	}
+	return 1 + rng.Intn(limit)
+}
+
+// IdentifyChance 第 identifyCount+1 次鉴定揭示词条的概率，随鉴定次数递减
+func (r *AffixRegistry) IdentifyChance(identifyCount int) float64 {
+	return r.Rules.IdentifyChance * math.Pow(r.Rules.IdentifyDecay, float64(identifyCount))
+}
+
+// RollIdentifyAffix 鉴定揭示隐藏词条：按打造时稀有/普通词条的相对概率决定品质，必定得到一条词条
+func (r *AffixRegistry) RollIdentifyAffix(rng *rand.Rand, level int) *models.EquipmentAdditionalAttr {
+	tier := models.AffixTierCommon
+	total := r.Rules.ForgeRareChance + r.Rules.ForgeCommonChance
+	if total > 0 && rng.Float64()*total < r.Rules.ForgeRareChance {
+		tier = models.AffixTierRare
+	}
+	if attr := r.roll(rng, models.AffixPoolForge, tier, level, ""); attr != nil {
+		return attr
+	}
+	return r.roll(rng, models.AffixPoolForge, "", level, "")
+}
+
+// RollReplaceAffix 重铸时替换词条类型：在打造词条池中抽取同品质、不同类型的词条
+func (r *AffixRegistry) RollReplaceAffix(rng *rand.Rand, tier string, level int, exclude string) *models.EquipmentAdditionalAttr {
+	return r.roll(rng, models.AffixPoolForge, tier, level, exclude)
+}
+
+// RerollValue 重铸时在词条的数值范围内重新随机（优先使用打造词条池的范围，没有时使用融合词条池）
+func (r *AffixRegistry) RerollValue(rng *rand.Rand, attrType string, level int) (float64, bool) {
+	def, ok := r.byType[attrType]
+	if !ok {
+		return 0, false
+	}
+	for _, pool := range []string{models.AffixPoolForge, models.AffixPoolMerge} {
+		if affixRange, ok := rangeFor(def, pool, level); ok {
+			return rollAffixValue(rng, def, affixRange), true
+		}
+	}
+	return 0, false
+}
+
+// roll 在词条池中按权重抽取一条；tier 为空表示不限品质，exclude 为需要排除的属性类型
+func (r *AffixRegistry) roll(rng *rand.Rand, pool, tier string, level int, exclude string) *models.EquipmentAdditionalAttr {
+	candidates, totalWeight := r.candidates(pool, tier, level, exclude)
+	if totalWeight == 0 {
+		return nil
+	}
+
+	pick := rng.Intn(totalWeight)
+	for _, c := range candidates {
+		if pick < c.rng.Weight {
+			return &models.EquipmentAdditionalAttr{
+				AttrType:       c.def.AttrType,
+				AttrName:       c.def.Title,
+				Value:          rollAffixValue(rng, c.def, c.rng),
+				IsPercent:      c.def.IsPercent,
+				RulesetVersion: r.Version,
+			}
+		}
+		pick -= c.rng.Weight
+	}
+	return nil
+}
+
+type affixCandidate struct {
+	def *models.AffixDefinition
+	rng models.AffixRange
+}
+
+// candidates 词条池中可抽取的词条及总权重，抽取和概率公示共用
+func (r *AffixRegistry) candidates(pool, tier string, level int, exclude string) ([]affixCandidate, int) {
+	var candidates []affixCandidate
+	totalWeight := 0
+	for i := range r.Definitions {
+		def := &r.Definitions[i]
+		if !def.IsActive || (tier != "" && def.Tier != tier) || def.AttrType == exclude {
+			continue
+		}
+		if affixRange, ok := rangeFor(def, pool, level); ok && affixRange.Weight > 0 {
+			candidates = append(candidates, affixCandidate{def: def, rng: affixRange})
+			totalWeight += affixRange.Weight
+		}
+	}
+	return candidates, totalWeight
+}
+
+// rollAffixValue 在范围内随机数值并按显示小数位取整；整数词条上下限都可取到
+func rollAffixValue(rng *rand.Rand, def *models.AffixDefinition, r models.AffixRange) float64 {
+	if def.Decimals == 0 {
+		return r.Min + float64(rng.Intn(int(r.Max-r.Min)+1))
+	}
+	scale := math.Pow(10, float64(def.Decimals))
+	return math.Round((r.Min+rng.Float64()*(r.Max-r.Min))*scale) / scale
+}
+
+// rangeFor 查找词条在指定池和品级下的范围，没有该品级的范围时使用通用范围（Level=0）
+func rangeFor(def *models.AffixDefinition, pool string, level int) (models.AffixRange, bool) {
+	var fallback *models.AffixRange
+	for i := range def.Ranges {
+		r := def.Ranges[i]
+		if r.Pool != pool {
+			continue
+		}
+		if r.Level == level {
+			return r, true
+		}
+		if r.Level == 0 {
+			fallback = &def.Ranges[i]
+		}
+	}
+	if fallback != nil {
+		return *fallback, true
+	}
+	return models.AffixRange{}, false
+}
+
+// FormatValue 格式化词条数值：小数位以词条定义为准，没有定义时按词条自身格式化
+func (r *AffixRegistry) FormatValue(attr *models.EquipmentAdditionalAttr) string {
+	def, ok := r.byType[attr.AttrType]
+	if !ok {
+		return attr.FormatValue()
+	}
+	formatted := strconv.FormatFloat(attr.Value, 'f', def.Decimals, 64)
+	if attr.IsPercent {
+		return formatted + "%"
+	}
+	return formatted
+}
+
+// DisplayName 词条展示名称：稀有词条为“称号·效果”，普通词条为效果名称
+func (r *AffixRegistry) DisplayName(attrType string) string {
+	def, ok := r.byType[attrType]
+	if !ok {
+		return attrType
+	}
+	if def.Title != "" {
+		return def.Title + "·" + def.Name
+	}
+	return def.Name
+}
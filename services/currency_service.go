@@ -0,0 +1,126 @@
+package services
+
+import (
+	"errors"
+	"ggo/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrInsufficientGold    = errors.New("金币不足")
+	ErrInsufficientDiamond = errors.New("钻石不足")
+	ErrUnknownCurrency     = errors.New("未知货币类型")
+)
+
+// CurrencyChange 一次余额变动的描述
+type CurrencyChange struct {
+	UserID     uint
+	Currency   string // models.CurrencyGold / models.CurrencyDiamond
+	Delta      int    // 正数增加，负数扣除
+	Reason     string // models.CurrencyReason*
+	SourceType string // 来源实体类型
+	SourceID   uint   // 来源实体ID
+}
+
+// CurrencyMismatch 余额与流水合计不一致的记录
+type CurrencyMismatch struct {
+	UserID    uint   `json:"user_id" gorm:"column:user_id"`
+	Currency  string `json:"currency" gorm:"column:currency"`
+	Balance   int    `json:"balance" gorm:"column:balance"`
+	LedgerSum int    `json:"ledger_sum" gorm:"column:ledger_sum"`
+}
+
+// ChangeCurrency 在调用方的事务中变更用户余额并追加一条流水
+// 会对用户行加锁，余额不足时返回 ErrInsufficientGold / ErrInsufficientDiamond
+func ChangeCurrency(tx *gorm.DB, change CurrencyChange) (*models.CurrencyTransaction, error) {
+	if change.Currency != models.CurrencyGold && change.Currency != models.CurrencyDiamond {
+		return nil, ErrUnknownCurrency
+	}
+
+	var user models.User
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id", "gold", "diamond").First(&user, change.UserID).Error; err != nil {
+		return nil, err
+	}
+
+	balance := user.Gold
+	if change.Currency == models.CurrencyDiamond {
+		balance = user.Diamond
+	}
+
+	newBalance := balance + change.Delta
+	if newBalance < 0 {
+		if change.Currency == models.CurrencyGold {
+			return nil, ErrInsufficientGold
+		}
+		return nil, ErrInsufficientDiamond
+	}
+
+	if err := tx.Model(&models.User{}).Where("id = ?", change.UserID).Update(change.Currency, newBalance).Error; err != nil {
+		return nil, err
+	}
+
+	record := models.CurrencyTransaction{
+		UserID:       change.UserID,
+		Currency:     change.Currency,
+		Delta:        change.Delta,
+		BalanceAfter: newBalance,
+		Reason:       change.Reason,
+		SourceType:   change.SourceType,
+		SourceID:     change.SourceID,
+	}
+	if err := tx.Create(&record).Error; err != nil {
+		return nil, err
+	}
+
+	return &record, nil
+}
+
+// RecordInitialBalances 为新建用户的初始余额写入流水（余额本身已随用户一起创建）
+func RecordInitialBalances(tx *gorm.DB, user *models.User, reason string) error {
+	balances := []struct {
+		currency string
+		amount   int
+	}{
+		{models.CurrencyGold, user.Gold},
+		{models.CurrencyDiamond, user.Diamond},
+	}
+
+	for _, b := range balances {
+		if b.amount == 0 {
+			continue
+		}
+		record := models.CurrencyTransaction{
+			UserID:       user.ID,
+			Currency:     b.currency,
+			Delta:        b.amount,
+			BalanceAfter: b.amount,
+			Reason:       reason,
+			SourceType:   "user",
+			SourceID:     user.ID,
+		}
+		if err := tx.Create(&record).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReconcileCurrencyLedger 找出余额与流水合计不一致的用户
+func ReconcileCurrencyLedger(db *gorm.DB) ([]CurrencyMismatch, error) {
+	querySQL := `SELECT u.id AS user_id, 'gold' AS currency, u.gold AS balance, COALESCE(SUM(t.delta), 0) AS ledger_sum
+FROM users u LEFT JOIN currency_transactions t ON t.user_id = u.id AND t.currency = 'gold'
+GROUP BY u.id, u.gold HAVING u.gold <> COALESCE(SUM(t.delta), 0)
+UNION ALL
+SELECT u.id AS user_id, 'diamond' AS currency, u.diamond AS balance, COALESCE(SUM(t.delta), 0) AS ledger_sum
+FROM users u LEFT JOIN currency_transactions t ON t.user_id = u.id AND t.currency = 'diamond'
+GROUP BY u.id, u.diamond HAVING u.diamond <> COALESCE(SUM(t.delta), 0)
+ORDER BY user_id`
+
+	var mismatches []CurrencyMismatch
+	if err := db.Raw(querySQL).Scan(&mismatches).Error; err != nil {
+		return nil, err
+	}
+	return mismatches, nil
+}
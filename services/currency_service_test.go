@@ -0,0 +1,114 @@
+package services
+
+import (
+	"errors"
+	"ggo/models"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+func TestChangeCurrency(t *testing.T) {
+	tests := []struct {
+		name        string
+		currency    string
+		delta       int
+		wantErr     error
+		wantGold    int
+		wantDiamond int
+	}{
+		{name: "增加金币", currency: models.CurrencyGold, delta: 50, wantGold: 150, wantDiamond: 20},
+		{name: "扣除金币", currency: models.CurrencyGold, delta: -100, wantGold: 0, wantDiamond: 20},
+		{name: "金币不足", currency: models.CurrencyGold, delta: -101, wantErr: ErrInsufficientGold, wantGold: 100, wantDiamond: 20},
+		{name: "增加钻石", currency: models.CurrencyDiamond, delta: 5, wantGold: 100, wantDiamond: 25},
+		{name: "钻石不足", currency: models.CurrencyDiamond, delta: -21, wantErr: ErrInsufficientDiamond, wantGold: 100, wantDiamond: 20},
+		{name: "未知货币", currency: "coupon", delta: 1, wantErr: ErrUnknownCurrency, wantGold: 100, wantDiamond: 20},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestDB(t)
+			user := createTestUser(t, db, "player", 100, 20)
+
+			var record *models.CurrencyTransaction
+			err := db.Transaction(func(tx *gorm.DB) error {
+				var err error
+				record, err = ChangeCurrency(tx, CurrencyChange{
+					UserID:     user.ID,
+					Currency:   tt.currency,
+					Delta:      tt.delta,
+					Reason:     models.CurrencyReasonMailClaim,
+					SourceType: "mail",
+					SourceID:   7,
+				})
+				return err
+			})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+
+			gold, diamond := userBalance(t, db, user.ID)
+			if gold != tt.wantGold || diamond != tt.wantDiamond {
+				t.Fatalf("balance = %d/%d, want %d/%d", gold, diamond, tt.wantGold, tt.wantDiamond)
+			}
+			assertLedgerBalanced(t, db)
+
+			if tt.wantErr != nil {
+				return
+			}
+			want := tt.wantGold
+			if tt.currency == models.CurrencyDiamond {
+				want = tt.wantDiamond
+			}
+			if record.BalanceAfter != want || record.Delta != tt.delta || record.SourceType != "mail" || record.SourceID != 7 {
+				t.Fatalf("record = %+v", record)
+			}
+		})
+	}
+}
+
+func TestChangeCurrencyRollsBackWithTransaction(t *testing.T) {
+	db := newTestDB(t)
+	user := createTestUser(t, db, "player", 100, 0)
+
+	failure := errors.New("later step failed")
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if _, err := ChangeCurrency(tx, CurrencyChange{
+			UserID:   user.ID,
+			Currency: models.CurrencyGold,
+			Delta:    -60,
+			Reason:   models.CurrencyReasonShopBuy,
+		}); err != nil {
+			return err
+		}
+		return failure
+	})
+	if !errors.Is(err, failure) {
+		t.Fatalf("err = %v, want %v", err, failure)
+	}
+
+	if gold, _ := userBalance(t, db, user.ID); gold != 100 {
+		t.Fatalf("gold = %d, want 100", gold)
+	}
+	var count int64
+	db.Model(&models.CurrencyTransaction{}).Where("user_id = ? AND reason = ?", user.ID, models.CurrencyReasonShopBuy).Count(&count)
+	if count != 0 {
+		t.Fatalf("ledger rows = %d, want 0", count)
+	}
+	assertLedgerBalanced(t, db)
+}
+
+func TestReconcileCurrencyLedgerFindsMismatch(t *testing.T) {
+	db := newTestDB(t)
+	user := createTestUser(t, db, "player", 100, 0)
+	// 绕过账本直接改余额
+	db.Model(&models.User{}).Where("id = ?", user.ID).Update("gold", 150)
+
+	mismatches, err := ReconcileCurrencyLedger(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mismatches) != 1 || mismatches[0].Currency != models.CurrencyGold ||
+		mismatches[0].Balance != 150 || mismatches[0].LedgerSum != 100 {
+		t.Fatalf("mismatches = %+v", mismatches)
+	}
+}
@@ -0,0 +1,339 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"ggo/models"
+	"math/rand"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const maxDropSimulations = 100000 // 单次模拟最多抽取次数
+
+var ErrDropTableInvalid = errors.New("掉落表配置无效")
+
+// DropItem 一次掉落的结果
+type DropItem struct {
+	Kind   string `json:"kind"`             // 类型：treasure, equipment, consumable, gold, diamond
+	RefID  uint   `json:"ref_id"`           // 宝物ID、装备模板ID或消耗品ID
+	Name   string `json:"name"`             // 名称（发放后填充）
+	Num    int    `json:"num"`              // 数量
+	Mailed int    `json:"mailed,omitempty"` // 背包放不下、改为邮件发放的数量（包含在 Num 中）
+}
+
+// DropStat 模拟结果中单个条目的统计
+type DropStat struct {
+	EntryID    uint    `json:"entry_id"`
+	Kind       string  `json:"kind"`
+	RefID      uint    `json:"ref_id"`
+	Guaranteed bool    `json:"guaranteed"`
+	Hits       int     `json:"hits"`      // 掉落次数
+	TotalQty   int     `json:"total_qty"` // 累计数量
+	Rate       float64 `json:"rate"`      // 每次掉落命中该条目的平均次数
+	AvgQty     float64 `json:"avg_qty"`   // 每次掉落的平均数量
+}
+
+// DropSimulation 掉落表模拟结果
+type DropSimulation struct {
+	DropTableID uint       `json:"drop_table_id"`
+	Times       int        `json:"times"`
+	Level       int        `json:"level"`
+	Empty       int        `json:"empty"`      // 什么都没掉的次数
+	EmptyRate   float64    `json:"empty_rate"` // 空掉落比例
+	Entries     []DropStat `json:"entries"`
+}
+
+// ValidateDropTable 校验掉落表及其条目
+func ValidateDropTable(table *models.DropTable) error {
+	if table.Rolls < 0 || table.NoneWeight < 0 {
+		return fmt.Errorf("%w: rolls 和 none_weight 不能为负数", ErrDropTableInvalid)
+	}
+	for i, entry := range table.Entries {
+		if !models.IsValidDropKind(entry.Kind) {
+			return fmt.Errorf("%w: 第%d个条目类型无效", ErrDropTableInvalid, i+1)
+		}
+		if (entry.Kind == models.DropKindTreasure || entry.Kind == models.DropKindEquipment || entry.Kind == models.DropKindConsumable) && entry.RefID == 0 {
+			return fmt.Errorf("%w: 第%d个条目缺少 ref_id", ErrDropTableInvalid, i+1)
+		}
+		if entry.MinQty <= 0 || entry.MaxQty < entry.MinQty {
+			return fmt.Errorf("%w: 第%d个条目数量范围无效", ErrDropTableInvalid, i+1)
+		}
+		if !entry.Guaranteed && entry.Weight <= 0 {
+			return fmt.Errorf("%w: 第%d个条目权重必须大于0", ErrDropTableInvalid, i+1)
+		}
+		if entry.MinLevel < 0 || entry.MaxLevel < 0 || (entry.MaxLevel > 0 && entry.MaxLevel < entry.MinLevel) {
+			return fmt.Errorf("%w: 第%d个条目等级范围无效", ErrDropTableInvalid, i+1)
+		}
+	}
+	return nil
+}
+
+// RollDropTable 对掉落表抽取一次：保底条目必掉，其余条目按权重抽取 Rolls 次；level 为玩家等级，用于等级限制
+func RollDropTable(table *models.DropTable, rng *rand.Rand, level int) []DropItem {
+	items, _ := rollDropEntries(table, rng, level)
+	return items
+}
+
+// rollDropEntries 返回掉落结果以及命中的条目下标（模拟统计使用）
+func rollDropEntries(table *models.DropTable, rng *rand.Rand, level int) ([]DropItem, []int) {
+	var items []DropItem
+	var hits []int
+
+	var pool []int
+	totalWeight := table.NoneWeight
+	for i, entry := range table.Entries {
+		if !dropEntryAllowed(entry, level) {
+			continue
+		}
+		if entry.Guaranteed {
+			items = append(items, DropItem{Kind: entry.Kind, RefID: entry.RefID, Num: rollQty(entry, rng)})
+			hits = append(hits, i)
+			continue
+		}
+		pool = append(pool, i)
+		totalWeight += entry.Weight
+	}
+
+	if len(pool) == 0 || totalWeight <= 0 {
+		return items, hits
+	}
+	for r := 0; r < table.Rolls; r++ {
+		roll := rng.Intn(totalWeight)
+		if roll < table.NoneWeight {
+			continue
+		}
+		roll -= table.NoneWeight
+		for _, i := range pool {
+			entry := table.Entries[i]
+			if roll < entry.Weight {
+				items = append(items, DropItem{Kind: entry.Kind, RefID: entry.RefID, Num: rollQty(entry, rng)})
+				hits = append(hits, i)
+				break
+			}
+			roll -= entry.Weight
+		}
+	}
+	return items, hits
+}
+
+func dropEntryAllowed(entry models.DropTableEntry, level int) bool {
+	if entry.MinLevel > 0 && level < entry.MinLevel {
+		return false
+	}
+	if entry.MaxLevel > 0 && level > entry.MaxLevel {
+		return false
+	}
+	return true
+}
+
+func rollQty(entry models.DropTableEntry, rng *rand.Rand) int {
+	if entry.MaxQty <= entry.MinQty {
+		return entry.MinQty
+	}
+	return entry.MinQty + rng.Intn(entry.MaxQty-entry.MinQty+1)
+}
+
+// LoadDropTables 查询挂载在来源上的启用中的掉落表
+func LoadDropTables(tx *gorm.DB, sourceType string, sourceID uint) ([]models.DropTable, error) {
+	var tables []models.DropTable
+	err := tx.Preload("Entries", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).
+		Joins("JOIN drop_table_bindings ON drop_table_bindings.drop_table_id = drop_tables.id").
+		Where("drop_table_bindings.source_type = ? AND drop_table_bindings.source_id = ? AND drop_tables.is_active = ?", sourceType, sourceID, true).
+		Order("drop_tables.id").Find(&tables).Error
+	return tables, err
+}
+
+// RollSourceDrops 对来源挂载的所有掉落表各抽取 times 次
+func RollSourceDrops(tx *gorm.DB, rng *rand.Rand, sourceType string, sourceID uint, level, times int) ([]DropItem, error) {
+	if times <= 0 {
+		return nil, nil
+	}
+	tables, err := LoadDropTables(tx, sourceType, sourceID)
+	if err != nil {
+		return nil, err
+	}
+
+	var items []DropItem
+	for i := range tables {
+		for t := 0; t < times; t++ {
+			items = append(items, RollDropTable(&tables[i], rng, level)...)
+		}
+	}
+	return items, nil
+}
+
+// MergeDrops 合并相同类型和ID的掉落，保持首次出现的顺序
+func MergeDrops(items []DropItem) []DropItem {
+	merged := make([]DropItem, 0, len(items))
+	index := make(map[string]int)
+	for _, item := range items {
+		key := fmt.Sprintf("%s:%d", item.Kind, item.RefID)
+		if i, ok := index[key]; ok {
+			merged[i].Num += item.Num
+			continue
+		}
+		index[key] = len(merged)
+		merged = append(merged, item)
+	}
+	return merged
+}
+
+// GrantDrops 发放掉落：金币/钻石记入流水，宝物和消耗品叠加到背包，装备创建到背包；
+// 背包空间或堆叠上限不足时，放不下的部分以邮件发到 area 区服。返回填充了名称和邮件数量的掉落
+func GrantDrops(tx *gorm.DB, userID uint, area int, items []DropItem, reason, sourceType string, sourceID uint) ([]DropItem, error) {
+	granted := make([]DropItem, 0, len(items))
+	for _, item := range MergeDrops(items) {
+		switch item.Kind {
+		case models.DropKindGold, models.DropKindDiamond:
+			if _, err := ChangeCurrency(tx, CurrencyChange{
+				UserID:     userID,
+				Currency:   item.Kind,
+				Delta:      item.Num,
+				Reason:     reason,
+				SourceType: sourceType,
+				SourceID:   sourceID,
+			}); err != nil {
+				return nil, err
+			}
+		case models.DropKindTreasure:
+			var treasure models.Treasure
+			if err := tx.First(&treasure, item.RefID).Error; err != nil {
+				return nil, fmt.Errorf("宝物不存在: %d", item.RefID)
+			}
+			item.Name = treasure.Name
+			if err := grantDropStack(tx, userID, area, models.MyItemTypeTreasure, &item); err != nil {
+				return nil, err
+			}
+		case models.DropKindConsumable:
+			var consumable models.Consumable
+			if err := tx.First(&consumable, item.RefID).Error; err != nil {
+				return nil, fmt.Errorf("消耗品不存在: %d", item.RefID)
+			}
+			item.Name = consumable.Name
+			if err := grantDropStack(tx, userID, area, models.MyItemTypeConsumable, &item); err != nil {
+				return nil, err
+			}
+		case models.DropKindEquipment:
+			var tpl models.EquipmentTemplate
+			if err := tx.First(&tpl, item.RefID).Error; err != nil {
+				return nil, fmt.Errorf("装备模板不存在: %d", item.RefID)
+			}
+			registry, err := GetAffixRegistry(tx)
+			if err != nil {
+				return nil, err
+			}
+			free, err := FreeSlots(tx, userID, models.PositionBackpack)
+			if err != nil {
+				return nil, err
+			}
+			item.Name = tpl.Name
+			if free < item.Num {
+				item.Mailed = item.Num - max(free, 0)
+				// 邮件领取时每封只创建一件装备
+				for i := 0; i < item.Mailed; i++ {
+					if err := sendDropOverflowMail(tx, userID, area, item, 1); err != nil {
+						return nil, err
+					}
+				}
+			}
+			rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+			for i := 0; i < item.Num-item.Mailed; i++ {
+				// 掉落的装备不带明词条，按概率带隐藏词条，需要鉴定后揭示
+				if err := tx.Create(&models.UserEquipment{
+					UserID:        userID,
+					EquipmentID:   item.RefID,
+					Position:      "backpack",
+					HiddenAffixes: registry.RollHiddenAffixes(rng, tpl.Level),
+				}).Error; err != nil {
+					return nil, err
+				}
+			}
+		default:
+			return nil, fmt.Errorf("未知掉落类型: %s", item.Kind)
+		}
+		granted = append(granted, item)
+	}
+	return granted, nil
+}
+
+// SimulateDropTable 模拟抽取 times 次，返回各条目的命中分布，供策划发布前核对概率
+func SimulateDropTable(table *models.DropTable, times, level int, seed int64) *DropSimulation {
+	if times > maxDropSimulations {
+		times = maxDropSimulations
+	}
+	rng := rand.New(rand.NewSource(seed))
+
+	stats := make([]DropStat, len(table.Entries))
+	for i, entry := range table.Entries {
+		stats[i] = DropStat{EntryID: entry.ID, Kind: entry.Kind, RefID: entry.RefID, Guaranteed: entry.Guaranteed}
+	}
+
+	result := &DropSimulation{DropTableID: table.ID, Times: times, Level: level}
+	for t := 0; t < times; t++ {
+		items, hits := rollDropEntries(table, rng, level)
+		if len(items) == 0 {
+			result.Empty++
+		}
+		for k, i := range hits {
+			stats[i].Hits++
+			stats[i].TotalQty += items[k].Num
+		}
+	}
+
+	if times > 0 {
+		result.EmptyRate = float64(result.Empty) / float64(times)
+		for i := range stats {
+			stats[i].Rate = float64(stats[i].Hits) / float64(times)
+			stats[i].AvgQty = float64(stats[i].TotalQty) / float64(times)
+		}
+	}
+	sort.SliceStable(stats, func(a, b int) bool { return stats[a].Hits > stats[b].Hits })
+	result.Entries = stats
+	return result
+}
+
+// grantDropStack 发放可堆叠的掉落，背包放不下的数量改为邮件发放
+func grantDropStack(tx *gorm.DB, userID uint, area int, itemType string, item *DropItem) error {
+	room, _, _, err := MyItemRoom(tx, userID, itemType, item.RefID, models.PositionBackpack)
+	if err != nil {
+		return err
+	}
+	if room > 0 {
+		if err := GrantMyItem(tx, userID, itemType, item.RefID, min(room, item.Num)); err != nil {
+			return err
+		}
+	}
+	if room < item.Num {
+		item.Mailed = item.Num - room
+		return sendDropOverflowMail(tx, userID, area, *item, item.Mailed)
+	}
+	return nil
+}
+
+// sendDropOverflowMail 背包放不下的掉落以邮件发放
+func sendDropOverflowMail(tx *gorm.DB, userID uint, area int, item DropItem, num int) error {
+	return tx.Create(&models.Mail{
+		UserID:   userID,
+		Area:     area,
+		Title:    "背包已满",
+		Content:  fmt.Sprintf("背包空间不足，%s ×%d 已通过邮件发放，请整理背包后领取。", item.Name, num),
+		ItemType: DropMailItemType(item.Kind),
+		ItemID:   item.RefID,
+		Num:      num,
+		Status:   0,
+	}).Error
+}
+
+// DropMailItemType 掉落类型对应的邮件物品类型
+func DropMailItemType(kind string) string {
+	switch kind {
+	case models.DropKindTreasure:
+		return "treasures"
+	case models.DropKindConsumable:
+		return "consumables"
+	}
+	return kind
+}
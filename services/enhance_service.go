@@ -0,0 +1,325 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"ggo/models"
+	"ggo/services/stats"
+	"math/rand"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrEquipmentNotFound       = errors.New("装备不存在或不属于该用户")
+	ErrEnhanceMaxLevel         = errors.New("装备已达到最高强化等级")
+	ErrBreakthroughRequired    = errors.New("当前等级需要突破材料")
+	ErrBreakthroughMaterial    = errors.New("突破材料无效")
+	ErrTreasureNotEnough       = errors.New("宝物不存在或数量不足")
+	ErrConsumableInvalid       = errors.New("道具不存在、数量不足或类型不符")
+	errEnhanceCostNotAvailable = errors.New("未配置该品级的强化消耗")
+)
+
+const (
+	enhancePityStep      = 0.05 // 每次连续失败增加的成功率
+	enhancePityGuarantee = 10   // 连续失败达到该次数后下一次必定成功
+)
+
+// 突破节点：从该强化等级继续强化时，需要额外消耗的宝物数量（宝物等级不低于装备品级）
+var enhanceBreakthroughs = map[int]int{
+	5:  1,
+	10: 2,
+	15: 3,
+	20: 4,
+}
+
+// EnhanceRequest 强化请求（均为可选）
+type EnhanceRequest struct {
+	ItemIDs       []uint `json:"item_ids"`        // 突破材料（背包宝物记录ID，可重复）
+	ProtectItemID uint   `json:"protect_item_id"` // 强化保护符（背包物品记录ID），失败不降级
+	LuckItemID    uint   `json:"luck_item_id"`    // 幸运符（背包物品记录ID），增加成功率
+}
+
+// EnhanceResult 强化结果
+type EnhanceResult struct {
+	Success       bool                  `json:"success"`
+	BaseRate      float64               `json:"base_rate"`  // 基础成功率
+	FinalRate     float64               `json:"final_rate"` // 计入保底和幸运符后的成功率
+	Guaranteed    bool                  `json:"guaranteed"` // 是否触发保底必成
+	Protected     bool                  `json:"protected"`  // 失败时是否因保护符未降级
+	FailCount     int                   `json:"fail_count"` // 强化后的连续失败次数
+	CostGold      int                   `json:"cost_gold"`
+	CurrentGold   int                   `json:"current_gold"`
+	OldLevel      int                   `json:"old_level"`
+	NewLevel      int                   `json:"new_level"`
+	MaxLevel      int                   `json:"max_level"`
+	Breakthrough  bool                  `json:"breakthrough"`   // 本次是否为突破（消耗了宝物）
+	UsedTreasures []models.Treasure     `json:"used_treasures"` // 消耗的突破材料
+	UsedItems     []models.Consumable   `json:"used_items"`     // 消耗的保护符/幸运符
+	Before        stats.EquipmentStats  `json:"before"`         // 强化前装备基础属性
+	After         stats.EquipmentStats  `json:"after"`          // 强化后装备基础属性
+	Equipment     *models.UserEquipment `json:"equipment"`
+}
+
+// EnhanceService 装备强化
+type EnhanceService struct {
+	DB *gorm.DB
+}
+
+func NewEnhanceService(db *gorm.DB) *EnhanceService {
+	return &EnhanceService{DB: db}
+}
+
+// EnhanceBreakthroughCost 从 level 强化到下一级需要的突破宝物数量，0 表示不是突破节点
+func EnhanceBreakthroughCost(level int) int {
+	return enhanceBreakthroughs[level]
+}
+
+// enhanceCost 强化消耗的金币，按装备品级
+func enhanceCost(rarity int) (int, error) {
+	costs := map[int]int{
+		1: 10000,  // 1万
+		2: 30000,  // 3万
+		3: 50000,  // 5万
+		4: 80000,  // 8万
+		5: 100000, // 10万
+		6: 200000, // 20万
+	}
+	cost, ok := costs[rarity]
+	if !ok {
+		return 0, errEnhanceCostNotAvailable
+	}
+	return cost, nil
+}
+
+// enhanceSuccessRate 强化成功率，按当前强化等级
+func enhanceSuccessRate(currentLevel int) float64 {
+	rates := map[int]float64{
+		0: 0.90, 1: 0.90, 2: 0.90, // 1~3: 90%
+		3: 0.80, 4: 0.80, // 3~5: 80%
+		5: 0.70, 6: 0.70, 7: 0.70, // 5~8: 70%
+		8: 0.60, 9: 0.60, // 8~10: 60%
+		10: 0.50, 11: 0.50, 12: 0.50, // 10~13: 50%
+		13: 0.40, 14: 0.40, // 13~15: 40%
+		15: 0.30, 16: 0.30, 17: 0.30, // 15~18: 30%
+	}
+
+	// 18级以上统一20%
+	if currentLevel >= 18 {
+		return 0.20
+	}
+
+	return rates[currentLevel]
+}
+
+// enhanceRate 计算成功率：基础成功率 + 连续失败保底 + 幸运符，连续失败达到上限时必定成功
+func enhanceRate(level, fails int, luck float64) (rate float64, guaranteed bool) {
+	if fails >= enhancePityGuarantee {
+		return 1, true
+	}
+	rate = enhanceSuccessRate(level) + float64(fails)*enhancePityStep + luck
+	if rate >= 1 {
+		return 1, false
+	}
+	return rate, false
+}
+
+// Enhance 强化装备：校验等级上限，突破节点额外消耗宝物，扣除金币后判定成功（+1）或失败（-1，最低0，使用保护符时不降级）
+// 保护符和幸运符无论成败都会消耗；每次强化写入强化记录
+func (s *EnhanceService) Enhance(userID, equipmentID uint, req EnhanceRequest) (*EnhanceResult, error) {
+	result := &EnhanceResult{UsedTreasures: []models.Treasure{}, UsedItems: []models.Consumable{}}
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		var equipment models.UserEquipment
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND user_id = ?", equipmentID, userID).First(&equipment).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrEquipmentNotFound
+			}
+			return err
+		}
+		if err := tx.First(&equipment.EquipmentTemplate, equipment.EquipmentID).Error; err != nil {
+			return err
+		}
+
+		rarity := equipment.EquipmentTemplate.Level
+		result.OldLevel = equipment.EnhanceLevel
+		result.MaxLevel = stats.MaxEnhanceLevel(rarity)
+		if equipment.EnhanceLevel >= result.MaxLevel {
+			return ErrEnhanceMaxLevel
+		}
+
+		// 突破节点需要宝物
+		if need := EnhanceBreakthroughCost(equipment.EnhanceLevel); need > 0 {
+			if len(req.ItemIDs) == 0 {
+				return fmt.Errorf("%w：需要%d个%d级及以上的宝物", ErrBreakthroughRequired, need, rarity)
+			}
+			if len(req.ItemIDs) != need {
+				return fmt.Errorf("%w：需要%d个宝物", ErrBreakthroughMaterial, need)
+			}
+			treasures, err := consumeTreasureItems(tx, userID, req.ItemIDs)
+			if err != nil {
+				return err
+			}
+			for _, treasure := range treasures {
+				if treasure.Level < rarity {
+					return fmt.Errorf("%w：宝物等级不能低于装备品级", ErrBreakthroughMaterial)
+				}
+			}
+			result.Breakthrough = true
+			result.UsedTreasures = treasures
+		}
+
+		cost, err := enhanceCost(rarity)
+		if err != nil {
+			return err
+		}
+		goldTx, err := ChangeCurrency(tx, CurrencyChange{
+			UserID:     userID,
+			Currency:   models.CurrencyGold,
+			Delta:      -cost,
+			Reason:     models.CurrencyReasonEquipmentEnhance,
+			SourceType: "user_equipment",
+			SourceID:   equipment.ID,
+		})
+		if err != nil {
+			return err
+		}
+		result.CostGold = cost
+		result.CurrentGold = goldTx.BalanceAfter
+
+		log := models.EnhanceLog{
+			UserID:          userID,
+			UserEquipmentID: equipment.ID,
+			EquipmentID:     equipment.EquipmentID,
+			Rarity:          rarity,
+			OldLevel:        equipment.EnhanceLevel,
+			FailCount:       equipment.EnhanceFails,
+			Breakthrough:    result.Breakthrough,
+			CostGold:        cost,
+		}
+
+		var protect bool
+		var luck float64
+		if req.ProtectItemID != 0 {
+			consumable, err := consumeConsumable(tx, userID, req.ProtectItemID, models.ConsumableEnhanceProtect)
+			if err != nil {
+				return err
+			}
+			protect = true
+			log.ProtectItemID = consumable.ID
+			result.UsedItems = append(result.UsedItems, *consumable)
+		}
+		if req.LuckItemID != 0 {
+			consumable, err := consumeConsumable(tx, userID, req.LuckItemID, models.ConsumableEnhanceLuck)
+			if err != nil {
+				return err
+			}
+			luck = consumable.Value
+			log.LuckItemID = consumable.ID
+			result.UsedItems = append(result.UsedItems, *consumable)
+		}
+
+		result.BaseRate = enhanceSuccessRate(equipment.EnhanceLevel)
+		result.FinalRate, result.Guaranteed = enhanceRate(equipment.EnhanceLevel, equipment.EnhanceFails, luck)
+		rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+		result.Success = result.Guaranteed || rng.Float64() < result.FinalRate
+		switch {
+		case result.Success:
+			result.NewLevel = equipment.EnhanceLevel + 1
+			result.FailCount = 0
+		case protect:
+			result.NewLevel = equipment.EnhanceLevel
+			result.Protected = true
+			result.FailCount = equipment.EnhanceFails + 1
+		default:
+			result.NewLevel = equipment.EnhanceLevel - 1
+			if result.NewLevel < 0 {
+				result.NewLevel = 0
+			}
+			result.FailCount = equipment.EnhanceFails + 1
+		}
+
+		if err := tx.Model(&equipment).Updates(map[string]interface{}{
+			"enhance_level": result.NewLevel,
+			"enhance_fails": result.FailCount,
+		}).Error; err != nil {
+			return err
+		}
+
+		log.NewLevel = result.NewLevel
+		log.Success = result.Success
+		log.BaseRate = result.BaseRate
+		log.FinalRate = result.FinalRate
+		log.Guaranteed = result.Guaranteed
+		log.Protected = result.Protected
+		if err := tx.Create(&log).Error; err != nil {
+			return err
+		}
+
+		result.Before = stats.EquipmentBase(&equipment, result.OldLevel)
+		result.After = stats.EquipmentBase(&equipment, result.NewLevel)
+		equipment.EnhanceLevel = result.NewLevel
+		equipment.EnhanceFails = result.FailCount
+		result.Equipment = &equipment
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if result.Equipment.IsEquipped {
+		// 战力为派生数据，刷新失败不影响本次强化
+		stats.Refresh(s.DB, userID)
+	}
+	return result, nil
+}
+
+// consumeTreasureItems 按背包宝物记录ID各消耗1个（ID可重复）；返回对应的宝物
+func consumeTreasureItems(tx *gorm.DB, userID uint, itemIDs []uint) ([]models.Treasure, error) {
+	counts := make(map[uint]int, len(itemIDs))
+	for _, id := range itemIDs {
+		counts[id]++
+	}
+
+	treasureByItem := make(map[uint]models.Treasure, len(counts))
+	for id, num := range counts {
+		myItem, err := TakeMyItem(tx, userID, models.MyItemTypeTreasure, id, num)
+		if errors.Is(err, ErrMyItemNotEnough) {
+			return nil, ErrTreasureNotEnough
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var treasure models.Treasure
+		if err := tx.First(&treasure, myItem.ItemID).Error; err != nil {
+			return nil, ErrTreasureNotEnough
+		}
+		treasureByItem[id] = treasure
+	}
+
+	treasures := make([]models.Treasure, 0, len(itemIDs))
+	for _, id := range itemIDs {
+		treasures = append(treasures, treasureByItem[id])
+	}
+	return treasures, nil
+}
+
+// consumeConsumable 消耗1个指定类型的消耗品
+func consumeConsumable(tx *gorm.DB, userID, myItemID uint, kind string) (*models.Consumable, error) {
+	myItem, err := TakeMyItem(tx, userID, models.MyItemTypeConsumable, myItemID, 1)
+	if errors.Is(err, ErrMyItemNotEnough) {
+		return nil, ErrConsumableInvalid
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var consumable models.Consumable
+	if err := tx.First(&consumable, myItem.ItemID).Error; err != nil || consumable.Kind != kind {
+		return nil, ErrConsumableInvalid
+	}
+	return &consumable, nil
+}
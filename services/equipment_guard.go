@@ -0,0 +1,28 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"ggo/models"
+)
+
+var (
+	ErrEquipmentEquipped = errors.New("装备穿戴中")
+	ErrEquipmentLocked   = errors.New("装备已锁定")
+	ErrEquipmentFavorite = errors.New("收藏的装备不能批量处理")
+)
+
+// CheckEquipmentDisposable 校验装备可以被消耗（回收、作为融合材料、出售等会删除装备的操作）：
+// 锁定的装备一律拒绝，穿戴中的装备需要先卸下；批量操作时收藏的装备也会被拒绝，需要单独处理
+func CheckEquipmentDisposable(eq *models.UserEquipment, batch bool) error {
+	if eq.IsLocked {
+		return fmt.Errorf("%w，请先解锁（ID: %d）", ErrEquipmentLocked, eq.ID)
+	}
+	if eq.IsEquipped {
+		return fmt.Errorf("%w，请先卸下（ID: %d）", ErrEquipmentEquipped, eq.ID)
+	}
+	if batch && eq.IsFavorite {
+		return fmt.Errorf("%w，请取消收藏或单独处理（ID: %d）", ErrEquipmentFavorite, eq.ID)
+	}
+	return nil
+}
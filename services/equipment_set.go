@@ -0,0 +1,68 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"ggo/models"
+	"ggo/services/stats"
+
+	"gorm.io/gorm"
+)
+
+var ErrEquipmentSetInvalid = errors.New("套装配置无效")
+
+// ValidateEquipmentSet 校验套装：模板存在且不属于其他套装，加成件数在2~套装件数之间，属性类型为已定义且参与属性计算的词条
+func ValidateEquipmentSet(db *gorm.DB, set *models.EquipmentSet) error {
+	if set.Name == "" {
+		return fmt.Errorf("%w: name 不能为空", ErrEquipmentSetInvalid)
+	}
+	if len(set.Items) < 2 {
+		return fmt.Errorf("%w: 套装至少包含2个装备模板", ErrEquipmentSetInvalid)
+	}
+
+	templateIDs := make([]uint, 0, len(set.Items))
+	seen := make(map[uint]bool, len(set.Items))
+	for i, item := range set.Items {
+		if item.EquipmentTemplateID == 0 || seen[item.EquipmentTemplateID] {
+			return fmt.Errorf("%w: 第%d个装备模板为空或重复", ErrEquipmentSetInvalid, i+1)
+		}
+		seen[item.EquipmentTemplateID] = true
+		templateIDs = append(templateIDs, item.EquipmentTemplateID)
+	}
+
+	var count int64
+	if err := db.Model(&models.EquipmentTemplate{}).Where("id IN ?", templateIDs).Count(&count).Error; err != nil {
+		return err
+	}
+	if int(count) != len(templateIDs) {
+		return fmt.Errorf("%w: 装备模板不存在", ErrEquipmentSetInvalid)
+	}
+
+	var taken []models.EquipmentSetItem
+	if err := db.Where("equipment_template_id IN ? AND equipment_set_id <> ?", templateIDs, set.ID).Find(&taken).Error; err != nil {
+		return err
+	}
+	if len(taken) > 0 {
+		return fmt.Errorf("%w: 装备模板%d已属于套装%d", ErrEquipmentSetInvalid, taken[0].EquipmentTemplateID, taken[0].EquipmentSetID)
+	}
+
+	registry, err := GetAffixRegistry(db)
+	if err != nil {
+		return err
+	}
+	for i, bonus := range set.Bonuses {
+		if bonus.Pieces < 2 || bonus.Pieces > len(set.Items) {
+			return fmt.Errorf("%w: 第%d个加成的件数必须在2~%d之间", ErrEquipmentSetInvalid, i+1, len(set.Items))
+		}
+		if _, ok := registry.Definition(bonus.AttrType); !ok {
+			return fmt.Errorf("%w: 第%d个加成的属性类型 %s 未定义", ErrEquipmentSetInvalid, i+1, bonus.AttrType)
+		}
+		if !stats.IsAffixType(bonus.AttrType) {
+			return fmt.Errorf("%w: 第%d个加成的属性类型 %s 不参与属性计算", ErrEquipmentSetInvalid, i+1, bonus.AttrType)
+		}
+		if bonus.Value <= 0 {
+			return fmt.Errorf("%w: 第%d个加成的数值必须大于0", ErrEquipmentSetInvalid, i+1)
+		}
+	}
+	return nil
+}
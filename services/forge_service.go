@@ -0,0 +1,301 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"ggo/models"
+	"math/rand"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 打造固定消耗的宝物数量（可重复选择同一条背包记录）
+const forgeTreasureCount = 3
+
+var (
+	ErrForgeTreasureCount   = fmt.Errorf("打造需要选择%d个宝物", forgeTreasureCount)
+	ErrForgeTemplateMissing = errors.New("没有找到合适的装备模板")
+)
+
+// ForgeTemplateOdds 某个装备模板被选中的概率（在该品级下）
+type ForgeTemplateOdds struct {
+	ID     uint    `json:"id"`
+	Name   string  `json:"name"`
+	Slot   string  `json:"slot"`
+	Chance float64 `json:"chance"`
+}
+
+// ForgeLevelOdds 打造出某个品级的概率，以及该品级下模板、词条和隐藏词条的概率
+type ForgeLevelOdds struct {
+	Level             int                 `json:"level"`
+	Chance            float64             `json:"chance"`              // 打造出该品级的概率
+	Templates         []ForgeTemplateOdds `json:"templates"`           // 该品级下各模板的概率
+	Affixes           []AffixOdds         `json:"affixes"`             // 该品级下各词条的概率
+	NoAffixChance     float64             `json:"no_affix_chance"`     // 该品级下没有词条的概率
+	HiddenAffixChance float64             `json:"hidden_affix_chance"` // 带隐藏词条的概率
+	HiddenAffixMax    int                 `json:"hidden_affix_max"`    // 隐藏词条数上限（数量在1~上限之间均匀随机）
+}
+
+// ForgePreview 打造预览：消耗、保底进度和完整概率
+type ForgePreview struct {
+	CostGold       int               `json:"cost_gold"`
+	Treasures      []models.Treasure `json:"treasures"`       // 选择的宝物，等级有可用模板的宝物被选为基准的概率相同
+	Pity           int               `json:"pity"`            // 已连续未出稀有词条的打造次数
+	PityThreshold  int               `json:"pity_threshold"`  // 保底次数，0 表示未开启
+	RareGuaranteed bool              `json:"rare_guaranteed"` // 本次打造是否必出稀有词条
+	RulesetVersion int               `json:"ruleset_version"` // 概率所依据的词条规则集版本
+	Levels         []ForgeLevelOdds  `json:"levels"`
+}
+
+// ForgeResult 打造结果
+type ForgeResult struct {
+	Equipment      *models.UserEquipment `json:"user_equipment"`
+	CostGold       int                   `json:"cost_gold"`
+	CurrentGold    int                   `json:"current_gold"`
+	Level          int                   `json:"equipment_level"`
+	Treasures      []models.Treasure     `json:"used_treasures"`
+	SelectedIndex  int                   `json:"selected_treasure_index"`
+	RareGuaranteed bool                  `json:"rare_guaranteed"` // 本次是否触发了稀有词条保底
+	Pity           int                   `json:"pity"`            // 打造后的保底计数
+}
+
+// ForgeService 装备打造：消耗3个宝物和金币，随机一个宝物的等级作为装备品级
+type ForgeService struct {
+	DB *gorm.DB
+}
+
+func NewForgeService(db *gorm.DB) *ForgeService {
+	return &ForgeService{DB: db}
+}
+
+// Preview 按选择的宝物计算打造的完整概率，与 Forge 使用同一套配置和抽取逻辑
+func (s *ForgeService) Preview(userID uint, itemIDs []uint) (*ForgePreview, error) {
+	if len(itemIDs) != forgeTreasureCount {
+		return nil, ErrForgeTreasureCount
+	}
+
+	registry, err := GetAffixRegistry(s.DB)
+	if err != nil {
+		return nil, err
+	}
+
+	var user models.User
+	if err := s.DB.Select("id", "forge_pity").First(&user, userID).Error; err != nil {
+		return nil, err
+	}
+
+	treasures, err := lookupTreasureItems(s.DB, userID, itemIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	preview := &ForgePreview{
+		CostGold:       registry.Rules.ForgeGoldCost,
+		Treasures:      treasures,
+		Pity:           user.ForgePity,
+		PityThreshold:  registry.Rules.ForgeRarePity,
+		RareGuaranteed: registry.ForgeRareGuaranteed(user.ForgePity),
+		RulesetVersion: registry.Version,
+		Levels:         []ForgeLevelOdds{},
+	}
+
+	templatesByLevel, candidates, err := forgeCandidates(s.DB, treasures)
+	if err != nil {
+		return nil, err
+	}
+
+	// 只有能打造出装备的宝物参与抽取，概率按参与抽取的宝物数归一
+	levelHits := make(map[int]int)
+	var levels []int
+	for _, index := range candidates {
+		level := treasures[index].Level
+		if levelHits[level] == 0 {
+			levels = append(levels, level)
+		}
+		levelHits[level]++
+	}
+	sort.Ints(levels)
+	for _, level := range levels {
+		templates := templatesByLevel[level]
+		odds := ForgeLevelOdds{
+			Level:     level,
+			Chance:    float64(levelHits[level]) / float64(len(candidates)),
+			Templates: make([]ForgeTemplateOdds, 0, len(templates)),
+		}
+		for _, tpl := range templates {
+			odds.Templates = append(odds.Templates, ForgeTemplateOdds{
+				ID:     tpl.ID,
+				Name:   tpl.Name,
+				Slot:   tpl.Slot,
+				Chance: 1 / float64(len(templates)),
+			})
+		}
+		odds.Affixes, odds.NoAffixChance = registry.ForgeAffixOdds(level, preview.RareGuaranteed)
+		if limit := registry.Rules.HiddenAffixMax[level]; limit > 0 {
+			odds.HiddenAffixChance = registry.Rules.HiddenAffixChance
+			odds.HiddenAffixMax = limit
+		}
+		preview.Levels = append(preview.Levels, odds)
+	}
+	return preview, nil
+}
+
+// Forge 打造装备：扣除宝物和金币，随机品级、模板、词条和隐藏词条，并更新稀有词条保底计数；背包已满时返回 ErrInventoryFull
+func (s *ForgeService) Forge(userID uint, itemIDs []uint) (*ForgeResult, error) {
+	if len(itemIDs) != forgeTreasureCount {
+		return nil, ErrForgeTreasureCount
+	}
+
+	registry, err := GetAffixRegistry(s.DB)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ForgeResult{CostGold: registry.Rules.ForgeGoldCost}
+	var equipment models.UserEquipment
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		var user models.User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("id", "gold", "forge_pity").First(&user, userID).Error; err != nil {
+			return err
+		}
+
+		treasures, err := consumeTreasureItems(tx, userID, itemIDs)
+		if err != nil {
+			return err
+		}
+		result.Treasures = treasures
+
+		templatesByLevel, candidates, err := forgeCandidates(tx, treasures)
+		if err != nil {
+			return err
+		}
+
+		rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+		result.SelectedIndex = candidates[rng.Intn(len(candidates))]
+		result.Level = treasures[result.SelectedIndex].Level
+		templates := templatesByLevel[result.Level]
+		template := templates[rng.Intn(len(templates))]
+
+		// 消耗的宝物可能腾出格子，扣除后再检查背包空间
+		free, err := FreeSlots(tx, userID, models.PositionBackpack)
+		if err != nil {
+			return err
+		}
+		if free <= 0 {
+			return fmt.Errorf("%w：背包", ErrInventoryFull)
+		}
+		equipment = models.UserEquipment{
+			UserID:        userID,
+			EquipmentID:   template.ID,
+			Position:      models.PositionBackpack,
+			HiddenAffixes: registry.RollHiddenAffixes(rng, result.Level),
+		}
+		if err := tx.Create(&equipment).Error; err != nil {
+			return err
+		}
+
+		result.CurrentGold = user.Gold
+		if result.CostGold > 0 {
+			goldTx, err := ChangeCurrency(tx, CurrencyChange{
+				UserID:     userID,
+				Currency:   models.CurrencyGold,
+				Delta:      -result.CostGold,
+				Reason:     models.CurrencyReasonEquipmentForge,
+				SourceType: "user_equipment",
+				SourceID:   equipment.ID,
+			})
+			if err != nil {
+				return err
+			}
+			result.CurrentGold = goldTx.BalanceAfter
+		}
+
+		result.RareGuaranteed = registry.ForgeRareGuaranteed(user.ForgePity)
+		attr := registry.RollForgeAffix(rng, result.Level, result.RareGuaranteed)
+		if attr != nil {
+			attr.UserEquipmentID = equipment.ID
+			if err := tx.Create(attr).Error; err != nil {
+				return err
+			}
+		}
+
+		result.Pity = user.ForgePity + 1
+		if attr != nil && registry.IsRare(attr.AttrType) {
+			result.Pity = 0
+		}
+		return tx.Model(&models.User{}).Where("id = ?", userID).Update("forge_pity", result.Pity).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.DB.Preload("EquipmentTemplate").Preload("AdditionalAttrs").First(&equipment, equipment.ID).Error; err != nil {
+		return nil, err
+	}
+	result.Equipment = &equipment
+	return result, nil
+}
+
+// forgeTemplates 某个品级可打造的装备模板，按ID排序（每个模板被选中的概率相同）
+func forgeTemplates(db *gorm.DB, level int) ([]models.EquipmentTemplate, error) {
+	var templates []models.EquipmentTemplate
+	err := db.Where("level = ? AND is_active = ?", level, true).Order("id").Find(&templates).Error
+	return templates, err
+}
+
+// forgeCandidates 查询各宝物等级可打造的装备模板，返回可被选为基准的宝物下标；
+// 等级没有可用模板的宝物不参与抽取，全部宝物都没有可用模板时返回 ErrForgeTemplateMissing
+func forgeCandidates(db *gorm.DB, treasures []models.Treasure) (map[int][]models.EquipmentTemplate, []int, error) {
+	templatesByLevel := make(map[int][]models.EquipmentTemplate)
+	var candidates []int
+	for i, treasure := range treasures {
+		templates, ok := templatesByLevel[treasure.Level]
+		if !ok {
+			var err error
+			templates, err = forgeTemplates(db, treasure.Level)
+			if err != nil {
+				return nil, nil, err
+			}
+			templatesByLevel[treasure.Level] = templates
+		}
+		if len(templates) > 0 {
+			candidates = append(candidates, i)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil, nil, ErrForgeTemplateMissing
+	}
+	return templatesByLevel, candidates, nil
+}
+
+// lookupTreasureItems 查询背包宝物记录对应的宝物（不扣减），同一记录重复选择时校验数量是否足够
+func lookupTreasureItems(db *gorm.DB, userID uint, itemIDs []uint) ([]models.Treasure, error) {
+	counts := make(map[uint]int, len(itemIDs))
+	for _, id := range itemIDs {
+		counts[id]++
+	}
+
+	treasureByItem := make(map[uint]models.Treasure, len(counts))
+	for id, num := range counts {
+		var myItem models.MyItem
+		if err := db.Where("id = ? AND user_id = ? AND item_type = ?", id, userID, models.MyItemTypeTreasure).
+			First(&myItem).Error; err != nil || myItem.Quantity < num {
+			return nil, ErrTreasureNotEnough
+		}
+		var treasure models.Treasure
+		if err := db.First(&treasure, myItem.ItemID).Error; err != nil {
+			return nil, ErrTreasureNotEnough
+		}
+		treasureByItem[id] = treasure
+	}
+
+	treasures := make([]models.Treasure, 0, len(itemIDs))
+	for _, id := range itemIDs {
+		treasures = append(treasures, treasureByItem[id])
+	}
+	return treasures, nil
+}
@@ -0,0 +1,165 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"ggo/models"
+	"ggo/services/stats"
+	"math/rand"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 鉴定消耗的金币，按装备品级；也可以改用1个等级不低于装备品级的宝物代替
+var identifyGoldCosts = map[int]int{
+	1: 2000,
+	2: 4000,
+	3: 8000,
+	4: 15000,
+	5: 25000,
+	6: 40000,
+}
+
+var (
+	ErrIdentifyNothing   = errors.New("该装备没有未鉴定的词条")
+	ErrIdentifyAffixFull = errors.New("装备词条已达上限，无法继续鉴定")
+	ErrIdentifyMaterial  = errors.New("鉴定材料无效")
+)
+
+// IdentifyRequest 鉴定请求
+type IdentifyRequest struct {
+	ItemID uint `json:"item_id"` // 用宝物代替金币时的背包宝物记录ID，宝物等级不低于装备品级；为0时消耗金币
+}
+
+// IdentifyResult 鉴定结果
+type IdentifyResult struct {
+	Revealed      bool                            `json:"revealed"`       // 是否揭示出词条
+	Chance        float64                         `json:"chance"`         // 本次揭示概率
+	NextChance    float64                         `json:"next_chance"`    // 下次鉴定的揭示概率
+	Affix         *models.EquipmentAdditionalAttr `json:"affix"`          // 揭示出的词条
+	CostGold      int                             `json:"cost_gold"`      // 消耗金币
+	UsedTreasure  *models.Treasure                `json:"used_treasure"`  // 代替金币消耗的宝物
+	CurrentGold   int                             `json:"current_gold"`   // 鉴定后金币余额
+	HiddenAffixes int                             `json:"hidden_affixes"` // 剩余未鉴定词条数
+	IdentifyCount int                             `json:"identify_count"` // 已鉴定次数
+	Equipment     *models.UserEquipment           `json:"equipment"`
+}
+
+// IdentifyService 装备鉴定：逐条揭示打造或掉落时生成的隐藏词条
+type IdentifyService struct {
+	DB *gorm.DB
+}
+
+func NewIdentifyService(db *gorm.DB) *IdentifyService {
+	return &IdentifyService{DB: db}
+}
+
+// Identify 鉴定一次：消耗金币或宝物和一个隐藏词条，按概率揭示出一条词条；
+// 揭示概率随该装备的鉴定次数递减，未揭示时该隐藏词条作废
+func (s *IdentifyService) Identify(userID, equipmentID uint, req IdentifyRequest) (*IdentifyResult, error) {
+	registry, err := GetAffixRegistry(s.DB)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &IdentifyResult{}
+	var equipment models.UserEquipment
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND user_id = ?", equipmentID, userID).First(&equipment).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrEquipmentNotFound
+			}
+			return err
+		}
+		if equipment.HiddenAffixes <= 0 {
+			return ErrIdentifyNothing
+		}
+		if err := tx.First(&equipment.EquipmentTemplate, equipment.EquipmentID).Error; err != nil {
+			return err
+		}
+		var affixCount int64
+		if err := tx.Model(&models.EquipmentAdditionalAttr{}).
+			Where("user_equipment_id = ?", equipment.ID).Count(&affixCount).Error; err != nil {
+			return err
+		}
+		if int(affixCount) >= registry.Rules.MaxAffixes {
+			return ErrIdentifyAffixFull
+		}
+
+		rarity := equipment.EquipmentTemplate.Level
+		if req.ItemID != 0 {
+			treasures, err := consumeTreasureItems(tx, userID, []uint{req.ItemID})
+			if errors.Is(err, ErrTreasureNotEnough) {
+				return fmt.Errorf("%w：%v", ErrIdentifyMaterial, err)
+			}
+			if err != nil {
+				return err
+			}
+			if treasures[0].Level < rarity {
+				return fmt.Errorf("%w：宝物等级不能低于装备品级", ErrIdentifyMaterial)
+			}
+			result.UsedTreasure = &treasures[0]
+		} else {
+			result.CostGold = identifyGoldCosts[rarity]
+		}
+		if result.CostGold > 0 {
+			goldTx, err := ChangeCurrency(tx, CurrencyChange{
+				UserID:     userID,
+				Currency:   models.CurrencyGold,
+				Delta:      -result.CostGold,
+				Reason:     models.CurrencyReasonEquipmentIdentify,
+				SourceType: "user_equipment",
+				SourceID:   equipment.ID,
+			})
+			if err != nil {
+				return err
+			}
+			result.CurrentGold = goldTx.BalanceAfter
+		} else {
+			var user models.User
+			if err := tx.Select("gold").First(&user, userID).Error; err != nil {
+				return err
+			}
+			result.CurrentGold = user.Gold
+		}
+
+		rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+		result.Chance = registry.IdentifyChance(equipment.IdentifyCount)
+		if rng.Float64() < result.Chance {
+			if attr := registry.RollIdentifyAffix(rng, rarity); attr != nil {
+				attr.UserEquipmentID = equipment.ID
+				if err := tx.Create(attr).Error; err != nil {
+					return err
+				}
+				result.Revealed = true
+				result.Affix = attr
+			}
+		}
+
+		equipment.HiddenAffixes--
+		equipment.IdentifyCount++
+		return tx.Model(&models.UserEquipment{}).Where("id = ?", equipment.ID).Updates(map[string]interface{}{
+			"hidden_affixes": equipment.HiddenAffixes,
+			"identify_count": equipment.IdentifyCount,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if result.Revealed && equipment.IsEquipped {
+		// 战力为派生数据，刷新失败不影响本次鉴定
+		stats.Refresh(s.DB, userID)
+	}
+	if err := s.DB.Preload("EquipmentTemplate").Preload("AdditionalAttrs").First(&equipment, equipment.ID).Error; err != nil {
+		return nil, err
+	}
+	result.HiddenAffixes = equipment.HiddenAffixes
+	result.IdentifyCount = equipment.IdentifyCount
+	result.NextChance = registry.IdentifyChance(equipment.IdentifyCount)
+	result.Equipment = &equipment
+	return result, nil
+}
@@ -0,0 +1,415 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"ggo/models"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 背包/仓库扩充：每次增加的格数、消耗的钻石和容量上限
+const (
+	InventoryExpandSlots   = 10
+	InventoryExpandDiamond = 100
+	InventoryMaxCapacity   = 500
+	maxInventoryMoves      = 100 // 单次批量移动的数量上限
+)
+
+var (
+	ErrMyItemNotEnough      = errors.New("物品不存在或数量不足")
+	ErrInventoryStackFull   = errors.New("物品数量超过堆叠上限")
+	ErrInventoryFull        = errors.New("空间已满")
+	ErrInventoryPosition    = errors.New("无效的位置，只支持 backpack, warehouse")
+	ErrInventoryMaxCapacity = fmt.Errorf("容量已达上限%d格", InventoryMaxCapacity)
+	ErrInventoryMoveInvalid = errors.New("移动参数无效")
+	ErrInventoryMoveTooMany = fmt.Errorf("单次最多移动%d个物品", maxInventoryMoves)
+)
+
+// InventorySpace 背包或仓库的容量和已用格数
+type InventorySpace struct {
+	Position string `json:"position"`
+	Capacity int    `json:"capacity"`
+	Used     int    `json:"used"`
+}
+
+// InventoryMove 一次移动：物品堆叠（可只移动部分数量）或一件未穿戴的装备
+type InventoryMove struct {
+	Kind     string `json:"kind" binding:"required"` // item: 背包物品（ID为我的物品ID）, equipment: 装备（ID为玩家装备ID）
+	ID       uint   `json:"id" binding:"required"`
+	To       string `json:"to" binding:"required"` // 目标位置：backpack, warehouse
+	Quantity int    `json:"quantity"`              // 物品移动的数量，0 表示整个堆叠
+}
+
+// InventoryMoveResult 移动结果，物品移动后返回目标位置的堆叠
+type InventoryMoveResult struct {
+	Kind      string                `json:"kind"`
+	ID        uint                  `json:"id"`
+	To        string                `json:"to"`
+	Item      *models.MyItem        `json:"item,omitempty"`
+	Equipment *models.UserEquipment `json:"equipment,omitempty"`
+}
+
+// InventoryService 背包和仓库：容量、扩充和物品移动
+type InventoryService struct {
+	DB *gorm.DB
+}
+
+func NewInventoryService(db *gorm.DB) *InventoryService {
+	return &InventoryService{DB: db}
+}
+
+// 背包物品的堆叠键，与 my_items 的唯一索引 idx_my_item_stack 一致
+var myItemStackColumns = []clause.Column{{Name: "user_id"}, {Name: "item_type"}, {Name: "item_id"}, {Name: "position"}}
+
+// GrantMyItem 发放可堆叠物品（宝物、消耗品）到背包
+func GrantMyItem(tx *gorm.DB, userID uint, itemType string, itemID uint, num int) error {
+	_, err := AddMyItem(tx, userID, itemType, itemID, models.PositionBackpack, num)
+	return err
+}
+
+// AddMyItem 把物品加入指定位置的堆叠，没有时创建（占用一格）；位置已满时返回 ErrInventoryFull，
+// 超过该物品的堆叠上限时返回 ErrInventoryStackFull（调用方回滚事务）。
+// 所有增加背包物品的地方都应调用此函数，不要直接插入 my_items
+func AddMyItem(tx *gorm.DB, userID uint, itemType string, itemID uint, position string, num int) (*models.MyItem, error) {
+	if num <= 0 {
+		return nil, fmt.Errorf("物品数量无效: %d", num)
+	}
+	room, limit, hasStack, err := MyItemRoom(tx, userID, itemType, itemID, position)
+	if err != nil {
+		return nil, err
+	}
+	if num > room {
+		if !hasStack && room == 0 {
+			return nil, fmt.Errorf("%w：%s", ErrInventoryFull, positionName(position))
+		}
+		return nil, fmt.Errorf("%w（上限%d）", ErrInventoryStackFull, limit)
+	}
+
+	item := models.MyItem{
+		UserID:   userID,
+		ItemID:   itemID,
+		ItemType: itemType,
+		Position: position,
+		Quantity: num,
+		IsActive: true,
+	}
+	if err := tx.Clauses(clause.OnConflict{
+		Columns: myItemStackColumns,
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"quantity":   gorm.Expr("my_items.quantity + ?", num),
+			"updated_at": time.Now().Unix(),
+		}),
+	}).Create(&item).Error; err != nil {
+		return nil, err
+	}
+
+	if err := tx.Where("user_id = ? AND item_type = ? AND item_id = ? AND position = ?", userID, itemType, itemID, position).
+		First(&item).Error; err != nil {
+		return nil, err
+	}
+	if item.Quantity > limit {
+		return nil, fmt.Errorf("%w（上限%d）", ErrInventoryStackFull, limit)
+	}
+	return &item, nil
+}
+
+// MyItemRoom 某个位置还能放入多少个该物品：已有堆叠时为堆叠剩余数量，没有堆叠时需要一个空格；
+// 同时返回堆叠上限和是否已有堆叠
+func MyItemRoom(tx *gorm.DB, userID uint, itemType string, itemID uint, position string) (room, limit int, hasStack bool, err error) {
+	limit, err = MaxStack(tx, itemType, itemID)
+	if err != nil {
+		return 0, 0, false, err
+	}
+
+	var stack models.MyItem
+	err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("user_id = ? AND item_type = ? AND item_id = ? AND position = ?", userID, itemType, itemID, position).
+		First(&stack).Error
+	if err == nil {
+		if stack.Quantity >= limit {
+			return 0, limit, true, nil
+		}
+		return limit - stack.Quantity, limit, true, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, 0, false, err
+	}
+
+	free, err := FreeSlots(tx, userID, position)
+	if err != nil {
+		return 0, 0, false, err
+	}
+	if free <= 0 {
+		return 0, limit, false, nil
+	}
+	return limit, limit, false, nil
+}
+
+// FreeSlots 背包或仓库的剩余格数。会锁定用户记录，使同一用户的容量检查串行执行
+func FreeSlots(tx *gorm.DB, userID uint, position string) (int, error) {
+	var user models.User
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Select("id", "backpack_capacity", "warehouse_capacity").First(&user, userID).Error; err != nil {
+		return 0, err
+	}
+	space, err := inventorySpace(tx, &user, position)
+	if err != nil {
+		return 0, err
+	}
+	return space.Capacity - space.Used, nil
+}
+
+// inventorySpace 统计某个位置的容量和已用格数：每个物品堆叠和每件未穿戴的装备各占一格
+func inventorySpace(tx *gorm.DB, user *models.User, position string) (InventorySpace, error) {
+	space := InventorySpace{Position: position}
+	switch position {
+	case models.PositionBackpack:
+		space.Capacity = user.BackpackCapacity
+	case models.PositionWarehouse:
+		space.Capacity = user.WarehouseCapacity
+	default:
+		return space, ErrInventoryPosition
+	}
+
+	var items, equipments int64
+	if err := tx.Model(&models.MyItem{}).Where("user_id = ? AND position = ?", user.ID, position).Count(&items).Error; err != nil {
+		return space, err
+	}
+	if err := tx.Model(&models.UserEquipment{}).Where("user_id = ? AND position = ? AND is_equipped = ?", user.ID, position, false).
+		Count(&equipments).Error; err != nil {
+		return space, err
+	}
+	space.Used = int(items + equipments)
+	return space, nil
+}
+
+func positionName(position string) string {
+	if position == models.PositionWarehouse {
+		return "仓库"
+	}
+	return "背包"
+}
+
+// Spaces 背包和仓库的容量与已用格数
+func (s *InventoryService) Spaces(userID uint) ([]InventorySpace, error) {
+	var user models.User
+	if err := s.DB.Select("id", "backpack_capacity", "warehouse_capacity").First(&user, userID).Error; err != nil {
+		return nil, err
+	}
+	spaces := make([]InventorySpace, 0, 2)
+	for _, position := range []string{models.PositionBackpack, models.PositionWarehouse} {
+		space, err := inventorySpace(s.DB, &user, position)
+		if err != nil {
+			return nil, err
+		}
+		spaces = append(spaces, space)
+	}
+	return spaces, nil
+}
+
+// Expand 消耗钻石扩充背包或仓库，每次增加固定格数，不超过容量上限
+func (s *InventoryService) Expand(userID uint, position string) (*InventorySpace, int, error) {
+	column := ""
+	switch position {
+	case models.PositionBackpack:
+		column = "backpack_capacity"
+	case models.PositionWarehouse:
+		column = "warehouse_capacity"
+	default:
+		return nil, 0, ErrInventoryPosition
+	}
+
+	var space InventorySpace
+	currentDiamond := 0
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		var user models.User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("id", "backpack_capacity", "warehouse_capacity").First(&user, userID).Error; err != nil {
+			return err
+		}
+		current, err := inventorySpace(tx, &user, position)
+		if err != nil {
+			return err
+		}
+		if current.Capacity+InventoryExpandSlots > InventoryMaxCapacity {
+			return ErrInventoryMaxCapacity
+		}
+
+		diamondTx, err := ChangeCurrency(tx, CurrencyChange{
+			UserID:     userID,
+			Currency:   models.CurrencyDiamond,
+			Delta:      -InventoryExpandDiamond,
+			Reason:     models.CurrencyReasonInventoryExpand,
+			SourceType: "user",
+			SourceID:   userID,
+		})
+		if err != nil {
+			return err
+		}
+		currentDiamond = diamondTx.BalanceAfter
+
+		if err := tx.Model(&models.User{}).Where("id = ?", userID).
+			Update(column, gorm.Expr(column+" + ?", InventoryExpandSlots)).Error; err != nil {
+			return err
+		}
+		space = current
+		space.Capacity += InventoryExpandSlots
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return &space, currentDiamond, nil
+}
+
+// Move 在背包和仓库之间移动物品或装备，多个移动在同一事务中执行，任意一个失败时全部回滚
+func (s *InventoryService) Move(userID uint, moves []InventoryMove) ([]InventoryMoveResult, error) {
+	if len(moves) == 0 {
+		return nil, ErrInventoryMoveInvalid
+	}
+	if len(moves) > maxInventoryMoves {
+		return nil, ErrInventoryMoveTooMany
+	}
+
+	results := make([]InventoryMoveResult, 0, len(moves))
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		for _, move := range moves {
+			if move.To != models.PositionBackpack && move.To != models.PositionWarehouse {
+				return ErrInventoryPosition
+			}
+			result := InventoryMoveResult{Kind: move.Kind, ID: move.ID, To: move.To}
+			switch move.Kind {
+			case "item":
+				item, err := moveMyItem(tx, userID, move)
+				if err != nil {
+					return err
+				}
+				result.Item = item
+			case "equipment":
+				equipment, err := moveEquipment(tx, userID, move)
+				if err != nil {
+					return err
+				}
+				result.Equipment = equipment
+			default:
+				return fmt.Errorf("%w：kind 只支持 item, equipment", ErrInventoryMoveInvalid)
+			}
+			results = append(results, result)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// moveMyItem 把物品堆叠（或其中一部分）移到目标位置，与目标位置已有的堆叠合并
+func moveMyItem(tx *gorm.DB, userID uint, move InventoryMove) (*models.MyItem, error) {
+	var source models.MyItem
+	if err := tx.Where("id = ? AND user_id = ?", move.ID, userID).First(&source).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrMyItemNotEnough
+		}
+		return nil, err
+	}
+	if source.Position == move.To {
+		return nil, fmt.Errorf("%w：物品已在%s中", ErrInventoryMoveInvalid, positionName(move.To))
+	}
+	num := move.Quantity
+	if num <= 0 {
+		num = source.Quantity
+	}
+
+	if _, err := TakeMyItem(tx, userID, source.ItemType, source.ID, num); err != nil {
+		return nil, err
+	}
+	return AddMyItem(tx, userID, source.ItemType, source.ItemID, move.To, num)
+}
+
+// moveEquipment 把一件未穿戴的装备移到目标位置
+func moveEquipment(tx *gorm.DB, userID uint, move InventoryMove) (*models.UserEquipment, error) {
+	var equipment models.UserEquipment
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id = ? AND user_id = ?", move.ID, userID).First(&equipment).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrEquipmentNotFound
+		}
+		return nil, err
+	}
+	if equipment.IsEquipped {
+		return nil, ErrEquipmentEquipped
+	}
+	if equipment.Position == move.To {
+		return nil, fmt.Errorf("%w：装备已在%s中", ErrInventoryMoveInvalid, positionName(move.To))
+	}
+
+	free, err := FreeSlots(tx, userID, move.To)
+	if err != nil {
+		return nil, err
+	}
+	if free <= 0 {
+		return nil, fmt.Errorf("%w：%s", ErrInventoryFull, positionName(move.To))
+	}
+	if err := tx.Model(&equipment).Update("position", move.To).Error; err != nil {
+		return nil, err
+	}
+	return &equipment, nil
+}
+
+// TakeMyItem 锁定并扣减背包物品数量，数量为0时删除记录；返回扣减前的记录。
+// 所有消耗背包物品的地方都应调用此函数
+func TakeMyItem(tx *gorm.DB, userID uint, itemType string, myItemID uint, num int) (*models.MyItem, error) {
+	var myItem models.MyItem
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id = ? AND user_id = ? AND item_type = ?", myItemID, userID, itemType).First(&myItem).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrMyItemNotEnough
+		}
+		return nil, err
+	}
+	if num <= 0 || myItem.Quantity < num {
+		return nil, ErrMyItemNotEnough
+	}
+
+	if myItem.Quantity == num {
+		if err := tx.Delete(&models.MyItem{}, myItem.ID).Error; err != nil {
+			return nil, err
+		}
+	} else if err := tx.Model(&models.MyItem{}).Where("id = ?", myItem.ID).
+		Update("quantity", gorm.Expr("quantity - ?", num)).Error; err != nil {
+		return nil, err
+	}
+	return &myItem, nil
+}
+
+// MaxStack 物品的堆叠上限，未配置时使用默认值
+func MaxStack(db *gorm.DB, itemType string, itemID uint) (int, error) {
+	var limit int
+	var err error
+	switch itemType {
+	case models.MyItemTypeTreasure:
+		var treasure models.Treasure
+		err = db.Select("id", "max_stack").First(&treasure, itemID).Error
+		limit = treasure.MaxStack
+	case models.MyItemTypeConsumable:
+		var consumable models.Consumable
+		err = db.Select("id", "max_stack").First(&consumable, itemID).Error
+		limit = consumable.MaxStack
+	default:
+		return 0, fmt.Errorf("未知物品类型: %s", itemType)
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, fmt.Errorf("物品不存在: %s %d", itemType, itemID)
+	}
+	if err != nil {
+		return 0, err
+	}
+	if limit <= 0 {
+		limit = models.DefaultMaxStack
+	}
+	return limit, nil
+}
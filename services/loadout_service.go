@@ -0,0 +1,390 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"ggo/models"
+	"ggo/services/stats"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 每个玩家最多保存的装备方案数量
+const maxLoadouts = 10
+
+var (
+	ErrLoadoutNotFound  = errors.New("装备方案不存在")
+	ErrLoadoutInvalid   = errors.New("装备方案无效")
+	ErrLoadoutNameTaken = errors.New("装备方案名称已存在")
+	ErrLoadoutTooMany   = fmt.Errorf("最多保存%d个装备方案", maxLoadouts)
+)
+
+// LoadoutRequest 保存装备方案的请求；use_current 为 true 时保存当前穿戴的装备和启用的皮肤
+type LoadoutRequest struct {
+	Name         string `json:"name" binding:"required,max=20"`
+	EquipmentIDs []uint `json:"equipment_ids"` // 玩家装备ID，每个部位最多一件
+	SkinID       uint   `json:"skin_id"`       // 皮肤ID，0表示切换时不改变皮肤
+	UseCurrent   bool   `json:"use_current"`   // 保存当前穿戴
+}
+
+// LoadoutSlot 装备方案中某个部位的状态
+type LoadoutSlot struct {
+	Slot            string                `json:"slot"`
+	UserEquipmentID uint                  `json:"user_equipment_id"`
+	Missing         bool                  `json:"missing"`             // 装备已被回收、融合或不再属于该玩家
+	Equipment       *models.UserEquipment `json:"equipment,omitempty"` // 装备详情，失效时为空
+}
+
+// LoadoutView 装备方案及各部位装备的当前状态
+type LoadoutView struct {
+	ID          uint          `json:"id"`
+	Name        string        `json:"name"`
+	SkinID      uint          `json:"skin_id"`
+	SkinMissing bool          `json:"skin_missing"` // 皮肤已不属于该玩家
+	Slots       []LoadoutSlot `json:"slots"`
+	UpdatedAt   int64         `json:"updated_at"`
+}
+
+// LoadoutApplyResult 切换装备方案的结果
+type LoadoutApplyResult struct {
+	Loadout     LoadoutView   `json:"loadout"`
+	Equipped    []uint        `json:"equipped"`     // 穿戴上的装备ID
+	Missing     []LoadoutSlot `json:"missing"`      // 失效的部位，保持原有穿戴
+	SkinMissing bool          `json:"skin_missing"` // 皮肤已失效，未切换皮肤
+}
+
+// LoadoutService 装备方案
+type LoadoutService struct {
+	DB *gorm.DB
+}
+
+func NewLoadoutService(db *gorm.DB) *LoadoutService {
+	return &LoadoutService{DB: db}
+}
+
+// List 获取玩家的全部装备方案，并标记已失效的装备和皮肤
+func (s *LoadoutService) List(userID uint) ([]LoadoutView, error) {
+	var loadouts []models.Loadout
+	if err := s.DB.Where("user_id = ?", userID).Preload("Items").Order("id").Find(&loadouts).Error; err != nil {
+		return nil, err
+	}
+
+	views := make([]LoadoutView, 0, len(loadouts))
+	for i := range loadouts {
+		view, err := loadoutView(s.DB, userID, &loadouts[i])
+		if err != nil {
+			return nil, err
+		}
+		views = append(views, view)
+	}
+	return views, nil
+}
+
+// Save 创建（loadoutID 为0）或覆盖装备方案
+func (s *LoadoutService) Save(userID, loadoutID uint, req LoadoutRequest) (*LoadoutView, error) {
+	var view LoadoutView
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		var loadout models.Loadout
+		if loadoutID != 0 {
+			if err := tx.Where("id = ? AND user_id = ?", loadoutID, userID).First(&loadout).Error; err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return ErrLoadoutNotFound
+				}
+				return err
+			}
+		} else {
+			var count int64
+			if err := tx.Model(&models.Loadout{}).Where("user_id = ?", userID).Count(&count).Error; err != nil {
+				return err
+			}
+			if count >= maxLoadouts {
+				return ErrLoadoutTooMany
+			}
+		}
+
+		var taken int64
+		if err := tx.Model(&models.Loadout{}).Where("user_id = ? AND name = ? AND id <> ?", userID, req.Name, loadoutID).
+			Count(&taken).Error; err != nil {
+			return err
+		}
+		if taken > 0 {
+			return ErrLoadoutNameTaken
+		}
+
+		items, skinID, err := loadoutContents(tx, userID, req)
+		if err != nil {
+			return err
+		}
+
+		loadout.UserID = userID
+		loadout.Name = req.Name
+		loadout.SkinID = skinID
+		if loadout.ID == 0 {
+			if err := tx.Create(&loadout).Error; err != nil {
+				return err
+			}
+		} else {
+			if err := tx.Model(&loadout).Updates(map[string]interface{}{"name": loadout.Name, "skin_id": loadout.SkinID}).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("loadout_id = ?", loadout.ID).Delete(&models.LoadoutItem{}).Error; err != nil {
+				return err
+			}
+		}
+		for i := range items {
+			items[i].LoadoutID = loadout.ID
+		}
+		if len(items) > 0 {
+			if err := tx.Create(&items).Error; err != nil {
+				return err
+			}
+		}
+		loadout.Items = items
+
+		view, err = loadoutView(tx, userID, &loadout)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &view, nil
+}
+
+// loadoutContents 根据请求确定方案中的装备（每个部位一件）和皮肤
+func loadoutContents(tx *gorm.DB, userID uint, req LoadoutRequest) ([]models.LoadoutItem, uint, error) {
+	var equipments []models.UserEquipment
+	skinID := req.SkinID
+	if req.UseCurrent {
+		if err := tx.Preload("EquipmentTemplate").Where("user_id = ? AND is_equipped = ?", userID, true).
+			Order("id").Find(&equipments).Error; err != nil {
+			return nil, 0, err
+		}
+		var active models.UserSkin
+		err := tx.Where("user_id = ? AND is_active = ?", userID, true).First(&active).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, 0, err
+		}
+		skinID = active.SkinID
+	} else {
+		ids := uniqueIDs(req.EquipmentIDs)
+		if len(ids) > 0 {
+			if err := tx.Preload("EquipmentTemplate").Where("id IN ? AND user_id = ?", ids, userID).
+				Order("id").Find(&equipments).Error; err != nil {
+				return nil, 0, err
+			}
+			if len(equipments) != len(ids) {
+				return nil, 0, ErrEquipmentNotFound
+			}
+		}
+		if skinID != 0 {
+			var count int64
+			if err := tx.Model(&models.UserSkin{}).Where("user_id = ? AND skin_id = ?", userID, skinID).Count(&count).Error; err != nil {
+				return nil, 0, err
+			}
+			if count == 0 {
+				return nil, 0, fmt.Errorf("%w：皮肤不属于该用户", ErrLoadoutInvalid)
+			}
+		}
+	}
+
+	items := make([]models.LoadoutItem, 0, len(equipments))
+	slots := make(map[string]bool, len(equipments))
+	for _, eq := range equipments {
+		slot := eq.EquipmentTemplate.Slot
+		if slots[slot] {
+			return nil, 0, fmt.Errorf("%w：部位 %s 只能选择一件装备", ErrLoadoutInvalid, slot)
+		}
+		slots[slot] = true
+		items = append(items, models.LoadoutItem{Slot: slot, UserEquipmentID: eq.ID})
+	}
+	return items, skinID, nil
+}
+
+// Delete 删除装备方案
+func (s *LoadoutService) Delete(userID, loadoutID uint) error {
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("id = ? AND user_id = ?", loadoutID, userID).Delete(&models.Loadout{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrLoadoutNotFound
+		}
+		return tx.Where("loadout_id = ?", loadoutID).Delete(&models.LoadoutItem{}).Error
+	})
+}
+
+// Apply 在一个事务中整套切换：卸下当前穿戴的装备，穿上方案中的装备并切换皮肤
+// 方案中已失效的装备（被回收、融合等）会在结果中列出，对应部位保持原有穿戴；卸下的装备背包放不下时返回 ErrInventoryFull
+func (s *LoadoutService) Apply(userID, loadoutID uint) (*LoadoutApplyResult, error) {
+	result := &LoadoutApplyResult{Equipped: []uint{}, Missing: []LoadoutSlot{}}
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		var loadout models.Loadout
+		if err := tx.Where("id = ? AND user_id = ?", loadoutID, userID).Preload("Items").First(&loadout).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrLoadoutNotFound
+			}
+			return err
+		}
+
+		var equipments []models.UserEquipment
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Preload("EquipmentTemplate").
+			Where("user_id = ?", userID).Order("id").Find(&equipments).Error; err != nil {
+			return err
+		}
+		byID := make(map[uint]*models.UserEquipment, len(equipments))
+		for i := range equipments {
+			byID[equipments[i].ID] = &equipments[i]
+		}
+
+		// 方案中有效装备占用的部位要换装，失效部位保持原有穿戴
+		wanted := make(map[uint]bool, len(loadout.Items))
+		replacedSlots := make(map[string]bool, len(loadout.Items))
+		for _, item := range loadout.Items {
+			eq, ok := byID[item.UserEquipmentID]
+			if !ok || eq.EquipmentTemplate.Slot != item.Slot {
+				result.Missing = append(result.Missing, LoadoutSlot{Slot: item.Slot, UserEquipmentID: item.UserEquipmentID, Missing: true})
+				continue
+			}
+			wanted[eq.ID] = true
+			replacedSlots[item.Slot] = true
+			result.Equipped = append(result.Equipped, eq.ID)
+		}
+		for _, slot := range loadoutEmptySlots(loadout.Items) {
+			replacedSlots[slot] = true
+		}
+
+		var unequip, equip []uint
+		fromBackpack := 0
+		for _, eq := range equipments {
+			switch {
+			case wanted[eq.ID] && !eq.IsEquipped:
+				equip = append(equip, eq.ID)
+				if eq.Position == models.PositionBackpack {
+					fromBackpack++
+				}
+			case !wanted[eq.ID] && eq.IsEquipped && replacedSlots[eq.EquipmentTemplate.Slot]:
+				unequip = append(unequip, eq.ID)
+			}
+		}
+		// 卸下的装备放回背包；从背包穿上的装备腾出的格子可以抵扣
+		if needed := len(unequip) - fromBackpack; needed > 0 {
+			free, err := FreeSlots(tx, userID, models.PositionBackpack)
+			if err != nil {
+				return err
+			}
+			if free < needed {
+				return fmt.Errorf("%w：背包", ErrInventoryFull)
+			}
+		}
+		if len(unequip) > 0 {
+			if err := tx.Model(&models.UserEquipment{}).Where("id IN ?", unequip).
+				Updates(map[string]interface{}{"is_equipped": false, "position": models.PositionBackpack}).Error; err != nil {
+				return err
+			}
+		}
+		if len(equip) > 0 {
+			if err := tx.Model(&models.UserEquipment{}).Where("id IN ?", equip).
+				Updates(map[string]interface{}{"is_equipped": true, "position": "equipped"}).Error; err != nil {
+				return err
+			}
+		}
+
+		if loadout.SkinID != 0 {
+			var owned int64
+			if err := tx.Model(&models.UserSkin{}).Where("user_id = ? AND skin_id = ?", userID, loadout.SkinID).Count(&owned).Error; err != nil {
+				return err
+			}
+			if owned == 0 {
+				result.SkinMissing = true
+			} else {
+				if err := tx.Model(&models.UserSkin{}).Where("user_id = ?", userID).Update("is_active", false).Error; err != nil {
+					return err
+				}
+				if err := tx.Model(&models.UserSkin{}).Where("user_id = ? AND skin_id = ?", userID, loadout.SkinID).
+					Update("is_active", true).Error; err != nil {
+					return err
+				}
+			}
+		}
+
+		view, err := loadoutView(tx, userID, &loadout)
+		if err != nil {
+			return err
+		}
+		result.Loadout = view
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// 战力为派生数据，刷新失败不影响本次操作
+	stats.Refresh(s.DB, userID)
+	return result, nil
+}
+
+// loadoutSlots 装备部位
+var loadoutSlots = []string{"weapon", "helmet", "chest", "gloves", "pants", "boots"}
+
+// loadoutEmptySlots 方案中没有装备的部位（切换时这些部位会被卸下）
+func loadoutEmptySlots(items []models.LoadoutItem) []string {
+	used := make(map[string]bool, len(items))
+	for _, item := range items {
+		used[item.Slot] = true
+	}
+	var empty []string
+	for _, slot := range loadoutSlots {
+		if !used[slot] {
+			empty = append(empty, slot)
+		}
+	}
+	return empty
+}
+
+// loadoutView 组装方案的展示结构，标记已失效的装备和皮肤
+func loadoutView(db *gorm.DB, userID uint, loadout *models.Loadout) (LoadoutView, error) {
+	view := LoadoutView{
+		ID:        loadout.ID,
+		Name:      loadout.Name,
+		SkinID:    loadout.SkinID,
+		Slots:     make([]LoadoutSlot, 0, len(loadout.Items)),
+		UpdatedAt: loadout.UpdatedAt,
+	}
+
+	ids := make([]uint, 0, len(loadout.Items))
+	for _, item := range loadout.Items {
+		ids = append(ids, item.UserEquipmentID)
+	}
+	var equipments []models.UserEquipment
+	if len(ids) > 0 {
+		if err := db.Preload("EquipmentTemplate").Preload("AdditionalAttrs").
+			Where("id IN ? AND user_id = ?", ids, userID).Find(&equipments).Error; err != nil {
+			return view, err
+		}
+	}
+	byID := make(map[uint]*models.UserEquipment, len(equipments))
+	for i := range equipments {
+		byID[equipments[i].ID] = &equipments[i]
+	}
+
+	for _, item := range loadout.Items {
+		slot := LoadoutSlot{Slot: item.Slot, UserEquipmentID: item.UserEquipmentID}
+		if eq, ok := byID[item.UserEquipmentID]; ok && eq.EquipmentTemplate.Slot == item.Slot {
+			slot.Equipment = eq
+		} else {
+			slot.Missing = true
+		}
+		view.Slots = append(view.Slots, slot)
+	}
+
+	if loadout.SkinID != 0 {
+		var owned int64
+		if err := db.Model(&models.UserSkin{}).Where("user_id = ? AND skin_id = ?", userID, loadout.SkinID).Count(&owned).Error; err != nil {
+			return view, err
+		}
+		view.SkinMissing = owned == 0
+	}
+	return view, nil
+}
@@ -0,0 +1,477 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"ggo/models"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 交易行：手续费比例、每个玩家同时寄售的数量上限和单笔售价上限
+const (
+	MarketFeePercent   = 5
+	marketMaxListings  = 20
+	marketMaxPrice     = 100000000
+	marketDefaultHours = 24
+)
+
+// 可选的寄售时长（小时）
+var marketDurations = map[int]bool{12: true, 24: true, 48: true}
+
+var (
+	ErrMarketListingNotFound = errors.New("寄售不存在或已下架")
+	ErrMarketKind            = errors.New("无效的寄售类型，只支持 item, equipment")
+	ErrMarketCurrency        = errors.New("无效的货币类型，只支持 gold, diamond")
+	ErrMarketPrice           = fmt.Errorf("售价必须在1~%d之间", marketMaxPrice)
+	ErrMarketDuration        = errors.New("寄售时长只支持12、24、48小时")
+	ErrMarketTooMany         = fmt.Errorf("同时最多寄售%d件物品", marketMaxListings)
+	ErrMarketOwnListing      = errors.New("不能购买自己寄售的物品")
+)
+
+// MarketListRequest 上架请求
+type MarketListRequest struct {
+	Area            int    `json:"area" binding:"required,min=1"` // 区服ID
+	Kind            string `json:"kind" binding:"required"`       // item, equipment
+	MyItemID        uint   `json:"my_item_id"`                    // 寄售的背包物品ID（item）
+	Quantity        int    `json:"quantity"`                      // 寄售数量（item），0 表示整个堆叠
+	UserEquipmentID uint   `json:"user_equipment_id"`             // 寄售的玩家装备ID（equipment）
+	Currency        string `json:"currency" binding:"required"`   // gold, diamond
+	Price           int    `json:"price" binding:"required"`      // 整单售价
+	DurationHours   int    `json:"duration_hours"`                // 寄售时长：12、24、48，默认24
+}
+
+// MarketSearch 交易行搜索条件，零值表示不限
+type MarketSearch struct {
+	Area      int
+	Kind      string
+	Level     int
+	Slot      string
+	AffixType string
+	Currency  string
+	Sort      string // price_asc(默认), price_desc, newest
+	Page      int
+	PageSize  int
+}
+
+// MarketBuyResult 购买结果
+type MarketBuyResult struct {
+	Listing        *models.MarketListing `json:"listing"`
+	Item           *models.MyItem        `json:"item,omitempty"`      // 购买后背包中的物品堆叠（item）
+	Equipment      *models.UserEquipment `json:"equipment,omitempty"` // 购买的装备（equipment）
+	CurrentBalance int                   `json:"current_balance"`     // 购买后标价货币的余额
+}
+
+// MarketService 交易行：玩家之间以金币或钻石寄售背包物品和装备
+type MarketService struct {
+	DB *gorm.DB
+}
+
+func NewMarketService(db *gorm.DB) *MarketService {
+	return &MarketService{DB: db}
+}
+
+// MarketFee 售价对应的手续费，不足1时按1收取
+func MarketFee(price int) int {
+	return max(price*MarketFeePercent/100, 1)
+}
+
+// List 上架：物品从背包扣除、装备转入托管（user_id 为0），直到售出、到期或下架
+func (s *MarketService) List(userID uint, req MarketListRequest) (*models.MarketListing, error) {
+	if req.Currency != models.CurrencyGold && req.Currency != models.CurrencyDiamond {
+		return nil, ErrMarketCurrency
+	}
+	if req.Price <= 0 || req.Price > marketMaxPrice {
+		return nil, ErrMarketPrice
+	}
+	if req.DurationHours == 0 {
+		req.DurationHours = marketDefaultHours
+	}
+	if !marketDurations[req.DurationHours] {
+		return nil, ErrMarketDuration
+	}
+
+	listing := models.MarketListing{
+		Area:      req.Area,
+		SellerID:  userID,
+		Kind:      req.Kind,
+		Currency:  req.Currency,
+		Price:     req.Price,
+		Fee:       MarketFee(req.Price),
+		Status:    models.MarketStatusActive,
+		ExpiresAt: time.Now().Add(time.Duration(req.DurationHours) * time.Hour).Unix(),
+	}
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		// 锁定卖家，使同一玩家的寄售数量检查串行执行
+		var user models.User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&user, userID).Error; err != nil {
+			return err
+		}
+		var active int64
+		if err := tx.Model(&models.MarketListing{}).
+			Where("seller_id = ? AND status = ?", userID, models.MarketStatusActive).Count(&active).Error; err != nil {
+			return err
+		}
+		if active >= marketMaxListings {
+			return ErrMarketTooMany
+		}
+
+		switch req.Kind {
+		case models.MarketKindItem:
+			if err := escrowMyItem(tx, userID, req.MyItemID, req.Quantity, &listing); err != nil {
+				return err
+			}
+		case models.MarketKindEquipment:
+			if err := escrowEquipment(tx, userID, req.UserEquipmentID, &listing); err != nil {
+				return err
+			}
+		default:
+			return ErrMarketKind
+		}
+		return tx.Create(&listing).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.load(listing.ID)
+}
+
+// escrowMyItem 从卖家背包扣除寄售的物品，并记录物品信息
+func escrowMyItem(tx *gorm.DB, userID, myItemID uint, quantity int, listing *models.MarketListing) error {
+	var myItem models.MyItem
+	if err := tx.Where("id = ? AND user_id = ?", myItemID, userID).First(&myItem).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrMyItemNotEnough
+		}
+		return err
+	}
+	if quantity == 0 {
+		quantity = myItem.Quantity
+	}
+	if _, err := TakeMyItem(tx, userID, myItem.ItemType, myItem.ID, quantity); err != nil {
+		return err
+	}
+
+	listing.ItemType = myItem.ItemType
+	listing.ItemID = myItem.ItemID
+	listing.Quantity = quantity
+	switch myItem.ItemType {
+	case models.MyItemTypeTreasure:
+		var treasure models.Treasure
+		if err := tx.First(&treasure, myItem.ItemID).Error; err != nil {
+			return err
+		}
+		listing.Name = treasure.Name
+		listing.Level = treasure.Level
+	case models.MyItemTypeConsumable:
+		var consumable models.Consumable
+		if err := tx.First(&consumable, myItem.ItemID).Error; err != nil {
+			return err
+		}
+		listing.Name = consumable.Name
+	}
+	return nil
+}
+
+// escrowEquipment 把卖家的装备转入托管；锁定和穿戴中的装备不能寄售
+func escrowEquipment(tx *gorm.DB, userID, equipmentID uint, listing *models.MarketListing) error {
+	var equipment models.UserEquipment
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id = ? AND user_id = ?", equipmentID, userID).First(&equipment).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrEquipmentNotFound
+		}
+		return err
+	}
+	if err := CheckEquipmentDisposable(&equipment, false); err != nil {
+		return err
+	}
+	if err := tx.First(&equipment.EquipmentTemplate, equipment.EquipmentID).Error; err != nil {
+		return err
+	}
+	if err := tx.Model(&models.UserEquipment{}).Where("id = ?", equipment.ID).Updates(map[string]interface{}{
+		"user_id":  0,
+		"position": models.PositionMarket,
+	}).Error; err != nil {
+		return err
+	}
+
+	listing.UserEquipmentID = equipment.ID
+	listing.Quantity = 1
+	listing.Name = equipment.EquipmentTemplate.Name
+	listing.Level = equipment.EquipmentTemplate.Level
+	listing.Slot = equipment.EquipmentTemplate.Slot
+	return nil
+}
+
+// Search 搜索某个区服寄售中且未到期的物品
+func (s *MarketService) Search(q MarketSearch) ([]models.MarketListing, int64, error) {
+	query := s.DB.Model(&models.MarketListing{}).
+		Where("area = ? AND status = ? AND expires_at > ?", q.Area, models.MarketStatusActive, time.Now().Unix())
+	if q.Kind != "" {
+		query = query.Where("kind = ?", q.Kind)
+	}
+	if q.Level > 0 {
+		query = query.Where("level = ?", q.Level)
+	}
+	if q.Slot != "" {
+		query = query.Where("slot = ?", q.Slot)
+	}
+	if q.AffixType != "" {
+		query = query.Where("user_equipment_id IN (?)", s.DB.Model(&models.EquipmentAdditionalAttr{}).
+			Select("user_equipment_id").Where("attr_type = ?", q.AffixType))
+	}
+	if q.Currency != "" {
+		query = query.Where("currency = ?", q.Currency)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	order := "price asc, id asc"
+	switch q.Sort {
+	case "price_desc":
+		order = "price desc, id asc"
+	case "newest":
+		order = "id desc"
+	}
+	var listings []models.MarketListing
+	if err := query.Order(order).Offset((q.Page - 1) * q.PageSize).Limit(q.PageSize).
+		Preload("Equipment.EquipmentTemplate").Preload("Equipment.AdditionalAttrs").
+		Find(&listings).Error; err != nil {
+		return nil, 0, err
+	}
+	return listings, total, nil
+}
+
+// MyListings 玩家在某个区服的寄售记录，status 为空时返回全部
+func (s *MarketService) MyListings(userID uint, area int, status string) ([]models.MarketListing, error) {
+	query := s.DB.Where("seller_id = ? AND area = ?", userID, area)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	var listings []models.MarketListing
+	err := query.Order("id desc").Limit(100).
+		Preload("Equipment.EquipmentTemplate").Preload("Equipment.AdditionalAttrs").
+		Find(&listings).Error
+	return listings, err
+}
+
+// Buy 购买整单寄售：买家扣款，卖家收到扣除手续费后的货款，物品直接放入买家背包；
+// 背包放不下时购买失败，不扣款
+func (s *MarketService) Buy(buyerID, listingID uint) (*MarketBuyResult, error) {
+	result := &MarketBuyResult{}
+	var listing models.MarketListing
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&listing, listingID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrMarketListingNotFound
+			}
+			return err
+		}
+		if listing.Status != models.MarketStatusActive || listing.ExpiresAt <= time.Now().Unix() {
+			return ErrMarketListingNotFound
+		}
+		if listing.SellerID == buyerID {
+			return ErrMarketOwnListing
+		}
+		// 按ID顺序锁定买卖双方，避免互相购买时死锁
+		var users []models.User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").
+			Where("id IN ?", []uint{buyerID, listing.SellerID}).Order("id").Find(&users).Error; err != nil {
+			return err
+		}
+
+		paid, err := ChangeCurrency(tx, CurrencyChange{
+			UserID:     buyerID,
+			Currency:   listing.Currency,
+			Delta:      -listing.Price,
+			Reason:     models.CurrencyReasonMarketBuy,
+			SourceType: "market_listing",
+			SourceID:   listing.ID,
+		})
+		if err != nil {
+			return err
+		}
+		result.CurrentBalance = paid.BalanceAfter
+		if income := listing.Price - listing.Fee; income > 0 {
+			if _, err := ChangeCurrency(tx, CurrencyChange{
+				UserID:     listing.SellerID,
+				Currency:   listing.Currency,
+				Delta:      income,
+				Reason:     models.CurrencyReasonMarketSale,
+				SourceType: "market_listing",
+				SourceID:   listing.ID,
+			}); err != nil {
+				return err
+			}
+		}
+
+		switch listing.Kind {
+		case models.MarketKindItem:
+			item, err := AddMyItem(tx, buyerID, listing.ItemType, listing.ItemID, models.PositionBackpack, listing.Quantity)
+			if err != nil {
+				return err
+			}
+			result.Item = item
+		case models.MarketKindEquipment:
+			if err := releaseEquipment(tx, buyerID, listing.UserEquipmentID); err != nil {
+				return err
+			}
+		}
+
+		listing.Status = models.MarketStatusSold
+		listing.BuyerID = buyerID
+		listing.ClosedAt = time.Now().Unix()
+		return tx.Model(&models.MarketListing{}).Where("id = ?", listing.ID).Updates(map[string]interface{}{
+			"status":    listing.Status,
+			"buyer_id":  listing.BuyerID,
+			"closed_at": listing.ClosedAt,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	loaded, err := s.load(listing.ID)
+	if err != nil {
+		return nil, err
+	}
+	result.Listing = loaded
+	result.Equipment = loaded.Equipment
+	return result, nil
+}
+
+// Cancel 卖家下架寄售中的物品，物品以邮件退回
+func (s *MarketService) Cancel(userID, listingID uint) (*models.MarketListing, error) {
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		var listing models.MarketListing
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND seller_id = ?", listingID, userID).First(&listing).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrMarketListingNotFound
+			}
+			return err
+		}
+		if listing.Status != models.MarketStatusActive {
+			return ErrMarketListingNotFound
+		}
+		return closeListing(tx, &listing, models.MarketStatusCancelled)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.load(listingID)
+}
+
+// ExpireMarketListings 把到期未售出的寄售标记为到期，并以邮件退回卖家；返回处理的数量
+func ExpireMarketListings(db *gorm.DB) (int, error) {
+	var ids []uint
+	if err := db.Model(&models.MarketListing{}).
+		Where("status = ? AND expires_at <= ?", models.MarketStatusActive, time.Now().Unix()).
+		Order("id").Limit(500).Pluck("id", &ids).Error; err != nil {
+		return 0, err
+	}
+
+	expired := 0
+	for _, id := range ids {
+		err := db.Transaction(func(tx *gorm.DB) error {
+			var listing models.MarketListing
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&listing, id).Error; err != nil {
+				return err
+			}
+			// 加锁前可能已被购买或下架
+			if listing.Status != models.MarketStatusActive {
+				return nil
+			}
+			expired++
+			return closeListing(tx, &listing, models.MarketStatusExpired)
+		})
+		if err != nil {
+			return expired, err
+		}
+	}
+	return expired, nil
+}
+
+// closeListing 结束寄售并以邮件把物品退回卖家
+func closeListing(tx *gorm.DB, listing *models.MarketListing, status string) error {
+	reason := "已到期"
+	if status == models.MarketStatusCancelled {
+		reason = "已下架"
+	}
+	mail := models.Mail{
+		UserID:   listing.SellerID,
+		Area:     listing.Area,
+		Title:    "交易行物品退回",
+		Content:  fmt.Sprintf("您寄售的 %s ×%d %s，物品已退回，请领取。", listing.Name, listing.Quantity, reason),
+		ItemType: DropMailItemType(listing.ItemType),
+		ItemID:   listing.ItemID,
+		Num:      listing.Quantity,
+		Status:   0,
+	}
+	if listing.Kind == models.MarketKindEquipment {
+		mail.ItemType = models.MailItemTypeMarketEquipment
+		mail.ItemID = listing.UserEquipmentID
+		mail.Num = 1
+	}
+	if err := tx.Create(&mail).Error; err != nil {
+		return err
+	}
+
+	listing.Status = status
+	listing.ClosedAt = time.Now().Unix()
+	return tx.Model(&models.MarketListing{}).Where("id = ?", listing.ID).Updates(map[string]interface{}{
+		"status":    listing.Status,
+		"closed_at": listing.ClosedAt,
+	}).Error
+}
+
+// ClaimMarketEquipment 领取交易行退回的装备：托管中的装备转入玩家背包，背包已满时返回 ErrInventoryFull
+func ClaimMarketEquipment(tx *gorm.DB, userID, equipmentID uint) (*models.UserEquipment, error) {
+	if err := releaseEquipment(tx, userID, equipmentID); err != nil {
+		return nil, err
+	}
+	var equipment models.UserEquipment
+	if err := tx.Preload("EquipmentTemplate").Preload("AdditionalAttrs").First(&equipment, equipmentID).Error; err != nil {
+		return nil, err
+	}
+	return &equipment, nil
+}
+
+// releaseEquipment 把托管中的装备转入玩家背包
+func releaseEquipment(tx *gorm.DB, userID, equipmentID uint) error {
+	free, err := FreeSlots(tx, userID, models.PositionBackpack)
+	if err != nil {
+		return err
+	}
+	if free <= 0 {
+		return fmt.Errorf("%w：背包", ErrInventoryFull)
+	}
+	result := tx.Model(&models.UserEquipment{}).
+		Where("id = ? AND user_id = ? AND position = ?", equipmentID, 0, models.PositionMarket).
+		Updates(map[string]interface{}{
+			"user_id":  userID,
+			"position": models.PositionBackpack,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrEquipmentNotFound
+	}
+	return nil
+}
+
+func (s *MarketService) load(listingID uint) (*models.MarketListing, error) {
+	var listing models.MarketListing
+	if err := s.DB.Preload("Equipment.EquipmentTemplate").Preload("Equipment.AdditionalAttrs").
+		First(&listing, listingID).Error; err != nil {
+		return nil, err
+	}
+	return &listing, nil
+}
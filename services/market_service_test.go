@@ -0,0 +1,330 @@
+package services
+
+import (
+	"errors"
+	"ggo/models"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+// marketFixture 卖家背包中有10个宝物和一件装备，买家有1000金币
+type marketFixture struct {
+	db        *gorm.DB
+	market    *MarketService
+	seller    *models.User
+	buyer     *models.User
+	treasure  models.Treasure
+	myItem    models.MyItem
+	equipment models.UserEquipment
+}
+
+func newMarketFixture(t *testing.T) *marketFixture {
+	t.Helper()
+	db := newTestDB(t)
+	f := &marketFixture{
+		db:     db,
+		market: NewMarketService(db),
+		seller: createTestUser(t, db, "seller", 100, 0),
+		buyer:  createTestUser(t, db, "buyer", 1000, 0),
+	}
+
+	f.treasure = models.Treasure{Name: "月光石", Level: 2, MaxStack: 9999, IsActive: true}
+	if err := db.Create(&f.treasure).Error; err != nil {
+		t.Fatal(err)
+	}
+	item, err := AddMyItem(db, f.seller.ID, models.MyItemTypeTreasure, f.treasure.ID, models.PositionBackpack, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.myItem = *item
+
+	template := models.EquipmentTemplate{Name: "铁剑", Level: 3, Slot: "weapon", IsActive: true}
+	if err := db.Create(&template).Error; err != nil {
+		t.Fatal(err)
+	}
+	f.equipment = models.UserEquipment{UserID: f.seller.ID, EquipmentID: template.ID, Position: models.PositionBackpack, EnhanceLevel: 4}
+	if err := db.Create(&f.equipment).Error; err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func (f *marketFixture) listItem(t *testing.T, quantity, price int) *models.MarketListing {
+	t.Helper()
+	listing, err := f.market.List(f.seller.ID, MarketListRequest{
+		Area: 1, Kind: models.MarketKindItem, MyItemID: f.myItem.ID, Quantity: quantity,
+		Currency: models.CurrencyGold, Price: price,
+	})
+	if err != nil {
+		t.Fatalf("list item: %v", err)
+	}
+	return listing
+}
+
+func (f *marketFixture) listEquipment(t *testing.T, price int) *models.MarketListing {
+	t.Helper()
+	listing, err := f.market.List(f.seller.ID, MarketListRequest{
+		Area: 1, Kind: models.MarketKindEquipment, UserEquipmentID: f.equipment.ID,
+		Currency: models.CurrencyGold, Price: price,
+	})
+	if err != nil {
+		t.Fatalf("list equipment: %v", err)
+	}
+	return listing
+}
+
+func (f *marketFixture) itemQuantity(t *testing.T, userID uint) int {
+	t.Helper()
+	var item models.MyItem
+	err := f.db.Where("user_id = ? AND item_type = ? AND item_id = ? AND position = ?",
+		userID, models.MyItemTypeTreasure, f.treasure.ID, models.PositionBackpack).First(&item).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return item.Quantity
+}
+
+func (f *marketFixture) loadEquipment(t *testing.T) models.UserEquipment {
+	t.Helper()
+	var equipment models.UserEquipment
+	if err := f.db.First(&equipment, f.equipment.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	return equipment
+}
+
+func TestMarketFee(t *testing.T) {
+	tests := []struct{ price, fee int }{
+		{price: 1, fee: 1},
+		{price: 19, fee: 1},
+		{price: 100, fee: 5},
+		{price: 1999, fee: 99},
+	}
+	for _, tt := range tests {
+		if got := MarketFee(tt.price); got != tt.fee {
+			t.Errorf("MarketFee(%d) = %d, want %d", tt.price, got, tt.fee)
+		}
+	}
+}
+
+func TestMarketListEscrowsItem(t *testing.T) {
+	f := newMarketFixture(t)
+	listing := f.listItem(t, 4, 200)
+
+	if listing.Quantity != 4 || listing.Name != f.treasure.Name || listing.Fee != 10 || listing.Status != models.MarketStatusActive {
+		t.Fatalf("listing = %+v", listing)
+	}
+	if got := f.itemQuantity(t, f.seller.ID); got != 6 {
+		t.Fatalf("seller quantity = %d, want 6", got)
+	}
+}
+
+func TestMarketListEscrowsEquipment(t *testing.T) {
+	f := newMarketFixture(t)
+	f.listEquipment(t, 300)
+
+	equipment := f.loadEquipment(t)
+	if equipment.UserID != 0 || equipment.Position != models.PositionMarket || equipment.EnhanceLevel != 4 {
+		t.Fatalf("escrowed equipment = %+v", equipment)
+	}
+}
+
+func TestMarketListRejectsLockedEquipment(t *testing.T) {
+	f := newMarketFixture(t)
+	f.db.Model(&f.equipment).Update("is_locked", true)
+
+	_, err := f.market.List(f.seller.ID, MarketListRequest{
+		Area: 1, Kind: models.MarketKindEquipment, UserEquipmentID: f.equipment.ID,
+		Currency: models.CurrencyGold, Price: 300,
+	})
+	if err == nil {
+		t.Fatal("listing a locked equipment succeeded")
+	}
+	if equipment := f.loadEquipment(t); equipment.UserID != f.seller.ID || equipment.Position != models.PositionBackpack {
+		t.Fatalf("equipment moved: %+v", equipment)
+	}
+}
+
+func TestMarketBuy(t *testing.T) {
+	tests := []struct {
+		name  string
+		kind  string
+		price int
+	}{
+		{name: "物品", kind: models.MarketKindItem, price: 200},
+		{name: "装备", kind: models.MarketKindEquipment, price: 300},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newMarketFixture(t)
+			var listing *models.MarketListing
+			if tt.kind == models.MarketKindItem {
+				listing = f.listItem(t, 4, tt.price)
+			} else {
+				listing = f.listEquipment(t, tt.price)
+			}
+
+			result, err := f.market.Buy(f.buyer.ID, listing.ID)
+			if err != nil {
+				t.Fatalf("buy: %v", err)
+			}
+
+			fee := MarketFee(tt.price)
+			if buyerGold, _ := userBalance(t, f.db, f.buyer.ID); buyerGold != 1000-tt.price || result.CurrentBalance != buyerGold {
+				t.Fatalf("buyer gold = %d (result %d), want %d", buyerGold, result.CurrentBalance, 1000-tt.price)
+			}
+			if sellerGold, _ := userBalance(t, f.db, f.seller.ID); sellerGold != 100+tt.price-fee {
+				t.Fatalf("seller gold = %d, want %d", sellerGold, 100+tt.price-fee)
+			}
+			assertLedgerBalanced(t, f.db)
+
+			if result.Listing.Status != models.MarketStatusSold || result.Listing.BuyerID != f.buyer.ID {
+				t.Fatalf("listing = %+v", result.Listing)
+			}
+			if tt.kind == models.MarketKindItem {
+				if got := f.itemQuantity(t, f.buyer.ID); got != 4 {
+					t.Fatalf("buyer quantity = %d, want 4", got)
+				}
+				return
+			}
+			equipment := f.loadEquipment(t)
+			if equipment.UserID != f.buyer.ID || equipment.Position != models.PositionBackpack || equipment.EnhanceLevel != 4 {
+				t.Fatalf("bought equipment = %+v", equipment)
+			}
+		})
+	}
+}
+
+func TestMarketBuyFailures(t *testing.T) {
+	tests := []struct {
+		name    string
+		buyer   func(f *marketFixture) uint
+		setup   func(t *testing.T, f *marketFixture, listing *models.MarketListing)
+		wantErr error
+	}{
+		{
+			name:    "余额不足",
+			buyer:   func(f *marketFixture) uint { return f.buyer.ID },
+			setup:   func(t *testing.T, f *marketFixture, listing *models.MarketListing) {},
+			wantErr: ErrInsufficientGold,
+		},
+		{
+			name:    "购买自己的寄售",
+			buyer:   func(f *marketFixture) uint { return f.seller.ID },
+			setup:   func(t *testing.T, f *marketFixture, listing *models.MarketListing) {},
+			wantErr: ErrMarketOwnListing,
+		},
+		{
+			name:  "已下架",
+			buyer: func(f *marketFixture) uint { return f.buyer.ID },
+			setup: func(t *testing.T, f *marketFixture, listing *models.MarketListing) {
+				if _, err := f.market.Cancel(f.seller.ID, listing.ID); err != nil {
+					t.Fatal(err)
+				}
+			},
+			wantErr: ErrMarketListingNotFound,
+		},
+		{
+			name:  "已到期",
+			buyer: func(f *marketFixture) uint { return f.buyer.ID },
+			setup: func(t *testing.T, f *marketFixture, listing *models.MarketListing) {
+				f.db.Model(&models.MarketListing{}).Where("id = ?", listing.ID).Update("expires_at", 1)
+			},
+			wantErr: ErrMarketListingNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newMarketFixture(t)
+			listing := f.listItem(t, 4, 5000)
+			tt.setup(t, f, listing)
+
+			if _, err := f.market.Buy(tt.buyer(f), listing.ID); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if buyerGold, _ := userBalance(t, f.db, f.buyer.ID); buyerGold != 1000 {
+				t.Fatalf("buyer gold = %d, want 1000", buyerGold)
+			}
+			if sellerGold, _ := userBalance(t, f.db, f.seller.ID); sellerGold != 100 {
+				t.Fatalf("seller gold = %d, want 100", sellerGold)
+			}
+			if got := f.itemQuantity(t, f.buyer.ID); got != 0 {
+				t.Fatalf("buyer quantity = %d, want 0", got)
+			}
+			assertLedgerBalanced(t, f.db)
+		})
+	}
+}
+
+func TestMarketCancelReturnsEquipmentByMail(t *testing.T) {
+	f := newMarketFixture(t)
+	listing := f.listEquipment(t, 300)
+
+	cancelled, err := f.market.Cancel(f.seller.ID, listing.ID)
+	if err != nil {
+		t.Fatalf("cancel: %v", err)
+	}
+	if cancelled.Status != models.MarketStatusCancelled {
+		t.Fatalf("status = %s, want %s", cancelled.Status, models.MarketStatusCancelled)
+	}
+
+	var mail models.Mail
+	if err := f.db.Where("user_id = ?", f.seller.ID).First(&mail).Error; err != nil {
+		t.Fatalf("return mail: %v", err)
+	}
+	if mail.ItemType != models.MailItemTypeMarketEquipment || mail.ItemID != f.equipment.ID || mail.Num != 1 {
+		t.Fatalf("mail = %+v", mail)
+	}
+	// 领取前装备仍在托管中
+	if equipment := f.loadEquipment(t); equipment.UserID != 0 {
+		t.Fatalf("equipment released before claim: %+v", equipment)
+	}
+
+	var claimed *models.UserEquipment
+	if err := f.db.Transaction(func(tx *gorm.DB) error {
+		claimed, err = ClaimMarketEquipment(tx, f.seller.ID, mail.ItemID)
+		return err
+	}); err != nil {
+		t.Fatalf("claim: %v", err)
+	}
+	if claimed.UserID != f.seller.ID || claimed.Position != models.PositionBackpack || claimed.EnhanceLevel != 4 {
+		t.Fatalf("claimed equipment = %+v", claimed)
+	}
+
+	// 同一件装备不能再次领取
+	err = f.db.Transaction(func(tx *gorm.DB) error {
+		_, err := ClaimMarketEquipment(tx, f.seller.ID, mail.ItemID)
+		return err
+	})
+	if !errors.Is(err, ErrEquipmentNotFound) {
+		t.Fatalf("second claim err = %v, want %v", err, ErrEquipmentNotFound)
+	}
+}
+
+func TestExpireMarketListingsReturnsItemByMail(t *testing.T) {
+	f := newMarketFixture(t)
+	listing := f.listItem(t, 4, 200)
+	f.db.Model(&models.MarketListing{}).Where("id = ?", listing.ID).Update("expires_at", 1)
+
+	expired, err := ExpireMarketListings(f.db)
+	if err != nil || expired != 1 {
+		t.Fatalf("expired = %d, err = %v", expired, err)
+	}
+
+	var mail models.Mail
+	if err := f.db.Where("user_id = ?", f.seller.ID).First(&mail).Error; err != nil {
+		t.Fatalf("return mail: %v", err)
+	}
+	if mail.ItemType != DropMailItemType(models.MyItemTypeTreasure) || mail.ItemID != f.treasure.ID || mail.Num != 4 {
+		t.Fatalf("mail = %+v", mail)
+	}
+
+	// 再次执行不会重复退回
+	if expired, err := ExpireMarketListings(f.db); err != nil || expired != 0 {
+		t.Fatalf("second run expired = %d, err = %v", expired, err)
+	}
+}
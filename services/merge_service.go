@@ -0,0 +1,188 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"ggo/models"
+	"ggo/services/stats"
+	"math/rand"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 单次融合最多使用的材料装备数量
+const maxMergeMaterials = 20
+
+var (
+	ErrMergeEmpty     = errors.New("请选择材料装备")
+	ErrMergeTooMany   = fmt.Errorf("单次最多使用%d件材料装备", maxMergeMaterials)
+	ErrMergeSelf      = errors.New("主装备不能作为材料")
+	ErrMergeAffixFull = errors.New("主装备附加属性已达上限")
+)
+
+// MergeOutcome 单件材料装备的融合结果
+type MergeOutcome struct {
+	MaterialID   uint                            `json:"material_id"`   // 材料装备ID
+	Name         string                          `json:"name"`          // 材料装备名称
+	Level        int                             `json:"level"`         // 材料装备品级
+	Consumed     bool                            `json:"consumed"`      // 是否已消耗；主装备词条达到上限后剩余材料不消耗
+	Success      bool                            `json:"success"`       // 是否获得新词条
+	Inherited    bool                            `json:"inherited"`     // 新词条是否继承自材料装备
+	NewAttribute *models.EquipmentAdditionalAttr `json:"new_attribute"` // 新词条
+}
+
+// MergeResult 融合结果
+type MergeResult struct {
+	MainEquipment *models.UserEquipment `json:"main_equipment"`
+	Outcomes      []MergeOutcome        `json:"results"`
+}
+
+// MergeService 装备融合：消耗材料装备，按概率为主装备继承或新增词条
+type MergeService struct {
+	DB *gorm.DB
+}
+
+func NewMergeService(db *gorm.DB) *MergeService {
+	return &MergeService{DB: db}
+}
+
+// Merge 用一件或多件材料装备依次融合到主装备。主装备和材料都必须属于该用户，材料不能是主装备本身、
+// 不能穿戴中或锁定（多件材料时也不能是收藏的装备），任意一件不满足条件时整体失败；
+// 融合过程中主装备词条达到上限时停止，剩余材料不消耗
+func (s *MergeService) Merge(userID, mainID uint, materialIDs []uint) (*MergeResult, error) {
+	ids := uniqueIDs(materialIDs)
+	if len(ids) == 0 {
+		return nil, ErrMergeEmpty
+	}
+	if len(ids) > maxMergeMaterials {
+		return nil, ErrMergeTooMany
+	}
+	for _, id := range ids {
+		if id == mainID {
+			return nil, ErrMergeSelf
+		}
+	}
+
+	registry, err := GetAffixRegistry(s.DB)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &MergeResult{Outcomes: make([]MergeOutcome, 0, len(ids))}
+	var main models.UserEquipment
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND user_id = ?", mainID, userID).First(&main).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return fmt.Errorf("主%w", ErrEquipmentNotFound)
+			}
+			return err
+		}
+		if err := tx.First(&main.EquipmentTemplate, main.EquipmentID).Error; err != nil {
+			return err
+		}
+		var affixCount int64
+		if err := tx.Model(&models.EquipmentAdditionalAttr{}).
+			Where("user_equipment_id = ?", main.ID).Count(&affixCount).Error; err != nil {
+			return err
+		}
+		if int(affixCount) >= registry.Rules.MaxAffixes {
+			return ErrMergeAffixFull
+		}
+
+		var materials []models.UserEquipment
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id IN ? AND user_id = ?", ids, userID).Find(&materials).Error; err != nil {
+			return err
+		}
+		if len(materials) != len(ids) {
+			return fmt.Errorf("材料%w", ErrEquipmentNotFound)
+		}
+		batch := len(ids) > 1
+		for i := range materials {
+			if err := CheckEquipmentDisposable(&materials[i], batch); err != nil {
+				return err
+			}
+		}
+		if err := tx.Preload("EquipmentTemplate").Preload("AdditionalAttrs").
+			Where("id IN ?", ids).Find(&materials).Error; err != nil {
+			return err
+		}
+		byID := make(map[uint]*models.UserEquipment, len(materials))
+		for i := range materials {
+			byID[materials[i].ID] = &materials[i]
+		}
+
+		rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+		var consumed []uint
+		for _, id := range ids {
+			material := byID[id]
+			outcome := MergeOutcome{
+				MaterialID: material.ID,
+				Name:       material.EquipmentTemplate.Name,
+				Level:      material.EquipmentTemplate.Level,
+			}
+			if int(affixCount) >= registry.Rules.MaxAffixes {
+				result.Outcomes = append(result.Outcomes, outcome)
+				continue
+			}
+
+			outcome.Consumed = true
+			consumed = append(consumed, material.ID)
+			outcome.NewAttribute, outcome.Inherited = rollMerge(rng, registry, &main, material)
+			if outcome.NewAttribute != nil {
+				if err := tx.Create(outcome.NewAttribute).Error; err != nil {
+					return err
+				}
+				outcome.Success = true
+				affixCount++
+			}
+			result.Outcomes = append(result.Outcomes, outcome)
+		}
+
+		if err := tx.Where("user_equipment_id IN ?", consumed).Delete(&models.EquipmentAdditionalAttr{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("id IN ?", consumed).Delete(&models.UserEquipment{}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if main.IsEquipped {
+		stats.Refresh(s.DB, userID)
+	}
+	if err := s.DB.Preload("EquipmentTemplate").Preload("AdditionalAttrs").First(&main, main.ID).Error; err != nil {
+		return nil, err
+	}
+	result.MainEquipment = &main
+	return result, nil
+}
+
+// rollMerge 单件材料的融合结果：材料有词条时按概率继承其中一条（保留原规则集版本），
+// 否则按材料品级的概率在融合词条池中新增一条；返回新词条（未获得时为 nil）及是否为继承
+func rollMerge(rng *rand.Rand, registry *AffixRegistry, main, material *models.UserEquipment) (*models.EquipmentAdditionalAttr, bool) {
+	if len(material.AdditionalAttrs) > 0 && rng.Float64() < registry.Rules.MergeInheritChance {
+		materialAttr := material.AdditionalAttrs[rng.Intn(len(material.AdditionalAttrs))]
+		return &models.EquipmentAdditionalAttr{
+			UserEquipmentID: main.ID,
+			AttrType:        materialAttr.AttrType,
+			AttrName:        materialAttr.AttrName,
+			Value:           materialAttr.Value,
+			IsPercent:       materialAttr.IsPercent,
+			RulesetVersion:  materialAttr.RulesetVersion,
+		}, true
+	}
+
+	if rng.Float64() >= registry.MergeSuccessRate(material.EquipmentTemplate.Level) {
+		return nil, false
+	}
+	// 按主装备品级在融合词条池中生成新的附加属性
+	attr := registry.RollMergeAffix(rng, main.EquipmentTemplate.Level)
+	if attr != nil {
+		attr.UserEquipmentID = main.ID
+	}
+	return attr, false
+}
@@ -0,0 +1,178 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"ggo/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 单次批量回收的装备数量上限
+const maxRecycleBatch = 100
+
+var (
+	ErrRecycleEmpty         = errors.New("请选择要回收的装备")
+	ErrRecycleTooMany       = fmt.Errorf("单次最多回收%d件装备", maxRecycleBatch)
+	ErrRecycleConfigMissing = errors.New("未配置该品级的回收价格")
+)
+
+// RecycledEquipment 单件装备的回收明细
+type RecycledEquipment struct {
+	ID           uint   `json:"id"`            // 玩家装备ID
+	EquipmentID  uint   `json:"equipment_id"`  // 装备模板ID
+	Name         string `json:"name"`          // 装备名称
+	Level        int    `json:"level"`         // 装备品级
+	EnhanceLevel int    `json:"enhance_level"` // 强化等级
+	CommonAffix  int    `json:"common_affix"`  // 普通词条数
+	RareAffix    int    `json:"rare_affix"`    // 稀有词条数
+	Diamond      int    `json:"diamond"`       // 获得钻石
+}
+
+// RecycleResult 回收结果
+type RecycleResult struct {
+	Diamond        int                 `json:"diamond"`         // 本次共获得钻石
+	CurrentDiamond int                 `json:"current_diamond"` // 回收后钻石余额
+	Items          []RecycledEquipment `json:"items"`
+}
+
+// RecycleService 装备回收换取钻石
+type RecycleService struct {
+	DB *gorm.DB
+}
+
+func NewRecycleService(db *gorm.DB) *RecycleService {
+	return &RecycleService{DB: db}
+}
+
+// RecyclePayout 按回收价格配置计算单件装备可获得的钻石，需预加载 EquipmentTemplate 和 AdditionalAttrs
+func RecyclePayout(config models.EquipmentRecycleConfig, eq *models.UserEquipment, registry *AffixRegistry) RecycledEquipment {
+	item := RecycledEquipment{
+		ID:           eq.ID,
+		EquipmentID:  eq.EquipmentID,
+		Name:         eq.EquipmentTemplate.Name,
+		Level:        eq.EquipmentTemplate.Level,
+		EnhanceLevel: eq.EnhanceLevel,
+	}
+	for _, attr := range eq.AdditionalAttrs {
+		if registry.IsRare(attr.AttrType) {
+			item.RareAffix++
+		} else {
+			item.CommonAffix++
+		}
+	}
+	item.Diamond = config.BaseDiamond +
+		eq.EnhanceLevel*config.EnhanceDiamond +
+		item.CommonAffix*config.CommonAffixDiamond +
+		item.RareAffix*config.RareAffixDiamond
+	return item
+}
+
+// Recycle 回收装备：在一个事务中校验（必须属于该用户、未穿戴、未锁定，批量回收时不能是收藏的装备），
+// 删除装备及其词条并发放钻石；任意一件不满足条件时整批失败
+func (s *RecycleService) Recycle(userID uint, equipmentIDs []uint, batch bool) (*RecycleResult, error) {
+	ids := uniqueIDs(equipmentIDs)
+	if len(ids) == 0 {
+		return nil, ErrRecycleEmpty
+	}
+	if len(ids) > maxRecycleBatch {
+		return nil, ErrRecycleTooMany
+	}
+
+	registry, err := GetAffixRegistry(s.DB)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &RecycleResult{Items: []RecycledEquipment{}}
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		var equipments []models.UserEquipment
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id IN ? AND user_id = ?", ids, userID).Order("id").Find(&equipments).Error; err != nil {
+			return err
+		}
+		if len(equipments) != len(ids) {
+			return ErrEquipmentNotFound
+		}
+		for i := range equipments {
+			if err := CheckEquipmentDisposable(&equipments[i], batch); err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Preload("EquipmentTemplate").Preload("AdditionalAttrs").
+			Where("id IN ?", ids).Order("id").Find(&equipments).Error; err != nil {
+			return err
+		}
+
+		var configs []models.EquipmentRecycleConfig
+		if err := tx.Find(&configs).Error; err != nil {
+			return err
+		}
+		configByLevel := make(map[int]models.EquipmentRecycleConfig, len(configs))
+		for _, config := range configs {
+			configByLevel[config.Level] = config
+		}
+
+		for i := range equipments {
+			eq := &equipments[i]
+			config, ok := configByLevel[eq.EquipmentTemplate.Level]
+			if !ok {
+				return fmt.Errorf("%w（品级: %d）", ErrRecycleConfigMissing, eq.EquipmentTemplate.Level)
+			}
+			item := RecyclePayout(config, eq, registry)
+			result.Items = append(result.Items, item)
+			result.Diamond += item.Diamond
+
+			if item.Diamond > 0 {
+				diamondTx, err := ChangeCurrency(tx, CurrencyChange{
+					UserID:     userID,
+					Currency:   models.CurrencyDiamond,
+					Delta:      item.Diamond,
+					Reason:     models.CurrencyReasonEquipmentRecycle,
+					SourceType: "user_equipment",
+					SourceID:   eq.ID,
+				})
+				if err != nil {
+					return err
+				}
+				result.CurrentDiamond = diamondTx.BalanceAfter
+			}
+		}
+
+		if err := tx.Where("user_equipment_id IN ?", ids).Delete(&models.EquipmentAdditionalAttr{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("id IN ?", ids).Delete(&models.UserEquipment{}).Error; err != nil {
+			return err
+		}
+
+		if result.Diamond == 0 {
+			var user models.User
+			if err := tx.Select("diamond").First(&user, userID).Error; err != nil {
+				return err
+			}
+			result.CurrentDiamond = user.Diamond
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// uniqueIDs 去掉重复和为0的ID，保持原顺序
+func uniqueIDs(ids []uint) []uint {
+	seen := make(map[uint]bool, len(ids))
+	result := make([]uint, 0, len(ids))
+	for _, id := range ids {
+		if id == 0 || seen[id] {
+			continue
+		}
+		seen[id] = true
+		result = append(result, id)
+	}
+	return result
+}
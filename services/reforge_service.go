@@ -0,0 +1,375 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"ggo/models"
+	"ggo/services/stats"
+	"math/rand"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	reforgeTTL               = 10 * time.Minute // 预览结果的确认时限
+	reforgeOtherRerollChance = 0.3              // 未锁定时，其他词条各自一并重新随机数值的概率
+)
+
+// 重铸消耗的金币，按装备品级；替换词条类型、锁定其他词条时各翻倍
+var reforgeGoldCosts = map[int]int{
+	1: 5000,
+	2: 10000,
+	3: 20000,
+	4: 40000,
+	5: 60000,
+	6: 100000,
+}
+
+var (
+	ErrReforgeInvalid     = errors.New("重铸参数无效")
+	ErrReforgeAttrMissing = errors.New("词条不存在")
+	ErrReforgePending     = errors.New("该装备有未确认的重铸结果，请先接受或放弃")
+	ErrReforgeMaterial    = errors.New("重铸材料无效")
+	ErrReforgeUnavailable = errors.New("该词条当前无法重铸")
+	ErrReforgeNotFound    = errors.New("重铸记录不存在")
+	ErrReforgeNotPending  = errors.New("重铸结果已处理")
+	ErrReforgeExpired     = errors.New("重铸结果已过期")
+	ErrReforgeStale       = errors.New("装备词条已变化，重铸结果失效")
+)
+
+// ReforgeRequest 重铸请求
+type ReforgeRequest struct {
+	AttrID     uint   `json:"attr_id" binding:"required"` // 要重铸的词条ID
+	Mode       string `json:"mode" binding:"required"`    // value: 重新随机数值, type: 替换为同品质的其他词条
+	LockOthers bool   `json:"lock_others"`                // 锁定其他词条（消耗更多），未锁定时其他词条可能一并重新随机数值
+	ItemIDs    []uint `json:"item_ids"`                   // 消耗的宝物（背包宝物记录ID，可重复），等级不低于装备品级
+}
+
+// ReforgeCost 重铸消耗
+type ReforgeCost struct {
+	Gold      int `json:"gold"`
+	Treasures int `json:"treasures"` // 需要的宝物数量
+}
+
+// ReforgeCostFor 计算重铸消耗
+func ReforgeCostFor(rarity int, mode string, lockOthers bool) (ReforgeCost, bool) {
+	gold, ok := reforgeGoldCosts[rarity]
+	if !ok {
+		return ReforgeCost{}, false
+	}
+	cost := ReforgeCost{Gold: gold, Treasures: 1}
+	if mode == models.ReforgeModeType {
+		cost.Gold *= 2
+		cost.Treasures++
+	}
+	if lockOthers {
+		cost.Gold *= 2
+		cost.Treasures++
+	}
+	return cost, true
+}
+
+// ReforgeService 词条重铸：预览时扣除消耗并保存结果，接受后才修改装备词条
+type ReforgeService struct {
+	DB *gorm.DB
+}
+
+func NewReforgeService(db *gorm.DB) *ReforgeService {
+	return &ReforgeService{DB: db}
+}
+
+// Preview 生成重铸预览：扣除金币和宝物，随机新词条并保存为待确认记录，装备词条保持不变
+func (s *ReforgeService) Preview(userID, equipmentID uint, req ReforgeRequest) (*models.AffixReforge, error) {
+	if req.Mode != models.ReforgeModeValue && req.Mode != models.ReforgeModeType {
+		return nil, fmt.Errorf("%w：mode 只支持 value, type", ErrReforgeInvalid)
+	}
+
+	registry, err := GetAffixRegistry(s.DB)
+	if err != nil {
+		return nil, err
+	}
+
+	var reforge *models.AffixReforge
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		var equipment models.UserEquipment
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND user_id = ?", equipmentID, userID).First(&equipment).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrEquipmentNotFound
+			}
+			return err
+		}
+		if err := tx.First(&equipment.EquipmentTemplate, equipment.EquipmentID).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_equipment_id = ?", equipment.ID).Order("id").Find(&equipment.AdditionalAttrs).Error; err != nil {
+			return err
+		}
+
+		if err := expireReforges(tx, equipment.ID); err != nil {
+			return err
+		}
+		var pending int64
+		if err := tx.Model(&models.AffixReforge{}).
+			Where("user_equipment_id = ? AND status = ?", equipment.ID, models.ReforgeStatusPending).
+			Count(&pending).Error; err != nil {
+			return err
+		}
+		if pending > 0 {
+			return ErrReforgePending
+		}
+
+		var target *models.EquipmentAdditionalAttr
+		for i := range equipment.AdditionalAttrs {
+			if equipment.AdditionalAttrs[i].ID == req.AttrID {
+				target = &equipment.AdditionalAttrs[i]
+			}
+		}
+		if target == nil {
+			return ErrReforgeAttrMissing
+		}
+
+		rarity := equipment.EquipmentTemplate.Level
+		cost, ok := ReforgeCostFor(rarity, req.Mode, req.LockOthers)
+		if !ok {
+			return ErrReforgeUnavailable
+		}
+		if len(req.ItemIDs) != cost.Treasures {
+			return fmt.Errorf("%w：需要%d个%d级及以上的宝物", ErrReforgeMaterial, cost.Treasures, rarity)
+		}
+
+		rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+		changes, err := rollReforge(rng, registry, &equipment, target, req)
+		if err != nil {
+			return err
+		}
+
+		treasures, err := consumeTreasureItems(tx, userID, req.ItemIDs)
+		if errors.Is(err, ErrTreasureNotEnough) {
+			return fmt.Errorf("%w：%v", ErrReforgeMaterial, err)
+		}
+		if err != nil {
+			return err
+		}
+		for _, treasure := range treasures {
+			if treasure.Level < rarity {
+				return fmt.Errorf("%w：宝物等级不能低于装备品级", ErrReforgeMaterial)
+			}
+		}
+		if _, err := ChangeCurrency(tx, CurrencyChange{
+			UserID:     userID,
+			Currency:   models.CurrencyGold,
+			Delta:      -cost.Gold,
+			Reason:     models.CurrencyReasonAffixReforge,
+			SourceType: "user_equipment",
+			SourceID:   equipment.ID,
+		}); err != nil {
+			return err
+		}
+
+		reforge = &models.AffixReforge{
+			UserID:          userID,
+			UserEquipmentID: equipment.ID,
+			AttrID:          target.ID,
+			Mode:            req.Mode,
+			LockOthers:      req.LockOthers,
+			CostGold:        cost.Gold,
+			CostTreasures:   cost.Treasures,
+			Changes:         changes,
+			Status:          models.ReforgeStatusPending,
+			ExpiresAt:       time.Now().Add(reforgeTTL).Unix(),
+		}
+		return tx.Create(reforge).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return reforge, nil
+}
+
+// rollReforge 随机重铸结果：目标词条按方式重铸，未锁定时其他词条按概率重新随机数值
+func rollReforge(rng *rand.Rand, registry *AffixRegistry, equipment *models.UserEquipment, target *models.EquipmentAdditionalAttr, req ReforgeRequest) ([]models.AffixReforgeChange, error) {
+	level := equipment.EquipmentTemplate.Level
+	change := models.AffixReforgeChange{
+		AttrID:         target.ID,
+		OldType:        target.AttrType,
+		OldValue:       target.Value,
+		NewType:        target.AttrType,
+		NewName:        target.AttrName,
+		IsPercent:      target.IsPercent,
+		RulesetVersion: registry.Version,
+	}
+	if req.Mode == models.ReforgeModeType {
+		tier := models.AffixTierCommon
+		if registry.IsRare(target.AttrType) {
+			tier = models.AffixTierRare
+		}
+		attr := registry.RollReplaceAffix(rng, tier, level, target.AttrType)
+		if attr == nil {
+			return nil, ErrReforgeUnavailable
+		}
+		change.NewType = attr.AttrType
+		change.NewName = attr.AttrName
+		change.NewValue = attr.Value
+		change.IsPercent = attr.IsPercent
+	} else {
+		value, ok := registry.RerollValue(rng, target.AttrType, level)
+		if !ok {
+			return nil, ErrReforgeUnavailable
+		}
+		change.NewValue = value
+	}
+	changes := []models.AffixReforgeChange{change}
+
+	if req.LockOthers {
+		return changes, nil
+	}
+	for i := range equipment.AdditionalAttrs {
+		attr := &equipment.AdditionalAttrs[i]
+		if attr.ID == target.ID || rng.Float64() >= reforgeOtherRerollChance {
+			continue
+		}
+		value, ok := registry.RerollValue(rng, attr.AttrType, level)
+		if !ok {
+			continue
+		}
+		changes = append(changes, models.AffixReforgeChange{
+			AttrID:         attr.ID,
+			OldType:        attr.AttrType,
+			OldValue:       attr.Value,
+			NewType:        attr.AttrType,
+			NewName:        attr.AttrName,
+			NewValue:       value,
+			IsPercent:      attr.IsPercent,
+			RulesetVersion: registry.Version,
+		})
+	}
+	return changes, nil
+}
+
+// Pending 查询装备当前待确认的重铸结果，没有时返回 nil
+func (s *ReforgeService) Pending(userID, equipmentID uint) (*models.AffixReforge, error) {
+	if err := expireReforges(s.DB, equipmentID); err != nil {
+		return nil, err
+	}
+	var reforge models.AffixReforge
+	err := s.DB.Where("user_id = ? AND user_equipment_id = ? AND status = ?", userID, equipmentID, models.ReforgeStatusPending).
+		Order("id desc").First(&reforge).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &reforge, nil
+}
+
+// Accept 接受重铸结果：词条仍与预览时一致才写入装备，否则结果失效
+func (s *ReforgeService) Accept(userID, reforgeID uint) (*models.UserEquipment, error) {
+	var equipment models.UserEquipment
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		reforge, err := lockPendingReforge(tx, userID, reforgeID)
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND user_id = ?", reforge.UserEquipmentID, userID).First(&equipment).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrReforgeStale
+			}
+			return err
+		}
+
+		for _, change := range reforge.Changes {
+			var attr models.EquipmentAdditionalAttr
+			if err := tx.Where("id = ? AND user_equipment_id = ?", change.AttrID, equipment.ID).First(&attr).Error; err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return ErrReforgeStale
+				}
+				return err
+			}
+			if attr.AttrType != change.OldType || attr.Value != change.OldValue {
+				return ErrReforgeStale
+			}
+			if err := tx.Model(&attr).Updates(map[string]interface{}{
+				"attr_type":       change.NewType,
+				"attr_name":       change.NewName,
+				"value":           change.NewValue,
+				"is_percent":      change.IsPercent,
+				"ruleset_version": change.RulesetVersion,
+			}).Error; err != nil {
+				return err
+			}
+		}
+
+		return tx.Model(reforge).Update("status", models.ReforgeStatusAccepted).Error
+	})
+	switch {
+	case errors.Is(err, ErrReforgeExpired):
+		s.closeReforge(reforgeID, models.ReforgeStatusExpired)
+	case errors.Is(err, ErrReforgeStale):
+		s.closeReforge(reforgeID, models.ReforgeStatusDiscarded)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if equipment.IsEquipped {
+		stats.Refresh(s.DB, userID)
+	}
+	if err := s.DB.Preload("EquipmentTemplate").Preload("AdditionalAttrs").First(&equipment, equipment.ID).Error; err != nil {
+		return nil, err
+	}
+	return &equipment, nil
+}
+
+// Discard 放弃重铸结果，装备词条保持不变（消耗不退还）
+func (s *ReforgeService) Discard(userID, reforgeID uint) error {
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		reforge, err := lockPendingReforge(tx, userID, reforgeID)
+		if err != nil {
+			return err
+		}
+		return tx.Model(reforge).Update("status", models.ReforgeStatusDiscarded).Error
+	})
+	if errors.Is(err, ErrReforgeExpired) {
+		s.closeReforge(reforgeID, models.ReforgeStatusExpired)
+		return nil
+	}
+	return err
+}
+
+// lockPendingReforge 锁定并返回待确认的重铸记录，已超时返回 ErrReforgeExpired
+func lockPendingReforge(tx *gorm.DB, userID, reforgeID uint) (*models.AffixReforge, error) {
+	var reforge models.AffixReforge
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id = ? AND user_id = ?", reforgeID, userID).First(&reforge).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrReforgeNotFound
+		}
+		return nil, err
+	}
+	if reforge.Status != models.ReforgeStatusPending {
+		return nil, ErrReforgeNotPending
+	}
+	if time.Now().Unix() >= reforge.ExpiresAt {
+		return nil, ErrReforgeExpired
+	}
+	return &reforge, nil
+}
+
+// closeReforge 将仍处于待确认的重铸记录标记为指定状态
+func (s *ReforgeService) closeReforge(reforgeID uint, status string) {
+	s.DB.Model(&models.AffixReforge{}).Where("id = ? AND status = ?", reforgeID, models.ReforgeStatusPending).
+		Update("status", status)
+}
+
+// expireReforges 将装备已超时的待确认重铸记录标记为过期
+func expireReforges(db *gorm.DB, equipmentID uint) error {
+	return db.Model(&models.AffixReforge{}).
+		Where("user_equipment_id = ? AND status = ? AND expires_at <= ?", equipmentID, models.ReforgeStatusPending, time.Now().Unix()).
+		Update("status", models.ReforgeStatusExpired).Error
+}
@@ -0,0 +1,329 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"ggo/models"
+	"ggo/utils"
+	"math/rand"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	runMaxDuration        = 30 * time.Minute // 对局最长时长，超时无法结算
+	runMinDuration        = 20 * time.Second // 对局最短时长，不足时不能结算（防止开局后立即结算刷掉落或重刷开局）
+	runMinSecondsPerKill  = 1                // 每次击杀至少需要的秒数
+	runMinSecondsPerScene = 10               // 每个场景物掉落至少需要的对局秒数
+	runMonsterSpawnSlots  = 30               // 每局刷怪次数
+)
+
+var (
+	ErrRunNotFound      = errors.New("对局不存在")
+	ErrRunInProgress    = errors.New("已有进行中的对局，请先结算")
+	ErrRunTokenInvalid  = errors.New("对局token无效")
+	ErrRunNotActive     = errors.New("对局已结束")
+	ErrRunExpired       = errors.New("对局已超时")
+	ErrRunNoMonsters    = errors.New("当前区域没有可刷新的怪物")
+	ErrRunKillsInvalid  = errors.New("击杀数据与对局不符")
+	ErrRunFinishedEarly = errors.New("对局时长不足以完成上报的击杀数")
+	ErrRunTooShort      = fmt.Errorf("对局不足%d秒，不能结算", int(runMinDuration.Seconds()))
+)
+
+// RunKill 结算时上报的击杀数
+type RunKill struct {
+	MonsterID uint `json:"monster_id" binding:"required"`
+	Count     int  `json:"count" binding:"min=0"`
+}
+
+// RunResult 对局结算结果
+type RunResult struct {
+	Run         *models.GameRun `json:"run"`
+	Gold        int             `json:"gold"`         // 怪物基础金币奖励
+	Exp         int             `json:"exp"`          // 获得经验
+	Drops       []DropItem      `json:"drops"`        // 掉落表掉落（宝物、装备、金币、钻石）
+	CurrentGold int             `json:"current_gold"` // 结算后金币
+	CurrentExp  int             `json:"current_exp"`  // 结算后经验
+}
+
+// RunService 对局：开局刷怪与结算奖励均由服务端决定
+type RunService struct {
+	DB *gorm.DB
+}
+
+func NewRunService(db *gorm.DB) *RunService {
+	return &RunService{DB: db}
+}
+
+// Start 开局：按怪物/场景的 SpawnRate 生成本局刷新，返回对局和签名的对局token
+func (s *RunService) Start(userID uint, area int, region string) (*models.GameRun, string, error) {
+	monsterQuery := s.DB.Where("is_active = ? AND spawn_rate > 0", true)
+	sceneQuery := s.DB.Where("is_active = ? AND spawn_rate > 0", true)
+	if region != "" {
+		monsterQuery = monsterQuery.Where("spawn_location = ?", region)
+		sceneQuery = sceneQuery.Where("region = ?", region)
+	}
+
+	var monsters []models.Monster
+	if err := monsterQuery.Find(&monsters).Error; err != nil {
+		return nil, "", err
+	}
+	if len(monsters) == 0 {
+		return nil, "", ErrRunNoMonsters
+	}
+	var scenes []models.Scene
+	if err := sceneQuery.Find(&scenes).Error; err != nil {
+		return nil, "", err
+	}
+
+	seed := time.Now().UnixNano()
+	rng := rand.New(rand.NewSource(seed))
+
+	// 怪物：每次刷新按 SpawnRate 加权抽取一只
+	totalRate := 0.0
+	for _, m := range monsters {
+		totalRate += m.SpawnRate
+	}
+	monsterCounts := make(map[uint]int)
+	var monsterOrder []uint
+	for i := 0; i < runMonsterSpawnSlots; i++ {
+		roll := rng.Float64() * totalRate
+		for _, m := range monsters {
+			roll -= m.SpawnRate
+			if roll < 0 {
+				if monsterCounts[m.ID] == 0 {
+					monsterOrder = append(monsterOrder, m.ID)
+				}
+				monsterCounts[m.ID]++
+				break
+			}
+		}
+	}
+
+	run := models.GameRun{
+		UserID:    userID,
+		Area:      area,
+		Region:    region,
+		Seed:      seed,
+		Status:    models.GameRunStatusActive,
+		StartedAt: time.Now().Unix(),
+	}
+	for _, id := range monsterOrder {
+		run.Spawns = append(run.Spawns, models.GameRunSpawn{Kind: models.GameRunSpawnMonster, RefID: id, Count: monsterCounts[id]})
+	}
+	// 场景物：每个场景按 SpawnRate 独立判定是否出现
+	for _, scene := range scenes {
+		if rng.Float64() < scene.SpawnRate {
+			run.Spawns = append(run.Spawns, models.GameRunSpawn{Kind: models.GameRunSpawnScene, RefID: scene.ID, Count: 1})
+		}
+	}
+
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		// 锁定用户，使同一用户的开局串行执行；同一时间只能有一局进行中，避免并行对局重复领取奖励
+		var user models.User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&user, userID).Error; err != nil {
+			return err
+		}
+		// 超过最长时长的对局已无法结算，标记为超时作废
+		deadline := time.Now().Add(-runMaxDuration).Unix()
+		if err := tx.Model(&models.GameRun{}).
+			Where("user_id = ? AND status = ? AND started_at < ?", userID, models.GameRunStatusActive, deadline).
+			Update("status", models.GameRunStatusExpired).Error; err != nil {
+			return err
+		}
+		var active int64
+		if err := tx.Model(&models.GameRun{}).
+			Where("user_id = ? AND status = ?", userID, models.GameRunStatusActive).Count(&active).Error; err != nil {
+			return err
+		}
+		if active > 0 {
+			return ErrRunInProgress
+		}
+		return tx.Create(&run).Error
+	})
+	if err != nil {
+		return nil, "", err
+	}
+
+	token, err := utils.GenerateRunToken(run.ID, userID, runMaxDuration)
+	if err != nil {
+		return nil, "", err
+	}
+	return &run, token, nil
+}
+
+// Finish 结算：校验时长和击杀数，由服务端发放金币、经验和宝物掉落
+func (s *RunService) Finish(userID, runID uint, runToken string, kills []RunKill) (*RunResult, error) {
+	claims, err := utils.ParseRunToken(runToken)
+	if err != nil || claims.RunID != runID || claims.UserID != userID {
+		return nil, ErrRunTokenInvalid
+	}
+
+	result := &RunResult{Drops: []DropItem{}}
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		var run models.GameRun
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND user_id = ?", runID, userID).First(&run).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrRunNotFound
+			}
+			return err
+		}
+		if run.Status != models.GameRunStatusActive {
+			return ErrRunNotActive
+		}
+
+		now := time.Now()
+		elapsed := now.Unix() - run.StartedAt
+		if elapsed > int64(runMaxDuration.Seconds()) {
+			return ErrRunExpired
+		}
+		if elapsed < int64(runMinDuration.Seconds()) {
+			return ErrRunTooShort
+		}
+
+		var spawns []models.GameRunSpawn
+		if err := tx.Where("game_run_id = ?", run.ID).Order("id").Find(&spawns).Error; err != nil {
+			return err
+		}
+		spawnByMonster := make(map[uint]*models.GameRunSpawn, len(spawns))
+		for i := range spawns {
+			if spawns[i].Kind == models.GameRunSpawnMonster {
+				spawnByMonster[spawns[i].RefID] = &spawns[i]
+			}
+		}
+
+		// 击杀数不能超过本局刷新的数量
+		totalKills := 0
+		for _, kill := range kills {
+			spawn, ok := spawnByMonster[kill.MonsterID]
+			if !ok || kill.Count < 0 || spawn.Killed+kill.Count > spawn.Count {
+				return ErrRunKillsInvalid
+			}
+			spawn.Killed += kill.Count
+			totalKills += kill.Count
+		}
+		if int64(totalKills*runMinSecondsPerKill) > elapsed {
+			return ErrRunFinishedEarly
+		}
+
+		var monsters []models.Monster
+		if err := tx.Where("id IN ?", keysOf(spawnByMonster)).Order("id").Find(&monsters).Error; err != nil {
+			return err
+		}
+
+		var user models.User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, userID).Error; err != nil {
+			return err
+		}
+
+		// 掉落使用开局时的随机种子，同一局结果固定
+		rng := rand.New(rand.NewSource(run.Seed))
+		var drops []DropItem
+		for _, monster := range monsters {
+			spawn := spawnByMonster[monster.ID]
+			result.Gold += monster.GoldReward * spawn.Killed
+			result.Exp += monster.ExpReward * spawn.Killed
+
+			items, err := RollSourceDrops(tx, rng, models.DropSourceMonster, monster.ID, user.Level, spawn.Killed)
+			if err != nil {
+				return err
+			}
+			drops = append(drops, items...)
+		}
+		// 本局出现的场景物各掉落一次：至少击杀一只怪物才有场景物掉落，且每个场景物需要 runMinSecondsPerScene 秒
+		sceneBudget := elapsed / runMinSecondsPerScene
+		if totalKills == 0 {
+			sceneBudget = 0
+		}
+		for _, spawn := range spawns {
+			if spawn.Kind != models.GameRunSpawnScene || sceneBudget <= 0 {
+				continue
+			}
+			sceneBudget--
+			items, err := RollSourceDrops(tx, rng, models.DropSourceScene, spawn.RefID, user.Level, spawn.Count)
+			if err != nil {
+				return err
+			}
+			drops = append(drops, items...)
+		}
+
+		for _, spawn := range spawns {
+			if spawn.Killed == 0 {
+				continue
+			}
+			if err := tx.Model(&models.GameRunSpawn{}).Where("id = ?", spawn.ID).Update("killed", spawn.Killed).Error; err != nil {
+				return err
+			}
+		}
+
+		result.CurrentExp = user.Exp + result.Exp
+
+		if result.Gold > 0 {
+			if _, err := ChangeCurrency(tx, CurrencyChange{
+				UserID:     userID,
+				Currency:   models.CurrencyGold,
+				Delta:      result.Gold,
+				Reason:     models.CurrencyReasonRunReward,
+				SourceType: "game_run",
+				SourceID:   run.ID,
+			}); err != nil {
+				return err
+			}
+		}
+		if result.Exp > 0 {
+			if err := tx.Model(&models.User{}).Where("id = ?", userID).
+				Update("exp", gorm.Expr("exp + ?", result.Exp)).Error; err != nil {
+				return err
+			}
+		}
+
+		granted, err := GrantDrops(tx, userID, run.Area, drops, models.CurrencyReasonRunReward, "game_run", run.ID)
+		if err != nil {
+			return err
+		}
+		result.Drops = granted
+
+		// 基础金币和掉落金币都已入账，重新读取余额
+		if err := tx.First(&user, userID).Error; err != nil {
+			return err
+		}
+		result.CurrentGold = user.Gold
+
+		run.Status = models.GameRunStatusFinished
+		run.FinishedAt = now.Unix()
+		run.Kills = totalKills
+		run.GoldReward = result.Gold
+		run.ExpReward = result.Exp
+		if err := tx.Model(&run).Updates(map[string]interface{}{
+			"status":      run.Status,
+			"finished_at": run.FinishedAt,
+			"kills":       run.Kills,
+			"gold_reward": run.GoldReward,
+			"exp_reward":  run.ExpReward,
+		}).Error; err != nil {
+			return err
+		}
+		run.Spawns = spawns
+		result.Run = &run
+		return nil
+	})
+	if errors.Is(err, ErrRunExpired) {
+		s.DB.Model(&models.GameRun{}).Where("id = ? AND status = ?", runID, models.GameRunStatusActive).
+			Update("status", models.GameRunStatusExpired)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func keysOf(m map[uint]*models.GameRunSpawn) []uint {
+	keys := make([]uint, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	return keys
+}
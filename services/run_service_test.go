@@ -0,0 +1,111 @@
+package services
+
+import (
+	"errors"
+	"ggo/models"
+	"testing"
+	"time"
+)
+
+// 场景物挂载的掉落表保底掉落的金币
+const testSceneDropGold = 7
+
+func TestRunFinish(t *testing.T) {
+	tests := []struct {
+		name          string
+		elapsed       time.Duration
+		kills         int
+		wantErr       error
+		wantGold      int // 结算获得的金币（怪物基础金币 + 场景物掉落）
+		wantSceneDrop bool
+	}{
+		{name: "开局后立即结算且无击杀", elapsed: 0, kills: 0, wantErr: ErrRunTooShort},
+		{name: "不足最短时长但有击杀", elapsed: runMinDuration - 5*time.Second, kills: 1, wantErr: ErrRunTooShort},
+		{name: "无击杀不掉落场景物", elapsed: runMinDuration + 5*time.Second, kills: 0},
+		{name: "击杀数超过时长", elapsed: runMinDuration + 5*time.Second, kills: 30, wantErr: ErrRunFinishedEarly},
+		{name: "有击杀且时长足够", elapsed: runMinDuration + 5*time.Second, kills: 3, wantGold: 3*5 + testSceneDropGold, wantSceneDrop: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestDB(t)
+			user := createTestUser(t, db, "player", 100, 0)
+
+			monster := models.Monster{Name: "史莱姆", SpawnRate: 1, GoldReward: 5, ExpReward: 10, IsActive: true}
+			scene := models.Scene{Name: "宝箱", SpawnRate: 1, IsActive: true}
+			table := models.DropTable{Name: "宝箱掉落", Rolls: 1, IsActive: true, Entries: []models.DropTableEntry{
+				{Kind: models.DropKindGold, MinQty: testSceneDropGold, MaxQty: testSceneDropGold, Guaranteed: true},
+			}}
+			for _, record := range []interface{}{&monster, &scene, &table} {
+				if err := db.Create(record).Error; err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := db.Create(&models.DropTableBinding{DropTableID: table.ID, SourceType: models.DropSourceScene, SourceID: scene.ID}).Error; err != nil {
+				t.Fatal(err)
+			}
+
+			runs := NewRunService(db)
+			run, token, err := runs.Start(user.ID, 1, "")
+			if err != nil {
+				t.Fatalf("start: %v", err)
+			}
+			startedAt := time.Now().Add(-tt.elapsed).Unix()
+			db.Model(&models.GameRun{}).Where("id = ?", run.ID).Update("started_at", startedAt)
+
+			var kills []RunKill
+			if tt.kills > 0 {
+				kills = []RunKill{{MonsterID: monster.ID, Count: tt.kills}}
+			}
+			result, err := runs.Finish(user.ID, run.ID, token, kills)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+
+			gold, _ := userBalance(t, db, user.ID)
+			if gold != 100+tt.wantGold {
+				t.Fatalf("gold = %d, want %d", gold, 100+tt.wantGold)
+			}
+			assertLedgerBalanced(t, db)
+
+			var stored models.GameRun
+			if err := db.First(&stored, run.ID).Error; err != nil {
+				t.Fatal(err)
+			}
+			if tt.wantErr != nil {
+				// 未结算的对局保持进行中，不发放任何奖励
+				if stored.Status != models.GameRunStatusActive {
+					t.Fatalf("status = %s, want %s", stored.Status, models.GameRunStatusActive)
+				}
+				return
+			}
+			if stored.Status != models.GameRunStatusFinished || stored.Kills != tt.kills {
+				t.Fatalf("run = %+v", stored)
+			}
+			sceneDropped := false
+			for _, drop := range result.Drops {
+				if drop.Kind == models.DropKindGold && drop.Num == testSceneDropGold {
+					sceneDropped = true
+				}
+			}
+			if sceneDropped != tt.wantSceneDrop {
+				t.Fatalf("scene dropped = %v, want %v (drops %+v)", sceneDropped, tt.wantSceneDrop, result.Drops)
+			}
+		})
+	}
+}
+
+func TestRunStartRejectsParallelRun(t *testing.T) {
+	db := newTestDB(t)
+	user := createTestUser(t, db, "player", 0, 0)
+	if err := db.Create(&models.Monster{Name: "史莱姆", SpawnRate: 1, IsActive: true}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	runs := NewRunService(db)
+	if _, _, err := runs.Start(user.ID, 1, ""); err != nil {
+		t.Fatalf("first start: %v", err)
+	}
+	if _, _, err := runs.Start(user.ID, 1, ""); !errors.Is(err, ErrRunInProgress) {
+		t.Fatalf("second start err = %v, want %v", err, ErrRunInProgress)
+	}
+}
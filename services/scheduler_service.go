@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"ggo/database"
 	"ggo/models"
+	"log"
+	"math/rand"
 	"strconv"
 	"time"
 
@@ -92,6 +94,7 @@ func runDailyBossDamageRewards(db *gorm.DB, location *time.Location) {
 				Num:      diamond,
 				Status:   0,
 			})
+			mails = append(mails, bossDropMails(db, row.UserID, area, rank)...)
 		}
 
 		if len(mails) == 0 {
@@ -102,6 +105,45 @@ func runDailyBossDamageRewards(db *gorm.DB, location *time.Location) {
 	}
 }
 
+// bossDropMails 为上榜玩家抽取每日首领挂载的掉落表，每种掉落发一封邮件，装备每件单独一封
+func bossDropMails(db *gorm.DB, userID uint, area, rank int) []models.Mail {
+	var user models.User
+	if err := db.Select("id", "level").First(&user, userID).Error; err != nil {
+		return nil
+	}
+
+	rng := rand.New(rand.NewSource(time.Now().UnixNano() + int64(userID)))
+	items, err := RollSourceDrops(db, rng, models.DropSourceBoss, 0, user.Level, 1)
+	if err != nil {
+		log.Printf("抽取首领掉落失败: %v", err)
+		return nil
+	}
+
+	mails := make([]models.Mail, 0, len(items))
+	for _, item := range MergeDrops(items) {
+		mail := models.Mail{
+			UserID:   userID,
+			Area:     area,
+			Title:    "每日首领掉落",
+			Content:  fmt.Sprintf("您在今天的首领排行榜中排行第%d名，获得首领掉落。", rank),
+			ItemType: DropMailItemType(item.Kind),
+			ItemID:   item.RefID,
+			Num:      item.Num,
+			Status:   0,
+		}
+		if item.Kind != models.DropKindEquipment {
+			mails = append(mails, mail)
+			continue
+		}
+		// 邮件领取时每封只创建一件装备
+		mail.Num = 1
+		for i := 0; i < item.Num; i++ {
+			mails = append(mails, mail)
+		}
+	}
+	return mails
+}
+
 func listAreasForRewards(db *gorm.DB) ([]int, error) {
 	var areas []models.Area
 	if err := db.Select("area").Order("area asc").Find(&areas).Error; err == nil && len(areas) > 0 {
@@ -138,3 +180,69 @@ func rewardDiamondByRank(rank int) int {
 		return 0
 	}
 }
+
+// StartCurrencyReconcileScheduler 每天凌晨4点核对用户余额与货币流水，不一致的用户写入日志
+func StartCurrencyReconcileScheduler() {
+	if database.DB == nil {
+		return
+	}
+
+	location, err := time.LoadLocation("Asia/Shanghai")
+	if err != nil {
+		location = time.Local
+	}
+
+	go func() {
+		for {
+			now := time.Now().In(location)
+			next := time.Date(now.Year(), now.Month(), now.Day(), 4, 0, 0, 0, location)
+			if !next.After(now) {
+				next = next.AddDate(0, 0, 1)
+			}
+			time.Sleep(time.Until(next))
+
+			mismatches, err := ReconcileCurrencyLedger(database.DB)
+			if err != nil {
+				log.Println("Currency reconcile failed:", err)
+				continue
+			}
+			for _, m := range mismatches {
+				log.Printf("Currency mismatch: user_id=%d currency=%s balance=%d ledger_sum=%d", m.UserID, m.Currency, m.Balance, m.LedgerSum)
+			}
+		}
+	}()
+}
+
+// StartIdempotencyCleanupScheduler 每小时清理过期的幂等记录
+func StartIdempotencyCleanupScheduler() {
+	if database.DB == nil {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+		for range ticker.C {
+			if err := database.DB.Where("expires_at < ?", time.Now().Unix()).Delete(&models.IdempotencyRecord{}).Error; err != nil {
+				log.Println("Idempotency cleanup failed:", err)
+			}
+		}
+	}()
+}
+
+// StartMarketExpireScheduler 每分钟把到期的交易行寄售退回卖家
+func StartMarketExpireScheduler() {
+	if database.DB == nil {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+		for range ticker.C {
+			if _, err := ExpireMarketListings(database.DB); err != nil {
+				log.Println("Market expire failed:", err)
+			}
+		}
+	}()
+}
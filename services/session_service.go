@@ -0,0 +1,283 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"ggo/config"
+	"ggo/database"
+	"ggo/models"
+	"ggo/utils"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrInvalidRefreshToken = errors.New("刷新token无效")
+	ErrRefreshTokenReused  = errors.New("刷新token已被使用，会话已吊销，请重新登录")
+	ErrSessionExpired      = errors.New("会话已过期，请重新登录")
+	ErrSessionNotFound     = errors.New("会话不存在")
+)
+
+// ClientInfo 发起登录/刷新的客户端信息
+type ClientInfo struct {
+	IP         string
+	UserAgent  string
+	DeviceID   string
+	DeviceName string
+}
+
+// TokenPair 登录或刷新后下发的一组token
+type TokenPair struct {
+	AccessToken  string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int64  `json:"expires_in"` // 访问token剩余秒数
+	SessionID    string `json:"session_id"`
+}
+
+// SessionService 登录会话管理：访问token + 可轮换的刷新token
+type SessionService struct {
+	DB              *gorm.DB
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+	SingleSession   bool
+}
+
+func NewSessionService(db *gorm.DB, cfg *config.Config) *SessionService {
+	return &SessionService{
+		DB:              db,
+		AccessTokenTTL:  cfg.AccessTokenTTL,
+		RefreshTokenTTL: cfg.RefreshTokenTTL,
+		SingleSession:   cfg.SingleSession,
+	}
+}
+
+// SessionCacheKey 会话在Redis中的有效标记，JWTAuth据此判断会话是否已吊销
+func SessionCacheKey(sessionID string) string {
+	return fmt.Sprintf("auth:session:%s", sessionID)
+}
+
+// CreateSession 为用户新建登录会话；单设备模式下会先吊销该用户的其他会话
+func (s *SessionService) CreateSession(user *models.User, client ClientInfo) (*TokenPair, error) {
+	if s.SingleSession {
+		if err := s.RevokeAll(user.ID, ""); err != nil {
+			return nil, err
+		}
+	}
+
+	sessionID, err := utils.RandomToken(16)
+	if err != nil {
+		return nil, err
+	}
+	refreshToken, err := utils.GenerateRefreshToken()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	session := models.UserSession{
+		SessionID:        sessionID,
+		UserID:           user.ID,
+		RefreshTokenHash: utils.HashToken(refreshToken),
+		DeviceID:         client.DeviceID,
+		DeviceName:       client.DeviceName,
+		UserAgent:        truncate(client.UserAgent, 255),
+		IP:               client.IP,
+		LastSeenAt:       now.Unix(),
+		ExpiresAt:        now.Add(s.RefreshTokenTTL).Unix(),
+	}
+	if err := s.DB.Create(&session).Error; err != nil {
+		return nil, err
+	}
+
+	return s.issue(user, &session, refreshToken)
+}
+
+// Refresh 用刷新token换取新的访问token，同时轮换刷新token
+func (s *SessionService) Refresh(refreshToken string, client ClientInfo) (*TokenPair, error) {
+	hash := utils.HashToken(refreshToken)
+	now := time.Now()
+
+	var session models.UserSession
+	var user models.User
+	var newRefreshToken string
+
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("refresh_token_hash = ?", hash).First(&session)
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return ErrInvalidRefreshToken
+		}
+		if result.Error != nil {
+			return result.Error
+		}
+
+		if session.RevokedAt != 0 || session.ExpiresAt < now.Unix() {
+			return ErrSessionExpired
+		}
+
+		if err := tx.First(&user, session.UserID).Error; err != nil {
+			return err
+		}
+
+		var err error
+		newRefreshToken, err = utils.GenerateRefreshToken()
+		if err != nil {
+			return err
+		}
+
+		updates := map[string]interface{}{
+			"refresh_token_hash":    utils.HashToken(newRefreshToken),
+			"previous_refresh_hash": hash,
+			"last_seen_at":          now.Unix(),
+			"expires_at":            now.Add(s.RefreshTokenTTL).Unix(),
+		}
+		if client.IP != "" {
+			updates["ip"] = client.IP
+		}
+		if client.UserAgent != "" {
+			updates["user_agent"] = truncate(client.UserAgent, 255)
+		}
+		if err := tx.Model(&session).Updates(updates).Error; err != nil {
+			return err
+		}
+		session.ExpiresAt = now.Add(s.RefreshTokenTTL).Unix()
+		return nil
+	})
+	if errors.Is(err, ErrInvalidRefreshToken) {
+		// 已轮换掉的旧刷新token被再次使用，说明token可能泄露，吊销整个会话
+		var reused models.UserSession
+		if s.DB.Where("previous_refresh_hash = ? AND revoked_at = 0", hash).First(&reused).Error == nil {
+			if err := s.Revoke(reused.UserID, reused.SessionID); err != nil {
+				return nil, err
+			}
+			return nil, ErrRefreshTokenReused
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return s.issue(&user, &session, newRefreshToken)
+}
+
+// ListSessions 列出用户当前有效的会话
+func (s *SessionService) ListSessions(userID uint) ([]models.UserSession, error) {
+	var sessions []models.UserSession
+	err := s.DB.Where("user_id = ? AND revoked_at = 0 AND expires_at >= ?", userID, time.Now().Unix()).
+		Order("last_seen_at desc").Find(&sessions).Error
+	return sessions, err
+}
+
+// Revoke 吊销用户的指定会话
+func (s *SessionService) Revoke(userID uint, sessionID string) error {
+	result := s.DB.Model(&models.UserSession{}).
+		Where("user_id = ? AND session_id = ? AND revoked_at = 0", userID, sessionID).
+		Update("revoked_at", time.Now().Unix())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrSessionNotFound
+	}
+	dropSessionCache(sessionID)
+	return nil
+}
+
+// RevokeAll 吊销用户的全部会话，exceptSessionID 非空时保留该会话
+func (s *SessionService) RevokeAll(userID uint, exceptSessionID string) error {
+	query := s.DB.Model(&models.UserSession{}).Where("user_id = ? AND revoked_at = 0", userID)
+	if exceptSessionID != "" {
+		query = query.Where("session_id <> ?", exceptSessionID)
+	}
+
+	var sessionIDs []string
+	if err := query.Pluck("session_id", &sessionIDs).Error; err != nil {
+		return err
+	}
+	if len(sessionIDs) == 0 {
+		return nil
+	}
+
+	if err := s.DB.Model(&models.UserSession{}).Where("session_id IN ?", sessionIDs).
+		Update("revoked_at", time.Now().Unix()).Error; err != nil {
+		return err
+	}
+	for _, sessionID := range sessionIDs {
+		dropSessionCache(sessionID)
+	}
+	return nil
+}
+
+// issue 签发访问token并刷新Redis中的会话标记
+func (s *SessionService) issue(user *models.User, session *models.UserSession, refreshToken string) (*TokenPair, error) {
+	accessToken, err := utils.GenerateToken(user.ID, user.Username, session.SessionID, s.AccessTokenTTL)
+	if err != nil {
+		return nil, err
+	}
+
+	if database.RedisClient != nil {
+		ttl := time.Until(time.Unix(session.ExpiresAt, 0))
+		database.RedisClient.Set(context.Background(), SessionCacheKey(session.SessionID), user.ID, ttl)
+	}
+
+	return &TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int64(s.AccessTokenTTL.Seconds()),
+		SessionID:    session.SessionID,
+	}, nil
+}
+
+// IsSessionActive 判断访问token所属会话是否仍然有效（优先读Redis，缺失时回查数据库并回填）
+func IsSessionActive(userID uint, sessionID string) bool {
+	if sessionID == "" || database.RedisClient == nil {
+		return false
+	}
+
+	ctx := context.Background()
+	cached, err := database.RedisClient.Get(ctx, SessionCacheKey(sessionID)).Result()
+	if err == nil {
+		return cached == fmt.Sprint(userID)
+	}
+	if !errors.Is(err, redis.Nil) || database.DB == nil {
+		return false
+	}
+
+	var session models.UserSession
+	if err := database.DB.Where("session_id = ? AND user_id = ? AND revoked_at = 0 AND expires_at >= ?", sessionID, userID, time.Now().Unix()).
+		First(&session).Error; err != nil {
+		return false
+	}
+	database.RedisClient.Set(ctx, SessionCacheKey(sessionID), userID, time.Until(time.Unix(session.ExpiresAt, 0)))
+	return true
+}
+
+// TouchSession 更新会话的最近活跃时间和IP，每个会话5分钟内最多写一次库
+func TouchSession(sessionID, ip string) {
+	if sessionID == "" || database.RedisClient == nil || database.DB == nil {
+		return
+	}
+
+	ok, err := database.RedisClient.SetNX(context.Background(), "auth:session:seen:"+sessionID, 1, 5*time.Minute).Result()
+	if err != nil || !ok {
+		return
+	}
+	database.DB.Model(&models.UserSession{}).Where("session_id = ?", sessionID).
+		Updates(map[string]interface{}{"last_seen_at": time.Now().Unix(), "ip": ip})
+}
+
+func dropSessionCache(sessionID string) {
+	if database.RedisClient != nil {
+		database.RedisClient.Del(context.Background(), SessionCacheKey(sessionID))
+	}
+}
+
+func truncate(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	return s[:max]
+}
@@ -0,0 +1,409 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"ggo/models"
+	"hash/fnv"
+	"math/rand"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 神秘商店：每次刷新的商品数、定时刷新间隔、付费刷新消耗的钻石和每天的付费刷新次数
+const (
+	shopOfferCount        = 6
+	ShopRefreshInterval   = 4 * time.Hour
+	ShopRefreshDiamond    = 50
+	ShopMaxPaidRefreshes  = 10
+	shopEquipmentChance   = 0.4 // 每件商品为装备的概率，该品级没有对应商品时改用另一种
+	shopEquipmentPriceMul = 2   // 装备价格为同品级宝物的倍数
+)
+
+// 商品品级权重：品级越高出现概率越低
+var shopRarityWeights = map[int]int{
+	1: 400,
+	2: 300,
+	3: 150,
+	4: 100,
+	5: 40,
+	6: 10,
+}
+
+// 各品级宝物的价格，装备按 shopEquipmentPriceMul 加价
+var shopPrices = map[int]struct {
+	Currency string
+	Price    int
+}{
+	1: {models.CurrencyGold, 10000},
+	2: {models.CurrencyGold, 20000},
+	3: {models.CurrencyGold, 50000},
+	4: {models.CurrencyGold, 120000},
+	5: {models.CurrencyDiamond, 300},
+	6: {models.CurrencyDiamond, 800},
+}
+
+var (
+	ErrShopOfferNotFound  = errors.New("商品不存在")
+	ErrShopOfferPurchased = errors.New("该商品已购买")
+	ErrShopExpired        = errors.New("商店已刷新，请重新获取商品")
+	ErrShopRefreshLimit   = fmt.Errorf("今日付费刷新次数已用完（每天%d次）", ShopMaxPaidRefreshes)
+)
+
+// ShopBuyResult 神秘商店购买结果
+type ShopBuyResult struct {
+	Offer          *models.MysteryShopOffer `json:"offer"`
+	Item           *models.MyItem           `json:"item,omitempty"`      // 购买后背包中的宝物堆叠（treasure）
+	Equipment      *models.UserEquipment    `json:"equipment,omitempty"` // 购买的装备（equipment）
+	CurrentBalance int                      `json:"current_balance"`     // 购买后价格货币的余额
+}
+
+// ShopService 神秘商店：商品由服务端生成，购买时扣除金币或钻石并发放物品
+type ShopService struct {
+	DB *gorm.DB
+}
+
+func NewShopService(db *gorm.DB) *ShopService {
+	return &ShopService{DB: db}
+}
+
+// Get 获取神秘商店，首次访问或到了定时刷新时间时免费刷新
+func (s *ShopService) Get(userID uint, area int) (*models.MysteryShop, error) {
+	var shop *models.MysteryShop
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		shop, err = lockShop(tx, userID, area, time.Now(), true)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.load(shop.ID)
+}
+
+// Refresh 花费钻石立即刷新商品，不影响下次定时刷新的时间
+func (s *ShopService) Refresh(userID uint, area int) (*models.MysteryShop, int, error) {
+	var shop *models.MysteryShop
+	var currentDiamond int
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		var err error
+		shop, err = lockShop(tx, userID, area, now, true)
+		if err != nil {
+			return err
+		}
+
+		day := shopDay(now)
+		if shop.PaidRefreshDay != day {
+			shop.PaidRefreshDay = day
+			shop.PaidRefreshes = 0
+		}
+		if shop.PaidRefreshes >= ShopMaxPaidRefreshes {
+			return ErrShopRefreshLimit
+		}
+		diamondTx, err := ChangeCurrency(tx, CurrencyChange{
+			UserID:     userID,
+			Currency:   models.CurrencyDiamond,
+			Delta:      -ShopRefreshDiamond,
+			Reason:     models.CurrencyReasonShopRefresh,
+			SourceType: "mystery_shop",
+			SourceID:   shop.ID,
+		})
+		if err != nil {
+			return err
+		}
+		currentDiamond = diamondTx.BalanceAfter
+
+		shop.PaidRefreshes++
+		return refreshShop(tx, shop, now)
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	loaded, err := s.load(shop.ID)
+	if err != nil {
+		return nil, 0, err
+	}
+	return loaded, currentDiamond, nil
+}
+
+// Buy 购买当前商品：扣除金币或钻石，宝物放入背包，装备按概率带隐藏词条；
+// 商店已到定时刷新时间时返回 ErrShopExpired，背包放不下时购买失败、不扣款
+func (s *ShopService) Buy(userID uint, area int, offerID uint) (*ShopBuyResult, error) {
+	result := &ShopBuyResult{}
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		shop, err := lockShop(tx, userID, area, now, false)
+		if err != nil {
+			return err
+		}
+
+		var offer models.MysteryShopOffer
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND shop_id = ?", offerID, shop.ID).First(&offer).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrShopOfferNotFound
+			}
+			return err
+		}
+		if offer.Purchased {
+			return ErrShopOfferPurchased
+		}
+
+		paid, err := ChangeCurrency(tx, CurrencyChange{
+			UserID:     userID,
+			Currency:   offer.Currency,
+			Delta:      -offer.Price,
+			Reason:     models.CurrencyReasonShopBuy,
+			SourceType: "mystery_shop_offer",
+			SourceID:   offer.ID,
+		})
+		if err != nil {
+			return err
+		}
+		result.CurrentBalance = paid.BalanceAfter
+
+		switch offer.Kind {
+		case models.ShopOfferTreasure:
+			item, err := AddMyItem(tx, userID, models.MyItemTypeTreasure, offer.RefID, models.PositionBackpack, 1)
+			if err != nil {
+				return err
+			}
+			result.Item = item
+		case models.ShopOfferEquipment:
+			equipment, err := grantShopEquipment(tx, userID, offer)
+			if err != nil {
+				return err
+			}
+			result.Equipment = equipment
+		default:
+			return fmt.Errorf("未知商品类型: %s", offer.Kind)
+		}
+
+		offer.Purchased = true
+		offer.PurchasedAt = now.Unix()
+		if err := tx.Model(&models.MysteryShopOffer{}).Where("id = ?", offer.ID).Updates(map[string]interface{}{
+			"purchased":    offer.Purchased,
+			"purchased_at": offer.PurchasedAt,
+		}).Error; err != nil {
+			return err
+		}
+		result.Offer = &offer
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// grantShopEquipment 发放购买的装备到背包，背包已满时返回 ErrInventoryFull
+func grantShopEquipment(tx *gorm.DB, userID uint, offer models.MysteryShopOffer) (*models.UserEquipment, error) {
+	free, err := FreeSlots(tx, userID, models.PositionBackpack)
+	if err != nil {
+		return nil, err
+	}
+	if free <= 0 {
+		return nil, fmt.Errorf("%w：背包", ErrInventoryFull)
+	}
+	registry, err := GetAffixRegistry(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+	equipment := models.UserEquipment{
+		UserID:        userID,
+		EquipmentID:   offer.RefID,
+		Position:      models.PositionBackpack,
+		HiddenAffixes: registry.RollHiddenAffixes(rng, offer.Level),
+	}
+	if err := tx.Create(&equipment).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.Preload("EquipmentTemplate").Preload("AdditionalAttrs").First(&equipment, equipment.ID).Error; err != nil {
+		return nil, err
+	}
+	return &equipment, nil
+}
+
+// lockShop 锁定玩家并取出神秘商店，不存在时创建；到了定时刷新时间时，autoRefresh 为 true 则刷新，
+// 否则返回 ErrShopExpired（购买时不能买到已过期的商品）
+func lockShop(tx *gorm.DB, userID uint, area int, now time.Time, autoRefresh bool) (*models.MysteryShop, error) {
+	// 锁定玩家，使同一玩家的商店创建、刷新和购买串行执行
+	var user models.User
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&user, userID).Error; err != nil {
+		return nil, err
+	}
+
+	var shop models.MysteryShop
+	err := tx.Where("user_id = ? AND area = ?", userID, area).First(&shop).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		shop = models.MysteryShop{UserID: userID, Area: area}
+		if err := scheduleShopRefresh(tx, &shop, now); err != nil {
+			return nil, err
+		}
+		return &shop, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if now.Unix() >= shop.NextRefreshAt {
+		if !autoRefresh {
+			return nil, ErrShopExpired
+		}
+		if err := scheduleShopRefresh(tx, &shop, now); err != nil {
+			return nil, err
+		}
+	}
+	return &shop, nil
+}
+
+// scheduleShopRefresh 定时刷新：刷新商品，并把下次刷新时间对齐到下一个刷新间隔的整点
+func scheduleShopRefresh(tx *gorm.DB, shop *models.MysteryShop, now time.Time) error {
+	interval := int64(ShopRefreshInterval / time.Second)
+	shop.NextRefreshAt = (now.Unix()/interval + 1) * interval
+	return refreshShop(tx, shop, now)
+}
+
+// refreshShop 以新的种子重新生成商品，整体替换旧商品
+func refreshShop(tx *gorm.DB, shop *models.MysteryShop, now time.Time) error {
+	shop.RefreshCount++
+	shop.RefreshedAt = now.Unix()
+	shop.Seed = shopSeed(shop.UserID, shop.Area, shop.RefreshCount, now.UnixNano())
+	offers, err := GenerateShopOffers(tx, shop.Seed)
+	if err != nil {
+		return err
+	}
+
+	shop.Offers = nil
+	if shop.ID == 0 {
+		if err := tx.Create(shop).Error; err != nil {
+			return err
+		}
+	} else {
+		if err := tx.Save(shop).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("shop_id = ?", shop.ID).Delete(&models.MysteryShopOffer{}).Error; err != nil {
+			return err
+		}
+	}
+	if len(offers) == 0 {
+		return nil
+	}
+	for i := range offers {
+		offers[i].ShopID = shop.ID
+	}
+	return tx.Create(&offers).Error
+}
+
+// shopSeed 每个玩家每次刷新的随机种子
+func shopSeed(userID uint, area, refreshCount int, nonce int64) int64 {
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%d:%d:%d:%d", userID, area, refreshCount, nonce)
+	return int64(h.Sum64())
+}
+
+// GenerateShopOffers 用种子生成一组商品：先按权重抽品级，再按概率选宝物或装备，最后在该品级的
+// 激活模板中等概率抽取。商品模板不变时，同一种子总是生成相同的商品
+func GenerateShopOffers(db *gorm.DB, seed int64) ([]models.MysteryShopOffer, error) {
+	var treasures []models.Treasure
+	if err := db.Where("is_active = ?", true).Order("id").Find(&treasures).Error; err != nil {
+		return nil, err
+	}
+	var templates []models.EquipmentTemplate
+	if err := db.Where("is_active = ?", true).Order("id").Find(&templates).Error; err != nil {
+		return nil, err
+	}
+
+	treasuresByLevel := make(map[int][]models.Treasure)
+	for _, treasure := range treasures {
+		treasuresByLevel[treasure.Level] = append(treasuresByLevel[treasure.Level], treasure)
+	}
+	templatesByLevel := make(map[int][]models.EquipmentTemplate)
+	for _, tpl := range templates {
+		templatesByLevel[tpl.Level] = append(templatesByLevel[tpl.Level], tpl)
+	}
+
+	// 只在有商品的品级中按权重抽取，按品级排序保证同一种子的结果稳定
+	var levels []int
+	totalWeight := 0
+	for level, weight := range shopRarityWeights {
+		if weight > 0 && len(treasuresByLevel[level])+len(templatesByLevel[level]) > 0 {
+			levels = append(levels, level)
+			totalWeight += weight
+		}
+	}
+	sort.Ints(levels)
+	if totalWeight == 0 {
+		return nil, nil
+	}
+
+	rng := rand.New(rand.NewSource(seed))
+	offers := make([]models.MysteryShopOffer, 0, shopOfferCount)
+	for slot := 0; slot < shopOfferCount; slot++ {
+		level := levels[len(levels)-1]
+		roll := rng.Intn(totalWeight)
+		for _, l := range levels {
+			if roll < shopRarityWeights[l] {
+				level = l
+				break
+			}
+			roll -= shopRarityWeights[l]
+		}
+
+		price := shopPrices[level]
+		offer := models.MysteryShopOffer{Slot: slot, Level: level, Currency: price.Currency, Price: price.Price}
+		pickEquipment := rng.Float64() < shopEquipmentChance
+		if len(templatesByLevel[level]) == 0 {
+			pickEquipment = false
+		} else if len(treasuresByLevel[level]) == 0 {
+			pickEquipment = true
+		}
+		if pickEquipment {
+			pool := templatesByLevel[level]
+			tpl := pool[rng.Intn(len(pool))]
+			offer.Kind = models.ShopOfferEquipment
+			offer.RefID = tpl.ID
+			offer.Name = tpl.Name
+			offer.ImageURL = tpl.ImageURL
+			offer.Price *= shopEquipmentPriceMul
+		} else {
+			pool := treasuresByLevel[level]
+			treasure := pool[rng.Intn(len(pool))]
+			offer.Kind = models.ShopOfferTreasure
+			offer.RefID = treasure.ID
+			offer.Name = treasure.Name
+			offer.ImageURL = treasure.ImageURL
+		}
+		offers = append(offers, offer)
+	}
+	return offers, nil
+}
+
+// shopDay 付费刷新次数按北京时间的自然日重置
+func shopDay(now time.Time) string {
+	location, err := time.LoadLocation("Asia/Shanghai")
+	if err != nil {
+		location = time.Local
+	}
+	return now.In(location).Format("20060102")
+}
+
+func (s *ShopService) load(shopID uint) (*models.MysteryShop, error) {
+	var shop models.MysteryShop
+	if err := s.DB.Preload("Offers", func(db *gorm.DB) *gorm.DB {
+		return db.Order("slot")
+	}).First(&shop, shopID).Error; err != nil {
+		return nil, err
+	}
+	if shop.PaidRefreshDay != shopDay(time.Now()) {
+		shop.PaidRefreshes = 0
+	}
+	return &shop, nil
+}
@@ -0,0 +1,231 @@
+package services
+
+import (
+	"errors"
+	"ggo/models"
+	"reflect"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+// shopFixture 只有1级宝物和1级装备模板，玩家有30000金币
+type shopFixture struct {
+	db       *gorm.DB
+	shop     *ShopService
+	user     *models.User
+	treasure models.Treasure
+	template models.EquipmentTemplate
+}
+
+func newShopFixture(t *testing.T) *shopFixture {
+	t.Helper()
+	db := newTestDB(t)
+	f := &shopFixture{db: db, shop: NewShopService(db), user: createTestUser(t, db, "player", 30000, 0)}
+	f.treasure = models.Treasure{Name: "铜钱", Level: 1, MaxStack: 9999, IsActive: true}
+	if err := db.Create(&f.treasure).Error; err != nil {
+		t.Fatal(err)
+	}
+	f.template = models.EquipmentTemplate{Name: "布衣", Level: 1, Slot: "chest", IsActive: true}
+	if err := db.Create(&f.template).Error; err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+// offer 打开商店并把第一件商品改为指定类型，返回该商品
+func (f *shopFixture) offer(t *testing.T, kind string) models.MysteryShopOffer {
+	t.Helper()
+	shop, err := f.shop.Get(f.user.ID, 1)
+	if err != nil {
+		t.Fatalf("get shop: %v", err)
+	}
+	if len(shop.Offers) != shopOfferCount {
+		t.Fatalf("offers = %d, want %d", len(shop.Offers), shopOfferCount)
+	}
+	offer := shop.Offers[0]
+	offer.Kind = kind
+	offer.RefID = f.treasure.ID
+	offer.Price = shopPrices[1].Price
+	if kind == models.ShopOfferEquipment {
+		offer.RefID = f.template.ID
+		offer.Price *= shopEquipmentPriceMul
+	}
+	if err := f.db.Save(&offer).Error; err != nil {
+		t.Fatal(err)
+	}
+	return offer
+}
+
+func TestGenerateShopOffersIsDeterministic(t *testing.T) {
+	f := newShopFixture(t)
+	first, err := GenerateShopOffers(f.db, 42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := GenerateShopOffers(f.db, 42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(first, second) {
+		t.Fatalf("same seed generated different offers:\n%+v\n%+v", first, second)
+	}
+	for _, offer := range first {
+		if offer.Level != 1 || offer.Currency != models.CurrencyGold {
+			t.Fatalf("offer = %+v", offer)
+		}
+	}
+}
+
+func TestShopBuy(t *testing.T) {
+	tests := []struct {
+		name string
+		kind string
+	}{
+		{name: "宝物", kind: models.ShopOfferTreasure},
+		{name: "装备", kind: models.ShopOfferEquipment},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newShopFixture(t)
+			offer := f.offer(t, tt.kind)
+
+			result, err := f.shop.Buy(f.user.ID, 1, offer.ID)
+			if err != nil {
+				t.Fatalf("buy: %v", err)
+			}
+			gold, _ := userBalance(t, f.db, f.user.ID)
+			if gold != 30000-offer.Price || result.CurrentBalance != gold {
+				t.Fatalf("gold = %d (result %d), want %d", gold, result.CurrentBalance, 30000-offer.Price)
+			}
+			assertLedgerBalanced(t, f.db)
+			if !result.Offer.Purchased {
+				t.Fatalf("offer not marked purchased: %+v", result.Offer)
+			}
+
+			switch tt.kind {
+			case models.ShopOfferTreasure:
+				if result.Item == nil || result.Item.ItemID != f.treasure.ID || result.Item.Quantity != 1 {
+					t.Fatalf("item = %+v", result.Item)
+				}
+			case models.ShopOfferEquipment:
+				if result.Equipment == nil || result.Equipment.EquipmentID != f.template.ID ||
+					result.Equipment.UserID != f.user.ID || result.Equipment.Position != models.PositionBackpack {
+					t.Fatalf("equipment = %+v", result.Equipment)
+				}
+			}
+
+			// 同一商品只能购买一次，第二次不扣款
+			if _, err := f.shop.Buy(f.user.ID, 1, offer.ID); !errors.Is(err, ErrShopOfferPurchased) {
+				t.Fatalf("second buy err = %v, want %v", err, ErrShopOfferPurchased)
+			}
+			if again, _ := userBalance(t, f.db, f.user.ID); again != gold {
+				t.Fatalf("gold after second buy = %d, want %d", again, gold)
+			}
+		})
+	}
+}
+
+func TestShopBuyFailures(t *testing.T) {
+	tests := []struct {
+		name    string
+		kind    string
+		area    int
+		setup   func(t *testing.T, f *shopFixture, offer *models.MysteryShopOffer)
+		wantErr error
+	}{
+		{
+			name: "金币不足",
+			area: 1,
+			kind: models.ShopOfferTreasure,
+			setup: func(t *testing.T, f *shopFixture, offer *models.MysteryShopOffer) {
+				f.db.Model(offer).Update("price", 30001)
+			},
+			wantErr: ErrInsufficientGold,
+		},
+		{
+			name: "商店已到刷新时间",
+			area: 1,
+			kind: models.ShopOfferTreasure,
+			setup: func(t *testing.T, f *shopFixture, offer *models.MysteryShopOffer) {
+				f.db.Model(&models.MysteryShop{}).Where("id = ?", offer.ShopID).Update("next_refresh_at", 1)
+			},
+			wantErr: ErrShopExpired,
+		},
+		{
+			name: "其他区服的商品",
+			area: 2,
+			kind: models.ShopOfferTreasure,
+			setup: func(t *testing.T, f *shopFixture, offer *models.MysteryShopOffer) {
+				if _, err := f.shop.Get(f.user.ID, 2); err != nil {
+					t.Fatal(err)
+				}
+			},
+			wantErr: ErrShopOfferNotFound,
+		},
+		{
+			name: "背包已满",
+			area: 1,
+			kind: models.ShopOfferEquipment,
+			setup: func(t *testing.T, f *shopFixture, offer *models.MysteryShopOffer) {
+				f.db.Model(&models.User{}).Where("id = ?", f.user.ID).Update("backpack_capacity", 0)
+			},
+			wantErr: ErrInventoryFull,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newShopFixture(t)
+			offer := f.offer(t, tt.kind)
+			tt.setup(t, f, &offer)
+
+			if _, err := f.shop.Buy(f.user.ID, tt.area, offer.ID); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if gold, _ := userBalance(t, f.db, f.user.ID); gold != 30000 {
+				t.Fatalf("gold = %d, want 30000", gold)
+			}
+			assertLedgerBalanced(t, f.db)
+
+			var purchased models.MysteryShopOffer
+			if err := f.db.First(&purchased, offer.ID).Error; err != nil {
+				t.Fatal(err)
+			}
+			if purchased.Purchased {
+				t.Fatal("failed purchase marked the offer as purchased")
+			}
+			var items, equipments int64
+			f.db.Model(&models.MyItem{}).Where("user_id = ?", f.user.ID).Count(&items)
+			f.db.Model(&models.UserEquipment{}).Where("user_id = ?", f.user.ID).Count(&equipments)
+			if items != 0 || equipments != 0 {
+				t.Fatalf("granted %d items and %d equipments on failure", items, equipments)
+			}
+		})
+	}
+}
+
+func TestShopRefresh(t *testing.T) {
+	f := newShopFixture(t)
+	f.db.Model(&models.User{}).Where("id = ?", f.user.ID).Update("diamond", ShopRefreshDiamond)
+	// 绕过账本直接改的余额补一条流水
+	f.db.Create(&models.CurrencyTransaction{UserID: f.user.ID, Currency: models.CurrencyDiamond,
+		Delta: ShopRefreshDiamond, BalanceAfter: ShopRefreshDiamond, Reason: models.CurrencyReasonMailClaim})
+
+	before, err := f.shop.Get(f.user.ID, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	refreshed, diamond, err := f.shop.Refresh(f.user.ID, 1)
+	if err != nil {
+		t.Fatalf("refresh: %v", err)
+	}
+	if diamond != 0 || refreshed.PaidRefreshes != 1 || refreshed.RefreshCount != before.RefreshCount+1 ||
+		refreshed.NextRefreshAt != before.NextRefreshAt {
+		t.Fatalf("refreshed = %+v, diamond = %d", refreshed, diamond)
+	}
+	assertLedgerBalanced(t, f.db)
+
+	if _, _, err := f.shop.Refresh(f.user.ID, 1); !errors.Is(err, ErrInsufficientDiamond) {
+		t.Fatalf("refresh without diamond err = %v, want %v", err, ErrInsufficientDiamond)
+	}
+}
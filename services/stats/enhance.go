@@ -0,0 +1,73 @@
+package stats
+
+import "ggo/models"
+
+// EnhanceCurve 某品级装备的强化曲线
+type EnhanceCurve struct {
+	PerLevel float64 `json:"per_level"` // 每级对基础生命值和攻击力的加成
+	MaxLevel int     `json:"max_level"` // 最高强化等级
+}
+
+// 各品级的强化曲线：品级越高每级加成越多、可强化的等级越高
+var enhanceCurves = map[int]EnhanceCurve{
+	1: {PerLevel: 0.03, MaxLevel: 10}, // 普通
+	2: {PerLevel: 0.04, MaxLevel: 12}, // 稀有
+	3: {PerLevel: 0.05, MaxLevel: 15}, // 史诗
+	4: {PerLevel: 0.06, MaxLevel: 18}, // 传说
+	5: {PerLevel: 0.07, MaxLevel: 20}, // 神话
+	6: {PerLevel: 0.08, MaxLevel: 25}, // 创世
+}
+
+// EquipmentStats 单件装备强化后的基础属性
+type EquipmentStats struct {
+	EnhanceLevel int     `json:"enhance_level"` // 强化等级
+	Multiplier   float64 `json:"multiplier"`    // 强化倍率
+	HP           int     `json:"hp"`            // 强化后生命值
+	Attack       int     `json:"attack"`        // 强化后攻击力
+}
+
+// EnhanceCurveFor 获取品级对应的强化曲线，未知品级按普通处理
+func EnhanceCurveFor(rarity int) EnhanceCurve {
+	if curve, ok := enhanceCurves[rarity]; ok {
+		return curve
+	}
+	return enhanceCurves[1]
+}
+
+// MaxEnhanceLevel 品级对应的最高强化等级
+func MaxEnhanceLevel(rarity int) int {
+	return EnhanceCurveFor(rarity).MaxLevel
+}
+
+// EnhanceMultiplier 强化等级对装备基础生命值和攻击力的倍率，超出上限的等级按上限计算
+func EnhanceMultiplier(rarity, level int) float64 {
+	curve := EnhanceCurveFor(rarity)
+	if level <= 0 {
+		return 1
+	}
+	if level > curve.MaxLevel {
+		level = curve.MaxLevel
+	}
+	return 1 + float64(level)*curve.PerLevel
+}
+
+// EquipmentBase 计算装备在指定强化等级下的基础属性
+func EquipmentBase(eq *models.UserEquipment, level int) EquipmentStats {
+	multiplier := EnhanceMultiplier(eq.EquipmentTemplate.Level, level)
+	return EquipmentStats{
+		EnhanceLevel: level,
+		Multiplier:   multiplier,
+		HP:           int(float64(eq.EquipmentTemplate.HP) * multiplier),
+		Attack:       int(float64(eq.EquipmentTemplate.Attack) * multiplier),
+	}
+}
+
+// EnhancedHP 强化后的装备基础生命值
+func EnhancedHP(eq *models.UserEquipment) int {
+	return EquipmentBase(eq, eq.EnhanceLevel).HP
+}
+
+// EnhancedAttack 强化后的装备基础攻击力
+func EnhancedAttack(eq *models.UserEquipment) int {
+	return EquipmentBase(eq, eq.EnhanceLevel).Attack
+}
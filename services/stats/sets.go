@@ -0,0 +1,76 @@
+package stats
+
+import (
+	"ggo/models"
+
+	"gorm.io/gorm"
+)
+
+// SetStatus 套装穿戴进度
+type SetStatus struct {
+	SetID    uint             `json:"set_id"`   // 套装ID
+	Name     string           `json:"name"`     // 套装名称
+	Equipped int              `json:"equipped"` // 已穿戴件数
+	Total    int              `json:"total"`    // 套装总件数
+	Bonuses  []SetBonusStatus `json:"bonuses"`
+}
+
+// SetBonusStatus 套装加成及是否已生效
+type SetBonusStatus struct {
+	Pieces   int     `json:"pieces"`    // 需要的件数
+	AttrType string  `json:"attr_type"` // 属性类型
+	Value    float64 `json:"value"`     // 数值
+	Active   bool    `json:"active"`    // 是否已生效
+}
+
+// LoadSets 读取包含指定装备模板的启用套装（含全部模板和加成）
+func LoadSets(db *gorm.DB, templateIDs []uint) ([]models.EquipmentSet, error) {
+	var sets []models.EquipmentSet
+	if len(templateIDs) == 0 {
+		return sets, nil
+	}
+	err := db.Where("is_active = ? AND id IN (?)", true,
+		db.Model(&models.EquipmentSetItem{}).Select("equipment_set_id").Where("equipment_template_id IN ?", templateIDs)).
+		Preload("Items").
+		Preload("Bonuses", func(db *gorm.DB) *gorm.DB { return db.Order("pieces, id") }).
+		Order("id").
+		Find(&sets).Error
+	return sets, err
+}
+
+// SetProgress 按已穿戴的装备模板计算每个套装的进度，同一模板只计一件
+func SetProgress(sets []models.EquipmentSet, equippedTemplateIDs []uint) []SetStatus {
+	equipped := make(map[uint]bool, len(equippedTemplateIDs))
+	for _, id := range equippedTemplateIDs {
+		equipped[id] = true
+	}
+
+	statuses := make([]SetStatus, 0, len(sets))
+	for _, set := range sets {
+		status := SetStatus{SetID: set.ID, Name: set.Name, Total: len(set.Items)}
+		for _, item := range set.Items {
+			if equipped[item.EquipmentTemplateID] {
+				status.Equipped++
+			}
+		}
+		for _, bonus := range set.Bonuses {
+			status.Bonuses = append(status.Bonuses, SetBonusStatus{
+				Pieces:   bonus.Pieces,
+				AttrType: bonus.AttrType,
+				Value:    bonus.Value,
+				Active:   status.Equipped >= bonus.Pieces,
+			})
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+// templateIDs 装备对应的模板ID
+func templateIDs(equipments []models.UserEquipment) []uint {
+	ids := make([]uint, 0, len(equipments))
+	for _, eq := range equipments {
+		ids = append(ids, eq.EquipmentID)
+	}
+	return ids
+}
@@ -0,0 +1,305 @@
+// Package stats 玩家属性计算：存档基础属性、已穿戴装备（含强化和词条）、套装加成、启用的皮肤，
+// 按固定顺序汇总为 PlayerStats 并计算战力。所有展示属性或按战力排行的接口都应使用这里的结果。
+package stats
+
+import (
+	"errors"
+	"ggo/models"
+	"math"
+
+	"gorm.io/gorm"
+)
+
+// 战力权重
+const (
+	powerDPSWeight      = 2.0  // 每点秒伤
+	powerEHPWeight      = 0.5  // 每点有效生命
+	powerRecoveryWeight = 5.0  // 每点生命恢复
+	powerPercentWeight  = 10.0 // 吸血、闪避、秒杀每1%
+	maxDamageReduction  = 75.0 // 计算有效生命时减伤上限（%）
+)
+
+// PlayerStats 玩家最终属性
+type PlayerStats struct {
+	HP              int     `json:"hp"`               // 生命值
+	Attack          int     `json:"attack"`           // 攻击力
+	AttackSpeed     float64 `json:"attack_speed"`     // 攻击速度
+	MoveSpeed       int     `json:"move_speed"`       // 移动速度（%）
+	BulletSpeed     int     `json:"bullet_speed"`     // 子弹速度（%）
+	Drain           int     `json:"drain"`            // 吸血（%）
+	Critical        int     `json:"critical"`         // 暴击值
+	Dodge           int     `json:"dodge"`            // 闪避（%）
+	InstantKill     int     `json:"instant_kill"`     // 秒杀几率（%）
+	Recovery        int     `json:"recovery"`         // 生命恢复
+	Trajectory      int     `json:"trajectory"`       // 额外弹道数
+	CriticalRate    float64 `json:"critical_rate"`    // 暴击率（0-1）
+	CriticalDamage  float64 `json:"critical_damage"`  // 暴击伤害（倍数）
+	DamageReduction float64 `json:"damage_reduction"` // 减伤（%）
+	AtkType         int     `json:"atk_type"`         // 攻击类型（来自皮肤）
+	CombatPower     int     `json:"combat_power"`     // 战力
+}
+
+// Input 计算属性所需的数据
+type Input struct {
+	Base       map[string]interface{} // 存档中的 base_attributes，可为空
+	Equipments []models.UserEquipment // 已穿戴的装备（需预加载 EquipmentTemplate 和 AdditionalAttrs）
+	Sets       []models.EquipmentSet  // 已穿戴装备所属的套装（需预加载 Items 和 Bonuses），可为空
+	Skin       *models.Skin           // 启用的皮肤，可为空
+}
+
+// percentBonus 百分比阶段的加成（%），在全部固定值累加完成后生效
+type percentBonus struct {
+	hp          float64
+	attack      float64
+	attackSpeed float64
+}
+
+// Compute 按顺序计算属性：
+// 1. 存档基础属性  2. 装备基础属性（按品级强化曲线加成）  3. 装备词条和套装加成固定值  4. 皮肤
+// 5. 百分比加成（生命、攻击、攻速）  6. 战力
+func Compute(in Input) PlayerStats {
+	s := baseStats(in.Base)
+	var pct percentBonus
+
+	for i := range in.Equipments {
+		eq := &in.Equipments[i]
+		tpl := eq.EquipmentTemplate
+		s.HP += EnhancedHP(eq)
+		s.Attack += EnhancedAttack(eq)
+		// 模板攻速默认1.0，表示不加成
+		s.AttackSpeed += tpl.AttackSpeed - 1
+		s.MoveSpeed += tpl.MoveSpeed
+		s.BulletSpeed += tpl.BulletSpeed
+		s.Drain += tpl.Drain
+		s.Critical += tpl.Critical
+		s.Dodge += tpl.Dodge
+		s.InstantKill += tpl.InstantKill
+		s.Recovery += tpl.Recovery
+		s.Trajectory += tpl.Trajectory
+	}
+
+	for i := range in.Equipments {
+		for _, attr := range in.Equipments[i].AdditionalAttrs {
+			applyAffix(&s, &pct, attr.AttrType, attr.Value)
+		}
+	}
+
+	for _, set := range SetProgress(in.Sets, templateIDs(in.Equipments)) {
+		for _, bonus := range set.Bonuses {
+			if bonus.Active {
+				applyAffix(&s, &pct, bonus.AttrType, bonus.Value)
+			}
+		}
+	}
+
+	if in.Skin != nil {
+		s.HP += in.Skin.HP
+		s.Attack += in.Skin.Attack
+		s.AttackSpeed += float64(in.Skin.AtkSpeed)
+		s.CriticalRate += in.Skin.CriticalRate
+		s.CriticalDamage += in.Skin.CriticalDamage
+		if in.Skin.AtkType > 0 {
+			s.AtkType = in.Skin.AtkType
+		}
+	}
+
+	s.HP = int(float64(s.HP) * (1 + pct.hp/100))
+	s.Attack = int(float64(s.Attack) * (1 + pct.attack/100))
+	s.AttackSpeed *= 1 + pct.attackSpeed/100
+
+	s.CombatPower = CombatPower(s)
+	return s
+}
+
+// AffixTypes 属性计算支持的词条和套装加成属性类型；词条定义和套装加成只能使用这些类型，
+// 否则会在界面上展示却不生效。新增类型时需要同时在 applyAffix 中实现
+var AffixTypes = []string{
+	"hp", "attack", "attack_speed", "move_speed", "bullet_speed", "drain", "critical", "dodge",
+	"instant_kill", "recovery", "trajectory", "damage_reduction", "critical_rate", "hp_pct", "attack_pct",
+	"greed", "lust", "wrath", "envy", "sloth", "pride", "gluttony",
+}
+
+// IsAffixType 判断属性类型是否能参与属性计算
+func IsAffixType(attrType string) bool {
+	for _, t := range AffixTypes {
+		if t == attrType {
+			return true
+		}
+	}
+	return false
+}
+
+// applyAffix 累加一条词条或套装加成：固定值直接累加，百分比加成记入 pct；不支持的类型返回 false
+func applyAffix(s *PlayerStats, pct *percentBonus, attrType string, v float64) bool {
+	switch attrType {
+	case "hp":
+		s.HP += int(v)
+	case "attack":
+		s.Attack += int(v)
+	case "attack_speed":
+		s.AttackSpeed += v
+	case "move_speed":
+		s.MoveSpeed += int(v)
+	case "bullet_speed":
+		s.BulletSpeed += int(v)
+	case "drain":
+		s.Drain += int(v)
+	case "critical":
+		s.Critical += int(v)
+	case "dodge":
+		s.Dodge += int(v)
+	case "instant_kill", "greed": // 贪婪：秒杀几率
+		s.InstantKill += int(v)
+	case "recovery", "lust": // 色欲：生命恢复
+		s.Recovery += int(v)
+	case "trajectory":
+		s.Trajectory += int(v)
+	case "damage_reduction":
+		s.DamageReduction += v
+	case "critical_rate", "wrath": // 暴怒：暴击率
+		s.CriticalRate += v / 100
+	case "envy": // 嫉妒：暴击伤害提升
+		s.CriticalDamage += v / 100
+	case "hp_pct", "sloth": // 傲慢：最大HP提升
+		pct.hp += v
+	case "attack_pct", "pride": // 怠惰：攻击力提升
+		pct.attack += v
+	case "gluttony": // 暴食：攻速提升
+		pct.attackSpeed += v
+	default:
+		return false
+	}
+	return true
+}
+
+// baseStats 读取存档 base_attributes，缺失的字段使用默认值
+func baseStats(base map[string]interface{}) PlayerStats {
+	s := PlayerStats{AttackSpeed: 1.0, CriticalDamage: 1.5}
+	if base == nil {
+		return s
+	}
+	s.HP = int(number(base, "hp", 0))
+	s.Attack = int(number(base, "attack", 0))
+	s.AttackSpeed = number(base, "attack_speed", s.AttackSpeed)
+	s.MoveSpeed = int(number(base, "move_speed", 0))
+	s.BulletSpeed = int(number(base, "bullet_speed", 0))
+	s.Drain = int(number(base, "drain", 0))
+	s.Critical = int(number(base, "critical", 0))
+	s.Dodge = int(number(base, "dodge", 0))
+	s.InstantKill = int(number(base, "instant_kill", 0))
+	s.Recovery = int(number(base, "recovery", 0))
+	s.CriticalRate = number(base, "critical_rate", 0)
+	s.CriticalDamage = number(base, "critical_damage", s.CriticalDamage)
+	s.DamageReduction = number(base, "damage_reduction", 0)
+	s.AtkType = int(number(base, "atk_type", 0))
+	return s
+}
+
+func number(m map[string]interface{}, key string, def float64) float64 {
+	if v, ok := m[key].(float64); ok {
+		return v
+	}
+	return def
+}
+
+// CombatPower 战力 = 秒伤 + 有效生命 + 生命恢复 + 吸血/闪避/秒杀
+func CombatPower(s PlayerStats) int {
+	critRate := math.Min(math.Max(s.CriticalRate, 0), 1)
+	critFactor := 1 + critRate*math.Max(s.CriticalDamage-1, 0)
+	dps := float64(s.Attack) * s.AttackSpeed * critFactor * float64(1+s.Trajectory)
+
+	reduction := math.Min(math.Max(s.DamageReduction, 0), maxDamageReduction)
+	ehp := float64(s.HP) / (1 - reduction/100)
+
+	power := dps*powerDPSWeight +
+		ehp*powerEHPWeight +
+		float64(s.Recovery)*powerRecoveryWeight +
+		float64(s.Drain+s.Dodge+s.InstantKill)*powerPercentWeight
+	return int(math.Round(power))
+}
+
+// Load 读取计算玩家属性所需的存档（指定区服）、已穿戴装备和启用的皮肤；装备和皮肤不分区服
+func Load(db *gorm.DB, userID uint, area int) (Input, error) {
+	in, err := loadAccount(db, userID)
+	if err != nil {
+		return in, err
+	}
+
+	var archive models.Archive
+	err = db.Where("user_id = ? AND area = ?", userID, area).First(&archive).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return in, err
+	}
+	in.Base = archiveBase(archive)
+	return in, nil
+}
+
+// loadAccount 读取不分区服的已穿戴装备、套装和启用的皮肤
+func loadAccount(db *gorm.DB, userID uint) (Input, error) {
+	var in Input
+	if err := db.Where("user_id = ? AND is_equipped = ?", userID, true).
+		Preload("EquipmentTemplate").
+		Preload("AdditionalAttrs").
+		Find(&in.Equipments).Error; err != nil {
+		return in, err
+	}
+
+	sets, err := LoadSets(db, templateIDs(in.Equipments))
+	if err != nil {
+		return in, err
+	}
+	in.Sets = sets
+
+	var userSkin models.UserSkin
+	err = db.Preload("Skin").Where("user_id = ? AND is_active = ?", userID, true).First(&userSkin).Error
+	if err == nil {
+		in.Skin = &userSkin.Skin
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return in, err
+	}
+	return in, nil
+}
+
+func archiveBase(archive models.Archive) map[string]interface{} {
+	if base, ok := archive.JSONData["base_attributes"].(map[string]interface{}); ok {
+		return base
+	}
+	return nil
+}
+
+// ForUser 计算玩家在某个区服的当前属性
+func ForUser(db *gorm.DB, userID uint, area int) (PlayerStats, error) {
+	in, err := Load(db, userID, area)
+	if err != nil {
+		return PlayerStats{}, err
+	}
+	return Compute(in), nil
+}
+
+// Refresh 重新计算玩家每个区服存档的战力并保存到 archives.combat_power（按区服的战力排行使用），
+// users.combat_power 保存各区服中的最高战力；装备、皮肤或存档变化后调用
+func Refresh(db *gorm.DB, userID uint) error {
+	in, err := loadAccount(db, userID)
+	if err != nil {
+		return err
+	}
+
+	var archives []models.Archive
+	if err := db.Where("user_id = ?", userID).Find(&archives).Error; err != nil {
+		return err
+	}
+
+	best := 0
+	if len(archives) == 0 {
+		best = Compute(in).CombatPower
+	}
+	for _, archive := range archives {
+		in.Base = archiveBase(archive)
+		power := Compute(in).CombatPower
+		best = max(best, power)
+		if err := db.Model(&models.Archive{}).Where("id = ?", archive.ID).Update("combat_power", power).Error; err != nil {
+			return err
+		}
+	}
+	return db.Model(&models.User{}).Where("id = ?", userID).Update("combat_power", best).Error
+}
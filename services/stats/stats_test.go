@@ -0,0 +1,40 @@
+package stats
+
+import (
+	"ggo/models"
+	"testing"
+)
+
+func TestAffixTypesAreApplied(t *testing.T) {
+	for _, attrType := range AffixTypes {
+		var s PlayerStats
+		var pct percentBonus
+		if !applyAffix(&s, &pct, attrType, 10) {
+			t.Errorf("affix type %s is listed in AffixTypes but not applied", attrType)
+		}
+		if s == (PlayerStats{}) && pct == (percentBonus{}) {
+			t.Errorf("affix type %s changed nothing", attrType)
+		}
+	}
+
+	var s PlayerStats
+	var pct percentBonus
+	if applyAffix(&s, &pct, "unknown", 10) || IsAffixType("unknown") {
+		t.Error("unknown affix type reported as supported")
+	}
+}
+
+func TestComputeAppliesMoveSpeedAndDodgeAffixes(t *testing.T) {
+	equipment := models.UserEquipment{
+		IsEquipped:        true,
+		EquipmentTemplate: models.EquipmentTemplate{Level: 1, Slot: "boots"},
+		AdditionalAttrs: []models.EquipmentAdditionalAttr{
+			{AttrType: "move_speed", Value: 12},
+			{AttrType: "dodge", Value: 3},
+		},
+	}
+	got := Compute(Input{Equipments: []models.UserEquipment{equipment}})
+	if got.MoveSpeed != 12 || got.Dodge != 3 {
+		t.Fatalf("move_speed = %d, dodge = %d, want 12 and 3", got.MoveSpeed, got.Dodge)
+	}
+}
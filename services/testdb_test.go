@@ -0,0 +1,98 @@
+package services
+
+import (
+	"fmt"
+	"ggo/models"
+	"strings"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// newTestDB 为每个测试创建独立的内存数据库并迁移全部表结构
+// SQLite 忽略行锁（FOR UPDATE），测试只覆盖单个请求内的业务逻辑
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	dsn := fmt.Sprintf("file:%s?mode=memory&cache=shared", strings.ReplaceAll(t.Name(), "/", "_"))
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("open test db: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("open test db: %v", err)
+	}
+	// 内存数据库在最后一个连接关闭时销毁，只保留一个连接
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	if err := db.AutoMigrate(
+		&models.User{},
+		&models.UserSkin{},
+		&models.Treasure{},
+		&models.Mail{},
+		&models.MyItem{},
+		&models.EquipmentTemplate{},
+		&models.UserEquipment{},
+		&models.EquipmentAdditionalAttr{},
+		&models.CurrencyTransaction{},
+		&models.UserSession{},
+		&models.WeChatAccount{},
+		&models.AffixDefinition{},
+		&models.AffixRange{},
+		&models.GameConfig{},
+		&models.Consumable{},
+		&models.MarketListing{},
+		&models.MysteryShop{},
+		&models.MysteryShopOffer{},
+		&models.Monster{},
+		&models.Scene{},
+		&models.GameRun{},
+		&models.GameRunSpawn{},
+		&models.DropTable{},
+		&models.DropTableEntry{},
+		&models.DropTableBinding{},
+	); err != nil {
+		t.Fatalf("migrate test db: %v", err)
+	}
+	return db
+}
+
+// createTestUser 创建测试用户，初始余额记入流水（与注册一致）
+func createTestUser(t *testing.T, db *gorm.DB, username string, gold, diamond int) *models.User {
+	t.Helper()
+	user := &models.User{Username: username, Password: "x", Gold: gold, Diamond: diamond, Level: 1}
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(user).Error; err != nil {
+			return err
+		}
+		return RecordInitialBalances(tx, user, models.CurrencyReasonRegister)
+	}); err != nil {
+		t.Fatalf("create user %s: %v", username, err)
+	}
+	return user
+}
+
+// assertLedgerBalanced 校验所有用户的余额与流水合计一致
+func assertLedgerBalanced(t *testing.T, db *gorm.DB) {
+	t.Helper()
+	mismatches, err := ReconcileCurrencyLedger(db)
+	if err != nil {
+		t.Fatalf("reconcile ledger: %v", err)
+	}
+	if len(mismatches) > 0 {
+		t.Fatalf("ledger mismatches: %+v", mismatches)
+	}
+}
+
+// userBalance 读取用户当前余额
+func userBalance(t *testing.T, db *gorm.DB, userID uint) (gold, diamond int) {
+	t.Helper()
+	var user models.User
+	if err := db.Select("id", "gold", "diamond").First(&user, userID).Error; err != nil {
+		t.Fatalf("load user %d: %v", userID, err)
+	}
+	return user.Gold, user.Diamond
+}
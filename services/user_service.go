@@ -1,10 +1,7 @@
 package services
 
 import (
-	"context"
 	"errors"
-	"fmt"
-	"ggo/database"
 	"ggo/models"
 	"ggo/utils"
 	"time"
@@ -12,12 +9,22 @@ import (
 	"gorm.io/gorm"
 )
 
+var (
+	ErrOldPasswordRequired = errors.New("修改密码需要提供原密码")
+	ErrOldPasswordWrong    = errors.New("原密码错误")
+	ErrInvalidPassword     = errors.New("密码格式不正确")
+	ErrInvalidUsername     = errors.New("用户名只能包含中文、英文和数字")
+	ErrUsernameTaken       = errors.New("用户名已存在")
+	ErrNothingToUpdate     = errors.New("没有需要更新的字段")
+)
+
 type UserService struct {
-	DB *gorm.DB
+	DB       *gorm.DB
+	Sessions *SessionService
 }
 
-func NewUserService(db *gorm.DB) *UserService {
-	return &UserService{DB: db}
+func NewUserService(db *gorm.DB, sessions *SessionService) *UserService {
+	return &UserService{DB: db, Sessions: sessions}
 }
 
 // GetDB 获取数据库实例（供控制器使用）
@@ -26,7 +33,7 @@ func (s *UserService) GetDB() *gorm.DB {
 }
 
 // LoginOrRegister 登录或注册
-func (s *UserService) LoginOrRegister(req *models.UserLoginRequest) (*models.UserLoginResponse, string, error) {
+func (s *UserService) LoginOrRegister(req *models.UserLoginRequest, client ClientInfo) (*models.UserLoginResponse, string, error) {
 	var user models.User
 
 	// 查找用户
@@ -34,17 +41,17 @@ func (s *UserService) LoginOrRegister(req *models.UserLoginRequest) (*models.Use
 
 	if errors.Is(err, gorm.ErrRecordNotFound) {
 		// 用户不存在，自动注册
-		return s.register(req)
+		return s.register(req, client)
 	} else if err != nil {
 		return nil, "", err
 	}
 
 	// 用户存在，验证密码
-	return s.login(&user, req.Password)
+	return s.login(&user, req.Password, client)
 }
 
 // register 注册新用户
-func (s *UserService) register(req *models.UserLoginRequest) (*models.UserLoginResponse, string, error) {
+func (s *UserService) register(req *models.UserLoginRequest, client ClientInfo) (*models.UserLoginResponse, string, error) {
 	// 加密密码
 	hashedPassword, err := utils.HashPassword(req.Password)
 	if err != nil {
@@ -59,51 +66,44 @@ func (s *UserService) register(req *models.UserLoginRequest) (*models.UserLoginR
 		LastLogin: time.Now(),
 	}
 
-	result := s.DB.Create(&user)
-	if result.Error != nil {
-		return nil, "", result.Error
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		return createPlayer(tx, &user)
+	})
+	if err != nil {
+		return nil, "", err
 	}
 
-	// 新用户默认绑定skinid=1的皮肤
-	userSkin := models.UserSkin{
-		UserID:   user.ID,
-		SkinID:   1,
-		IsActive: true, // 默认为激活状态
+	// 创建登录会话并生成token
+	tokens, err := s.Sessions.CreateSession(&user, client)
+	if err != nil {
+		return nil, "", err
 	}
 
-	skinResult := s.DB.Create(&userSkin)
-	if skinResult.Error != nil {
-		return nil, "", skinResult.Error
-	}
+	return NewLoginResponse(&user, tokens), tokens.AccessToken, nil
+}
 
-	// 生成token
-	token, err := utils.GenerateToken(user.ID, user.Username)
-	if err != nil {
-		return nil, "", err
+// createPlayer 创建新玩家：写入用户、记录初始余额流水、绑定默认皮肤
+func createPlayer(tx *gorm.DB, user *models.User) error {
+	if err := tx.Create(user).Error; err != nil {
+		return err
 	}
 
-	if database.RedisClient != nil {
-		ttl, ttlErr := utils.GetRemainingTime(token)
-		if ttlErr != nil {
-			ttl = 7 * 24 * time.Hour
-		}
-		database.RedisClient.Set(context.Background(), fmt.Sprintf("auth:token:%d", user.ID), token, ttl)
+	// 新用户赠送的金币记入流水
+	if err := RecordInitialBalances(tx, user, models.CurrencyReasonRegister); err != nil {
+		return err
 	}
 
-	response := &models.UserLoginResponse{
+	// 新用户默认绑定skinid=1的皮肤
+	userSkin := models.UserSkin{
 		UserID:   user.ID,
-		Username: user.Username,
-		Gold:     user.Gold,
-		Diamond:  user.Diamond,
-		Level:    user.Level,
-		Token:    token,
+		SkinID:   1,
+		IsActive: true, // 默认为激活状态
 	}
-
-	return response, token, nil
+	return tx.Create(&userSkin).Error
 }
 
 // login 用户登录
-func (s *UserService) login(user *models.User, password string) (*models.UserLoginResponse, string, error) {
+func (s *UserService) login(user *models.User, password string, client ClientInfo) (*models.UserLoginResponse, string, error) {
 	// 验证密码
 	if !utils.CheckPassword(password, user.Password) {
 		return nil, "", errors.New("密码错误")
@@ -112,31 +112,29 @@ func (s *UserService) login(user *models.User, password string) (*models.UserLog
 	// 更新最后登录时间
 	s.DB.Model(user).Update("last_login", time.Now())
 
-	// 生成新token
-	newToken, err := utils.GenerateToken(user.ID, user.Username)
+	// 创建登录会话并生成token
+	tokens, err := s.Sessions.CreateSession(user, client)
 	if err != nil {
 		return nil, "", err
 	}
 
-	if database.RedisClient != nil {
-		ttl, ttlErr := utils.GetRemainingTime(newToken)
-		if ttlErr != nil {
-			ttl = 7 * 24 * time.Hour
-		}
-		database.RedisClient.Set(context.Background(), fmt.Sprintf("auth:token:%d", user.ID), newToken, ttl)
-	}
+	return NewLoginResponse(user, tokens), tokens.AccessToken, nil
+}
 
-	response := &models.UserLoginResponse{
-		Img:      user.Img,
-		UserID:   user.ID,
-		Username: user.Username,
-		Gold:     user.Gold,
-		Diamond:  user.Diamond,
-		Level:    user.Level,
-		Token:    newToken,
+// NewLoginResponse 组装登录响应
+func NewLoginResponse(user *models.User, tokens *TokenPair) *models.UserLoginResponse {
+	return &models.UserLoginResponse{
+		Img:          user.Img,
+		UserID:       user.ID,
+		Username:     user.Username,
+		Gold:         user.Gold,
+		Diamond:      user.Diamond,
+		Level:        user.Level,
+		Token:        tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+		ExpiresIn:    tokens.ExpiresIn,
+		SessionID:    tokens.SessionID,
 	}
-
-	return response, newToken, nil
 }
 
 // GetUserByID 根据ID获取用户
@@ -145,3 +143,145 @@ func (s *UserService) GetUserByID(userID uint) (*models.User, error) {
 	err := s.DB.First(&user, userID).Error
 	return &user, err
 }
+
+// UpdateProfile 玩家修改个人资料；修改密码需校验原密码，成功后其他设备的会话失效
+func (s *UserService) UpdateProfile(userID uint, currentSessionID string, req *models.UpdateProfileRequest) (*models.User, error) {
+	user, err := s.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	updates := map[string]interface{}{}
+	if req.Img != nil {
+		updates["img"] = *req.Img
+	}
+
+	passwordChanged := false
+	if req.NewPassword != "" {
+		if req.OldPassword == "" {
+			return nil, ErrOldPasswordRequired
+		}
+		if !utils.CheckPassword(req.OldPassword, user.Password) {
+			return nil, ErrOldPasswordWrong
+		}
+		if !utils.ValidatePassword(req.NewPassword) {
+			return nil, ErrInvalidPassword
+		}
+		hashedPassword, err := utils.HashPassword(req.NewPassword)
+		if err != nil {
+			return nil, err
+		}
+		updates["password"] = hashedPassword
+		passwordChanged = true
+	}
+
+	if len(updates) == 0 {
+		return nil, ErrNothingToUpdate
+	}
+	if err := s.DB.Model(user).Updates(updates).Error; err != nil {
+		return nil, err
+	}
+
+	if passwordChanged {
+		if err := s.Sessions.RevokeAll(userID, currentSessionID); err != nil {
+			return nil, err
+		}
+	}
+	return user, nil
+}
+
+// AdminCreateUser 管理后台创建用户，初始余额记入流水
+func (s *UserService) AdminCreateUser(req *models.AdminCreateUserRequest) (*models.User, error) {
+	if !utils.ValidateUsername(req.Username) {
+		return nil, ErrInvalidUsername
+	}
+	if !utils.ValidatePassword(req.Password) {
+		return nil, ErrInvalidPassword
+	}
+
+	var count int64
+	if err := s.DB.Model(&models.User{}).Where("username = ?", req.Username).Count(&count).Error; err != nil {
+		return nil, err
+	}
+	if count > 0 {
+		return nil, ErrUsernameTaken
+	}
+
+	hashedPassword, err := utils.HashPassword(req.Password)
+	if err != nil {
+		return nil, err
+	}
+
+	level := req.Level
+	if level == 0 {
+		level = 1
+	}
+	user := models.User{
+		Username: req.Username,
+		Password: hashedPassword,
+		Img:      req.Img,
+		Gold:     req.Gold,
+		Diamond:  req.Diamond,
+		Level:    level,
+	}
+
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&user).Error; err != nil {
+			return err
+		}
+		// 创建时带入的余额记入流水
+		return RecordInitialBalances(tx, &user, models.CurrencyReasonAdminCreate)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// AdminUpdateUser 管理后台修改用户；重置密码后该用户所有会话失效
+func (s *UserService) AdminUpdateUser(userID uint, req *models.AdminUpdateUserRequest) (*models.User, error) {
+	user, err := s.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	updates := map[string]interface{}{}
+	if req.Img != nil {
+		updates["img"] = *req.Img
+	}
+	if req.Level != nil {
+		updates["level"] = *req.Level
+	}
+	if req.Password != "" {
+		if !utils.ValidatePassword(req.Password) {
+			return nil, ErrInvalidPassword
+		}
+		hashedPassword, err := utils.HashPassword(req.Password)
+		if err != nil {
+			return nil, err
+		}
+		updates["password"] = hashedPassword
+	}
+
+	if len(updates) == 0 {
+		return nil, ErrNothingToUpdate
+	}
+	if err := s.DB.Model(user).Updates(updates).Error; err != nil {
+		return nil, err
+	}
+
+	if req.Password != "" {
+		if err := s.Sessions.RevokeAll(userID, ""); err != nil {
+			return nil, err
+		}
+	}
+	return user, nil
+}
+
+// DeleteUser 删除用户并吊销其全部会话
+func (s *UserService) DeleteUser(userID uint) error {
+	if err := s.Sessions.RevokeAll(userID, ""); err != nil {
+		return err
+	}
+	return s.DB.Delete(&models.User{}, userID).Error
+}
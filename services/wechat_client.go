@@ -0,0 +1,108 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrWeChatCodeRejected 微信拒绝了登录code（无效或已使用），客户端需要重新调用 wx.login
+	ErrWeChatCodeRejected = errors.New("微信登录code无效")
+	// ErrWeChatUnavailable 微信接口调用失败、限流或返回了无法识别的数据
+	ErrWeChatUnavailable = errors.New("微信服务暂不可用")
+)
+
+// 微信拒绝登录code的错误码：40029 code无效，40163 code已被使用
+const (
+	weChatErrInvalidCode = 40029
+	weChatErrCodeUsed    = 40163
+)
+
+// WeChatSession jscode2session 返回的登录态
+type WeChatSession struct {
+	OpenID     string `json:"openid"`
+	UnionID    string `json:"unionid"`
+	SessionKey string `json:"session_key"`
+	ErrCode    int    `json:"errcode"`
+	ErrMsg     string `json:"errmsg"`
+}
+
+// WeChatClient 微信服务端接口，测试时可替换为本地模拟服务
+type WeChatClient interface {
+	// Code2Session 用小游戏 wx.login 的 code 换取 openid 和 session_key
+	Code2Session(ctx context.Context, code string) (*WeChatSession, error)
+}
+
+// httpWeChatClient 调用微信官方接口的实现
+type httpWeChatClient struct {
+	appID      string
+	appSecret  string
+	baseURL    string
+	httpClient *http.Client
+}
+
+// NewWeChatClient 创建微信接口客户端，baseURL 为空时使用官方地址
+func NewWeChatClient(appID, appSecret, baseURL string) WeChatClient {
+	if baseURL == "" {
+		baseURL = "https://api.weixin.qq.com"
+	}
+	return &httpWeChatClient{
+		appID:      appID,
+		appSecret:  appSecret,
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+// Code2Session 微信API文档：https://developers.weixin.qq.com/miniprogram/dev/api-backend/open-api/login/auth.code2Session.html
+func (c *httpWeChatClient) Code2Session(ctx context.Context, code string) (*WeChatSession, error) {
+	query := url.Values{}
+	query.Set("appid", c.appID)
+	query.Set("secret", c.appSecret)
+	query.Set("js_code", code)
+	query.Set("grant_type", "authorization_code")
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/sns/jscode2session?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: 调用微信API失败: %w", ErrWeChatUnavailable, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("%w: 读取响应失败: %w", ErrWeChatUnavailable, err)
+	}
+
+	var session WeChatSession
+	if err := json.Unmarshal(body, &session); err != nil {
+		return nil, fmt.Errorf("%w: 解析响应数据失败: %w", ErrWeChatUnavailable, err)
+	}
+
+	switch session.ErrCode {
+	case 0:
+	case weChatErrInvalidCode, weChatErrCodeUsed:
+		return nil, fmt.Errorf("%w: 获取openid失败: %s", ErrWeChatCodeRejected, session.ErrMsg)
+	default:
+		return nil, fmt.Errorf("%w: 获取openid失败: %d %s", ErrWeChatUnavailable, session.ErrCode, session.ErrMsg)
+	}
+	if session.OpenID == "" {
+		return nil, fmt.Errorf("%w: 响应中缺少openid", ErrWeChatUnavailable)
+	}
+	if session.SessionKey == "" {
+		return nil, fmt.Errorf("%w: 响应中缺少session_key", ErrWeChatUnavailable)
+	}
+
+	return &session, nil
+}
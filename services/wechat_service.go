@@ -0,0 +1,162 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"ggo/models"
+	"ggo/utils"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrWeChatAlreadyBound = errors.New("该账号已绑定微信")
+	ErrWeChatBoundToOther = errors.New("该微信已绑定其他账号")
+)
+
+// WeChatService 微信登录与账号绑定
+type WeChatService struct {
+	DB       *gorm.DB
+	Client   WeChatClient
+	Sessions *SessionService
+}
+
+func NewWeChatService(db *gorm.DB, client WeChatClient, sessions *SessionService) *WeChatService {
+	return &WeChatService{DB: db, Client: client, Sessions: sessions}
+}
+
+// Login 微信登录：按openid/unionid查找绑定的游戏账号，没有则自动注册，返回与账号密码登录相同的响应
+func (s *WeChatService) Login(ctx context.Context, code string, client ClientInfo) (*models.UserLoginResponse, error) {
+	wxSession, err := s.Client.Code2Session(ctx, code)
+	if err != nil {
+		return nil, err
+	}
+
+	var user models.User
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		account, err := findWeChatAccount(tx, wxSession)
+		if err != nil {
+			return err
+		}
+
+		if account != nil {
+			// 已绑定：刷新服务端保存的session_key
+			if err := tx.Model(account).Updates(map[string]interface{}{
+				"open_id":     wxSession.OpenID,
+				"union_id":    wxSession.UnionID,
+				"session_key": wxSession.SessionKey,
+			}).Error; err != nil {
+				return err
+			}
+			if err := tx.First(&user, account.UserID).Error; err != nil {
+				return err
+			}
+			return tx.Model(&user).Update("last_login", time.Now()).Error
+		}
+
+		// 未绑定：创建新的游戏账号，密码随机生成，只能通过微信登录（或后续绑定）
+		suffix, err := utils.RandomToken(5)
+		if err != nil {
+			return err
+		}
+		randomPassword, err := utils.RandomToken(16)
+		if err != nil {
+			return err
+		}
+		hashedPassword, err := utils.HashPassword(randomPassword)
+		if err != nil {
+			return err
+		}
+
+		user = models.User{
+			Username:  "wx" + suffix,
+			Password:  hashedPassword,
+			Gold:      100, // 新用户默认金币
+			Level:     1,   // 新用户默认等级
+			LastLogin: time.Now(),
+		}
+		if err := createPlayer(tx, &user); err != nil {
+			return err
+		}
+
+		return tx.Create(&models.WeChatAccount{
+			UserID:     user.ID,
+			OpenID:     wxSession.OpenID,
+			UnionID:    wxSession.UnionID,
+			SessionKey: wxSession.SessionKey,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	tokens, err := s.Sessions.CreateSession(&user, client)
+	if err != nil {
+		return nil, err
+	}
+	return NewLoginResponse(&user, tokens), nil
+}
+
+// Bind 将已登录的用户名账号绑定到微信
+func (s *WeChatService) Bind(ctx context.Context, userID uint, code string) (*models.WeChatAccount, error) {
+	wxSession, err := s.Client.Code2Session(ctx, code)
+	if err != nil {
+		return nil, err
+	}
+
+	var account models.WeChatAccount
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&models.WeChatAccount{}).Where("user_id = ?", userID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrWeChatAlreadyBound
+		}
+
+		existing, err := findWeChatAccount(tx, wxSession)
+		if err != nil {
+			return err
+		}
+		if existing != nil {
+			return ErrWeChatBoundToOther
+		}
+
+		account = models.WeChatAccount{
+			UserID:     userID,
+			OpenID:     wxSession.OpenID,
+			UnionID:    wxSession.UnionID,
+			SessionKey: wxSession.SessionKey,
+		}
+		return tx.Create(&account).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
+
+// findWeChatAccount 先按openid查找，找不到再按unionid查找，均未绑定时返回nil
+func findWeChatAccount(tx *gorm.DB, wxSession *WeChatSession) (*models.WeChatAccount, error) {
+	var account models.WeChatAccount
+	err := tx.Where("open_id = ?", wxSession.OpenID).First(&account).Error
+	if err == nil {
+		return &account, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	if wxSession.UnionID == "" {
+		return nil, nil
+	}
+	err = tx.Where("union_id = ?", wxSession.UnionID).First(&account).Error
+	if err == nil {
+		return &account, nil
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return nil, err
+}
@@ -0,0 +1,268 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"ggo/models"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	testWeChatAppID  = "wx-test-app"
+	testWeChatSecret = "wx-test-secret"
+)
+
+// newFakeWeChat 模拟微信 jscode2session 接口：按 js_code 返回预设的响应，未知 code 返回 40029
+func newFakeWeChat(t *testing.T, sessions map[string]WeChatSession) WeChatClient {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if r.URL.Path != "/sns/jscode2session" || query.Get("appid") != testWeChatAppID ||
+			query.Get("secret") != testWeChatSecret || query.Get("grant_type") != "authorization_code" {
+			t.Errorf("unexpected request: %s", r.URL.String())
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		code := query.Get("js_code")
+		if code == "malformed" {
+			w.Write([]byte("<html>"))
+			return
+		}
+		session, ok := sessions[code]
+		if !ok {
+			session = WeChatSession{ErrCode: 40029, ErrMsg: "invalid code"}
+		}
+		json.NewEncoder(w).Encode(session)
+	}))
+	t.Cleanup(server.Close)
+	return NewWeChatClient(testWeChatAppID, testWeChatSecret, server.URL+"/")
+}
+
+func TestWeChatClientCode2Session(t *testing.T) {
+	client := newFakeWeChat(t, map[string]WeChatSession{
+		"ok":          {OpenID: "open-1", UnionID: "union-1", SessionKey: "key-1"},
+		"no-openid":   {SessionKey: "key-1"},
+		"no-key":      {OpenID: "open-1"},
+		"rate-limit":  {ErrCode: 45011, ErrMsg: "api minute-quota reach limit"},
+		"code-reused": {ErrCode: 40163, ErrMsg: "code been used"},
+	})
+
+	tests := []struct {
+		code    string
+		want    *WeChatSession
+		wantErr string
+		wantIs  error
+	}{
+		{code: "ok", want: &WeChatSession{OpenID: "open-1", UnionID: "union-1", SessionKey: "key-1"}},
+		{code: "unknown", wantErr: "invalid code", wantIs: ErrWeChatCodeRejected},
+		{code: "rate-limit", wantErr: "minute-quota", wantIs: ErrWeChatUnavailable},
+		{code: "code-reused", wantErr: "code been used", wantIs: ErrWeChatCodeRejected},
+		{code: "no-openid", wantErr: "缺少openid", wantIs: ErrWeChatUnavailable},
+		{code: "no-key", wantErr: "缺少session_key", wantIs: ErrWeChatUnavailable},
+		{code: "malformed", wantErr: "解析响应数据失败", wantIs: ErrWeChatUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.code, func(t *testing.T) {
+			got, err := client.Code2Session(context.Background(), tt.code)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) || !errors.Is(err, tt.wantIs) {
+					t.Fatalf("err = %v, want %v containing %q", err, tt.wantIs, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if *got != *tt.want {
+				t.Fatalf("session = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWeChatClientUnreachable(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+
+	client := NewWeChatClient(testWeChatAppID, testWeChatSecret, server.URL)
+	if _, err := client.Code2Session(context.Background(), "ok"); !errors.Is(err, ErrWeChatUnavailable) || !strings.Contains(err.Error(), "调用微信API失败") {
+		t.Fatalf("err = %v", err)
+	}
+}
+
+func newTestWeChatService(t *testing.T, sessions map[string]WeChatSession) *WeChatService {
+	t.Helper()
+	db := newTestDB(t)
+	return NewWeChatService(db, newFakeWeChat(t, sessions), &SessionService{
+		DB:              db,
+		AccessTokenTTL:  time.Hour,
+		RefreshTokenTTL: 24 * time.Hour,
+	})
+}
+
+func TestWeChatLogin(t *testing.T) {
+	s := newTestWeChatService(t, map[string]WeChatSession{
+		"first":        {OpenID: "open-1", UnionID: "union-1", SessionKey: "key-1"},
+		"again":        {OpenID: "open-1", UnionID: "union-1", SessionKey: "key-2"},
+		"other-app":    {OpenID: "open-other-app", UnionID: "union-1", SessionKey: "key-3"},
+		"another-user": {OpenID: "open-2", SessionKey: "key-4"},
+	})
+	client := ClientInfo{DeviceID: "device-1", IP: "127.0.0.1"}
+
+	// 首次登录自动注册，赠送的金币记入流水
+	first, err := s.Login(context.Background(), "first", client)
+	if err != nil {
+		t.Fatalf("first login: %v", err)
+	}
+	if first.Token == "" || first.RefreshToken == "" || !strings.HasPrefix(first.Username, "wx") || first.Gold != 100 {
+		t.Fatalf("first login response = %+v", first)
+	}
+	var skins int64
+	s.DB.Model(&models.UserSkin{}).Where("user_id = ? AND skin_id = ?", first.UserID, 1).Count(&skins)
+	if skins != 1 {
+		t.Fatalf("default skins = %d, want 1", skins)
+	}
+	assertLedgerBalanced(t, s.DB)
+
+	tests := []struct {
+		name       string
+		code       string
+		wantSameAs uint // 0 表示应注册新账号
+		wantOpenID string
+		wantKey    string
+	}{
+		{name: "再次登录使用同一账号并刷新session_key", code: "again", wantSameAs: first.UserID, wantOpenID: "open-1", wantKey: "key-2"},
+		{name: "同一unionid的其他应用登录同一账号", code: "other-app", wantSameAs: first.UserID, wantOpenID: "open-other-app", wantKey: "key-3"},
+		{name: "其他微信注册新账号", code: "another-user", wantOpenID: "open-2", wantKey: "key-4"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := s.Login(context.Background(), tt.code, client)
+			if err != nil {
+				t.Fatalf("login: %v", err)
+			}
+			if tt.wantSameAs != 0 && resp.UserID != tt.wantSameAs {
+				t.Fatalf("user = %d, want %d", resp.UserID, tt.wantSameAs)
+			}
+			if tt.wantSameAs == 0 && resp.UserID == first.UserID {
+				t.Fatal("different wechat logged into the first account")
+			}
+
+			var account models.WeChatAccount
+			if err := s.DB.Where("user_id = ?", resp.UserID).First(&account).Error; err != nil {
+				t.Fatal(err)
+			}
+			if account.OpenID != tt.wantOpenID || account.SessionKey != tt.wantKey {
+				t.Fatalf("account = %+v", account)
+			}
+		})
+	}
+
+	var users, accounts int64
+	s.DB.Model(&models.User{}).Count(&users)
+	s.DB.Model(&models.WeChatAccount{}).Count(&accounts)
+	if users != 2 || accounts != 2 {
+		t.Fatalf("users = %d, accounts = %d, want 2 and 2", users, accounts)
+	}
+}
+
+func TestWeChatLoginRejectedCodeCreatesNothing(t *testing.T) {
+	s := newTestWeChatService(t, nil)
+
+	if _, err := s.Login(context.Background(), "expired", ClientInfo{}); err == nil {
+		t.Fatal("login with a rejected code succeeded")
+	}
+	var users, sessions int64
+	s.DB.Model(&models.User{}).Count(&users)
+	s.DB.Model(&models.UserSession{}).Count(&sessions)
+	if users != 0 || sessions != 0 {
+		t.Fatalf("users = %d, sessions = %d, want 0", users, sessions)
+	}
+}
+
+func TestWeChatBind(t *testing.T) {
+	tests := []struct {
+		name    string
+		setup   func(t *testing.T, s *WeChatService, userID uint)
+		code    string
+		wantErr error
+	}{
+		{name: "绑定成功", code: "bind"},
+		{
+			name: "账号已绑定微信",
+			setup: func(t *testing.T, s *WeChatService, userID uint) {
+				s.DB.Create(&models.WeChatAccount{UserID: userID, OpenID: "open-old", SessionKey: "key"})
+			},
+			code:    "bind",
+			wantErr: ErrWeChatAlreadyBound,
+		},
+		{
+			name: "微信已绑定其他账号",
+			setup: func(t *testing.T, s *WeChatService, userID uint) {
+				other := createTestUser(t, s.DB, "other", 0, 0)
+				s.DB.Create(&models.WeChatAccount{UserID: other.ID, OpenID: "open-bind", SessionKey: "key"})
+			},
+			code:    "bind",
+			wantErr: ErrWeChatBoundToOther,
+		},
+		{
+			name: "unionid已绑定其他账号",
+			setup: func(t *testing.T, s *WeChatService, userID uint) {
+				other := createTestUser(t, s.DB, "other", 0, 0)
+				s.DB.Create(&models.WeChatAccount{UserID: other.ID, OpenID: "open-other-app", UnionID: "union-bind", SessionKey: "key"})
+			},
+			code:    "bind",
+			wantErr: ErrWeChatBoundToOther,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestWeChatService(t, map[string]WeChatSession{
+				"bind": {OpenID: "open-bind", UnionID: "union-bind", SessionKey: "key-bind"},
+			})
+			user := createTestUser(t, s.DB, "player", 0, 0)
+			if tt.setup != nil {
+				tt.setup(t, s, user.ID)
+			}
+
+			account, err := s.Bind(context.Background(), user.ID, tt.code)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			if account.UserID != user.ID || account.OpenID != "open-bind" || account.UnionID != "union-bind" {
+				t.Fatalf("account = %+v", account)
+			}
+
+			// 绑定后可以用微信登录到该账号
+			resp, err := s.Login(context.Background(), tt.code, ClientInfo{})
+			if err != nil {
+				t.Fatalf("login after bind: %v", err)
+			}
+			if resp.UserID != user.ID {
+				t.Fatalf("login user = %d, want %d", resp.UserID, user.ID)
+			}
+		})
+	}
+}
+
+func TestWeChatBindRejectedCode(t *testing.T) {
+	s := newTestWeChatService(t, nil)
+	user := createTestUser(t, s.DB, "player", 0, 0)
+
+	if _, err := s.Bind(context.Background(), user.ID, "expired"); !errors.Is(err, ErrWeChatCodeRejected) {
+		t.Fatalf("err = %v", err)
+	}
+	var accounts int64
+	s.DB.Model(&models.WeChatAccount{}).Count(&accounts)
+	if accounts != 0 {
+		t.Fatalf("accounts = %d, want 0", accounts)
+	}
+}
@@ -1,6 +1,9 @@
 package utils
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"time"
 
@@ -9,25 +12,35 @@ import (
 
 var jwtSecret = []byte("your-secret-key-change-in-production") // 生产环境请修改
 
+// token受众：玩家token与后台token互不通用
+const (
+	AudiencePlayer = "player"
+	AudienceAdmin  = "admin"
+	AudienceRun    = "run"
+)
+
 type Claims struct {
-	UserID   uint   `json:"user_id"`
-	Username string `json:"username"`
+	UserID    uint   `json:"user_id"`
+	Username  string `json:"username"`
+	SessionID string `json:"sid"` // 登录会话ID，用于按设备吊销
 	jwt.RegisteredClaims
 }
 
-// GenerateToken 生成token
-func GenerateToken(userID uint, username string) (string, error) {
+// GenerateToken 生成访问token（短期有效，过期后使用刷新token换取）
+func GenerateToken(userID uint, username, sessionID string, ttl time.Duration) (string, error) {
 	nowTime := time.Now()
-	expireTime := nowTime.Add(7 * 24 * 3600 * time.Second) // 7天
+	expireTime := nowTime.Add(ttl)
 
 	claims := Claims{
-		UserID:   userID,
-		Username: username,
+		UserID:    userID,
+		Username:  username,
+		SessionID: sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(expireTime),
 			IssuedAt:  jwt.NewNumericDate(nowTime),
 			NotBefore: jwt.NewNumericDate(nowTime),
 			Issuer:    "myapp",
+			Audience:  jwt.ClaimStrings{AudiencePlayer},
 		},
 	}
 
@@ -45,7 +58,7 @@ func ParseToken(token string) (*Claims, error) {
 			return nil, errors.New("unexpected signing method")
 		}
 		return jwtSecret, nil
-	})
+	}, jwt.WithAudience(AudiencePlayer))
 
 	if err != nil {
 		return nil, err
@@ -58,22 +71,115 @@ func ParseToken(token string) (*Claims, error) {
 	return nil, errors.New("invalid token")
 }
 
-// RefreshToken 刷新token
-func RefreshToken(token string) (string, error) {
-	claims, err := ParseToken(token)
+// AdminClaims 管理后台token
+type AdminClaims struct {
+	AdminID  uint   `json:"admin_id"`
+	Username string `json:"username"`
+	Role     string `json:"role"`
+	jwt.RegisteredClaims
+}
+
+// GenerateAdminToken 生成管理后台token
+func GenerateAdminToken(adminID uint, username, role string, ttl time.Duration) (string, error) {
+	nowTime := time.Now()
+
+	claims := AdminClaims{
+		AdminID:  adminID,
+		Username: username,
+		Role:     role,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(nowTime.Add(ttl)),
+			IssuedAt:  jwt.NewNumericDate(nowTime),
+			NotBefore: jwt.NewNumericDate(nowTime),
+			Issuer:    "myapp",
+			Audience:  jwt.ClaimStrings{AudienceAdmin},
+		},
+	}
+
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(jwtSecret)
+}
+
+// ParseAdminToken 解析管理后台token，玩家token会因受众不符被拒绝
+func ParseAdminToken(token string) (*AdminClaims, error) {
+	tokenClaims, err := jwt.ParseWithClaims(token, &AdminClaims{}, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, errors.New("unexpected signing method")
+		}
+		return jwtSecret, nil
+	}, jwt.WithAudience(AudienceAdmin))
+
 	if err != nil {
+		return nil, err
+	}
+
+	if claims, ok := tokenClaims.Claims.(*AdminClaims); ok && tokenClaims.Valid {
+		return claims, nil
+	}
+
+	return nil, errors.New("invalid token")
+}
+
+// GenerateRefreshToken 生成刷新token（随机串，服务端只保存其哈希）
+func GenerateRefreshToken() (string, error) {
+	return RandomToken(32)
+}
+
+// RandomToken 生成指定字节数的随机串（十六进制）
+func RandomToken(size int) (string, error) {
+	buf := make([]byte, size)
+	if _, err := rand.Read(buf); err != nil {
 		return "", err
 	}
+	return hex.EncodeToString(buf), nil
+}
+
+// HashToken 计算token的哈希，用于入库比对
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// RunClaims 对局token，开局时签发，结算时校验对局归属
+type RunClaims struct {
+	RunID  uint `json:"run_id"`
+	UserID uint `json:"user_id"`
+	jwt.RegisteredClaims
+}
+
+// GenerateRunToken 生成对局token
+func GenerateRunToken(runID, userID uint, ttl time.Duration) (string, error) {
+	nowTime := time.Now()
+
+	claims := RunClaims{
+		RunID:  runID,
+		UserID: userID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(nowTime.Add(ttl)),
+			IssuedAt:  jwt.NewNumericDate(nowTime),
+			Issuer:    "myapp",
+			Audience:  jwt.ClaimStrings{AudienceRun},
+		},
+	}
 
-	// 如果token还有效，重新生成
-	return GenerateToken(claims.UserID, claims.Username)
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(jwtSecret)
 }
 
-// GetRemainingTime 获取token剩余时间
-func GetRemainingTime(token string) (time.Duration, error) {
-	claims, err := ParseToken(token)
+// ParseRunToken 解析对局token
+func ParseRunToken(token string) (*RunClaims, error) {
+	tokenClaims, err := jwt.ParseWithClaims(token, &RunClaims{}, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, errors.New("unexpected signing method")
+		}
+		return jwtSecret, nil
+	}, jwt.WithAudience(AudienceRun))
+
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
-	return time.Until(claims.ExpiresAt.Time), nil
+
+	if claims, ok := tokenClaims.Claims.(*RunClaims); ok && tokenClaims.Valid {
+		return claims, nil
+	}
+
+	return nil, errors.New("invalid token")
 }